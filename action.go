@@ -2,8 +2,11 @@ package flagx
 
 import (
 	"context"
+	"fmt"
+	"log/slog"
 	"reflect"
 	"strings"
+	"sync"
 
 	"github.com/henrylee2cn/goutil/status"
 )
@@ -20,6 +23,16 @@ type (
 	// NOTE:
 	//  If need to return an error, use *Context.ThrowStatus or *Context.CheckStatus
 	ActionFunc func(*Context)
+	// ActionE action that returns error, which is converted to a status with
+	// code StatusExecuteFailed automatically
+	// NOTE:
+	//  If it is a struct, wrap it by WrapActionE to set as an action
+	ActionE interface {
+		// ExecuteE executes action.
+		ExecuteE(*Context) error
+	}
+	// ActionErrFunc action function that returns error
+	ActionErrFunc func(*Context) error
 	// ActionCopier an interface that can create its own copy
 	ActionCopier interface {
 		DeepCopy() Action
@@ -30,21 +43,72 @@ type (
 	Filter interface {
 		Filter(c *Context, next ActionFunc)
 	}
+	// ParsedFilter an optional interface of filter, if implemented, FilterParsed is called
+	// instead of Filter with the action object that has been parsed and validated
+	// NOTE:
+	//  If need to return an error, use *Context.ThrowStatus or *Context.CheckStatus
+	ParsedFilter interface {
+		FilterParsed(c *Context, action interface{}, next ActionFunc)
+	}
+	// ParsedFilterFunc parsed filter function
+	// NOTE:
+	//  If need to return an error, use *Context.ThrowStatus or *Context.CheckStatus
+	ParsedFilterFunc func(c *Context, action interface{}, next ActionFunc)
+	// FilterE filter that returns error, which is converted to a status with
+	// code StatusExecuteFailed automatically
+	// NOTE:
+	//  If it is a struct, wrap it by WrapFilterE to add as a filter
+	FilterE interface {
+		FilterE(c *Context, next ActionFunc) error
+	}
+	// FilterErrFunc filter function that returns error
+	FilterErrFunc func(c *Context, next ActionFunc) error
 	// FilterCopier an interface that can create its own copy
 	FilterCopier interface {
 		DeepCopy() Filter
 	}
+	// Resetter an optional interface of the struct action or filter, if it is implemented and
+	// the copier interface is not, the objects are recycled by a sync.Pool after each execution
+	// instead of being allocated by reflection, and Reset is called before they are reused
+	// NOTE:
+	//  Reset should clear the fields that are not flags, the flags are reset by their defaults;
+	//  the objects must not be retained after the execution.
+	Resetter interface {
+		Reset()
+	}
 	// FilterFunc filter function
 	// NOTE:
 	//  If need to return an error, use *Context.ThrowStatus or *Context.CheckStatus
 	FilterFunc func(c *Context, next ActionFunc)
+	// PreRouterFunc rewrites the arguments before routing, such as alias expansion,
+	// legacy-flag rewriting and profile injection.
+	PreRouterFunc func(ctx context.Context, arguments []string) ([]string, error)
+	// PanicError a non-Status panic re-panicked by Exec when the app does not recover panics
+	PanicError struct {
+		CmdPath []string    // The command path being executed
+		Value   interface{} // The original panic value
+		Stack   []byte      // The stack of the original panic
+	}
 	// Context context of an action execution
 	Context struct {
 		context.Context
-		args      []string
-		cmdPath   []string
-		cmd       *Command
-		execScope Scope
+		args          []string
+		rawArgs       []string
+		cmdPath       []string
+		cmd           *Command
+		execScope     Scope
+		snap          *execSnapshot
+		requestID     string
+		requestIDOnce sync.Once
+		logger        *slog.Logger
+		loggerOnce    sync.Once
+		values        map[interface{}]interface{}
+		valuesLock    sync.RWMutex
+		filters       []Filter     // The filter instances in the chain of the execution
+		outputFormat  OutputFormat // The format selected by the output flag
+		group         *goGroup     // The goroutines started by Go
+		groupOnce     sync.Once
+		external      bool // Runs an external command, see App.SetExternalCommands
 	}
 )
 
@@ -52,6 +116,7 @@ type (
 	contextKey    int8
 	actionFactory struct {
 		elemType reflect.Type
+		pool     *sync.Pool // The recycled objects if the type implements Resetter
 	}
 	factory      actionFactory
 	actionObject struct {
@@ -61,14 +126,35 @@ type (
 		actionFactory ActionCopier
 		actionFunc    ActionFunc
 	}
+	errAction struct {
+		obj ActionE
+	}
+	errFilter struct {
+		obj FilterE
+	}
+	namedFilter struct {
+		name   string
+		filter Filter
+	}
 	filterObject struct {
+		name       string
 		flagSet    *FlagSet
 		options    map[string]*Flag
 		factory    FilterCopier
-		filterFunc FilterFunc
+		filterFunc Filter
 	}
 )
 
+// NamedFilter returns the filter with the name, which can be used to remove or replace it.
+func NamedFilter(name string, filter Filter) Filter {
+	return &namedFilter{name: name, filter: filter}
+}
+
+// Filter implements Filter interface.
+func (f *namedFilter) Filter(c *Context, next ActionFunc) {
+	f.filter.Filter(c, next)
+}
+
 // Execute implements Action interface.
 func (fn ActionFunc) Execute(c *Context) {
 	fn(c)
@@ -79,12 +165,159 @@ func (fn FilterFunc) Filter(c *Context, next ActionFunc) {
 	fn(c, next)
 }
 
+// Filter implements Filter interface, it is called with nil action.
+func (fn ParsedFilterFunc) Filter(c *Context, next ActionFunc) {
+	fn(c, nil, next)
+}
+
+// FilterParsed implements ParsedFilter interface.
+func (fn ParsedFilterFunc) FilterParsed(c *Context, action interface{}, next ActionFunc) {
+	fn(c, action, next)
+}
+
+// callFilter calls FilterParsed if the filter implements ParsedFilter, otherwise Filter.
+func callFilter(c *Context, filter Filter, action Action, next ActionFunc) {
+	if pf, ok := filter.(ParsedFilter); ok {
+		pf.FilterParsed(c, rawObject(action), next)
+		return
+	}
+	filter.Filter(c, next)
+}
+
+// WrapActionE wraps the error-returning action as an Action.
+func WrapActionE(action ActionE) Action {
+	return &errAction{obj: action}
+}
+
+// WrapFilterE wraps the error-returning filter as a Filter.
+func WrapFilterE(filter FilterE) Filter {
+	return &errFilter{obj: filter}
+}
+
+// Execute implements Action interface.
+func (fn ActionErrFunc) Execute(c *Context) {
+	c.checkExecuteError(fn(c))
+}
+
+// ExecuteE implements ActionE interface.
+func (fn ActionErrFunc) ExecuteE(c *Context) error {
+	return fn(c)
+}
+
+// Filter implements Filter interface.
+func (fn FilterErrFunc) Filter(c *Context, next ActionFunc) {
+	c.checkExecuteError(fn(c, next))
+}
+
+// FilterE implements FilterE interface.
+func (fn FilterErrFunc) FilterE(c *Context, next ActionFunc) error {
+	return fn(c, next)
+}
+
+// Execute implements Action interface.
+func (a *errAction) Execute(c *Context) {
+	c.checkExecuteError(a.obj.ExecuteE(c))
+}
+
+// Filter implements Filter interface.
+func (f *errFilter) Filter(c *Context, next ActionFunc) {
+	c.checkExecuteError(f.obj.FilterE(c, next))
+}
+
+func (c *Context) checkExecuteError(err error) {
+	if err != nil {
+		panic(c.snap.newStatus(StatusExecuteFailed, "", err))
+	}
+}
+
+// rawObject returns the original object of the wrapped error-returning action or filter.
+func rawObject(v interface{}) interface{} {
+	switch w := v.(type) {
+	case *errAction:
+		return w.obj
+	case *errFilter:
+		return w.obj
+	}
+	return v
+}
+
+func newActionFactory(elemType reflect.Type) *actionFactory {
+	h := &actionFactory{elemType: elemType}
+	if reflect.PtrTo(elemType).Implements(reflect.TypeOf((*Resetter)(nil)).Elem()) {
+		h.pool = &sync.Pool{New: func() interface{} {
+			return reflect.New(elemType).Interface()
+		}}
+	}
+	return h
+}
+
+// newObject returns a new object, or a recycled one that has been reset.
+func (h *actionFactory) newObject() interface{} {
+	if h.pool == nil {
+		return reflect.New(h.elemType).Interface()
+	}
+	v := h.pool.Get()
+	v.(Resetter).Reset()
+	return v
+}
+
+// recycle puts the object created by DeepCopy back to the pool, if any.
+func (h *actionFactory) recycle(obj interface{}) {
+	if h.pool != nil {
+		h.pool.Put(rawObject(obj))
+	}
+}
+
+// recycle records the object to be put back to the pool of the factory after the execution.
+func (snap *execSnapshot) recycle(h *actionFactory, obj interface{}) {
+	if h.pool != nil {
+		snap.recycled = append(snap.recycled, recycledObject{factory: h, obj: obj})
+	}
+}
+
+// release recycles the pooled objects of the execution.
+func (snap *execSnapshot) release() {
+	if snap.result != nil { // the objects are returned by ExecResult
+		snap.recycled = nil
+		return
+	}
+	for _, r := range snap.recycled {
+		r.factory.recycle(r.obj)
+	}
+	snap.recycled = nil
+}
+
 func (h *actionFactory) DeepCopy() Action {
-	return reflect.New(h.elemType).Interface().(Action)
+	switch v := h.newObject().(type) {
+	case Action:
+		return v
+	case ActionE:
+		return WrapActionE(v)
+	default:
+		panic(fmt.Errorf("%T does not implement Action or ActionE", v))
+	}
 }
 
 func (f *factory) DeepCopy() Filter {
-	return reflect.New(f.elemType).Interface().(Filter)
+	switch v := (*actionFactory)(f).newObject().(type) {
+	case Filter:
+		return v
+	case FilterE:
+		return WrapFilterE(v)
+	default:
+		panic(fmt.Errorf("%T does not implement Filter or FilterE", v))
+	}
+}
+
+// Error implements error interface.
+func (p *PanicError) Error() string {
+	return fmt.Sprintf("flagx: panic in command %q: %v", strings.Join(p.CmdPath, " "), p.Value)
+}
+
+// Unwrap returns the original panic value if it is an error.
+func (p *PanicError) Unwrap() error {
+	err, _ := p.Value.(error)
+	return err
 }
 
 // Args returns the command arguments.
@@ -92,11 +325,78 @@ func (c *Context) Args() []string {
 	return c.args
 }
 
+// RawArgs returns the command arguments before being rewritten by the pre-routers.
+func (c *Context) RawArgs() []string {
+	return c.rawArgs
+}
+
+// SetValue sets the value scoped to the current execution, such as the
+// authenticated user passed from a filter to the action.
+// NOTE:
+//  unlike *Command.SetMeta, the value is not shared by other executions.
+func (c *Context) SetValue(key, val interface{}) {
+	c.valuesLock.Lock()
+	defer c.valuesLock.Unlock()
+	if c.values == nil {
+		c.values = make(map[interface{}]interface{}, 8)
+	}
+	c.values[key] = val
+}
+
+// Value returns the value set by SetValue, or the value of the embedded context.Context.
+func (c *Context) Value(key interface{}) interface{} {
+	c.valuesLock.RLock()
+	val, ok := c.values[key]
+	c.valuesLock.RUnlock()
+	if ok {
+		return val
+	}
+	if c.Context == nil {
+		return nil
+	}
+	return c.Context.Value(key)
+}
+
+// Filter returns the filter instance of the type in the chain of the current execution,
+// such as the struct filter parsing the authenticated user, or nil if it does not exist.
+// NOTE:
+//  @typ can be the struct type or its pointer type;
+//  the instances are created per execution, so they can be read by the action safely.
+func (c *Context) Filter(typ reflect.Type) interface{} {
+	for _, filter := range c.filters {
+		obj := rawObject(filter)
+		t := reflect.TypeOf(obj)
+		if t == typ || t.Kind() == reflect.Ptr && t.Elem() == typ {
+			return obj
+		}
+	}
+	return nil
+}
+
+// FilterOf returns the filter instance of type T in the chain of the current execution,
+// see *Context.Filter.
+// Example:
+//  auth, ok := flagx.FilterOf[*AuthFilter](c)
+func FilterOf[T any](c *Context) (T, bool) {
+	for _, filter := range c.filters {
+		if obj, ok := rawObject(filter).(T); ok {
+			return obj, true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
 // GetCmdMeta gets the command meta.
 func (c *Context) GetCmdMeta(key interface{}) interface{} {
 	return c.cmd.GetMeta(key)
 }
 
+// RangeCmdMeta calls fn for each command meta in no particular order, and stops if fn returns false.
+func (c *Context) RangeCmdMeta(fn func(key, val interface{}) bool) {
+	c.cmd.RangeMeta(fn)
+}
+
 // CmdPath returns the command path slice.
 func (c *Context) CmdPath() []string {
 	return c.cmdPath
@@ -112,9 +412,9 @@ func (c *Context) CmdScope() Scope {
 	return c.cmd.scope
 }
 
-// ExecScope returns the executor scope.
+// ExecScope returns the executor scope passed to Exec, or InitialScope if it is not passed.
 func (c *Context) ExecScope() Scope {
-	return c.cmd.scope
+	return c.execScope
 }
 
 // UsageText returns the command usage.
@@ -122,12 +422,12 @@ func (c *Context) UsageText() string {
 	return c.cmd.UsageText(c.execScope)
 }
 
-// ThrowStatus creates a status with stack, and panic.
+// ThrowStatus creates a status with stack unless it is disabled by *App.SetStatusStack, and panic.
 func (c *Context) ThrowStatus(code int32, msg string, cause ...interface{}) {
-	panic(status.New(code, msg, cause...).TagStack(1))
+	panic(c.snap.newStatus(code, msg, cause...))
 }
 
-// CheckStatus if err!=nil, create a status with stack, and panic.
+// CheckStatus if err!=nil, create a status with stack unless it is disabled by *App.SetStatusStack, and panic.
 // NOTE:
 //  If err!=nil and msg=="", error text is set to msg
 func (c *Context) CheckStatus(err error, code int32, msg string, whenError ...func()) {
@@ -137,5 +437,26 @@ func (c *Context) CheckStatus(err error, code int32, msg string, whenError ...fu
 	if len(whenError) > 0 && whenError[0] != nil {
 		whenError[0]()
 	}
-	panic(status.New(code, msg, err).TagStack(1))
+	panic(c.snap.newStatus(code, msg, err))
+}
+
+// newStatus creates a status, with the stack unless it is disabled by *App.SetStatusStack.
+func (snap *execSnapshot) newStatus(code int32, msg string, cause ...interface{}) *Status {
+	stat := status.New(code, msg, cause...)
+	if snap == nil || !snap.noStatusStack {
+		stat.TagStack(2)
+	}
+	return stat
+}
+
+// throwStatus creates a status like newStatus, and panic.
+func (snap *execSnapshot) throwStatus(code int32, msg string, cause interface{}) {
+	panic(snap.newStatus(code, msg, cause))
+}
+
+// checkStatus if err!=nil, create a status like newStatus, and panic.
+func (snap *execSnapshot) checkStatus(err error, code int32, msg string) {
+	if err != nil {
+		panic(snap.newStatus(code, msg, err))
+	}
 }
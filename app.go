@@ -1,13 +1,19 @@
 package flagx
 
 import (
-	"bytes"
+	"context"
 	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"text/template"
+	"text/template/parse"
 	"time"
 
 	"github.com/henrylee2cn/goutil"
@@ -25,16 +31,122 @@ type (
 		authors                 []Author
 		copyright               string
 		notFound                ActionFunc
+		errorHandler            ErrorHandlerFunc
+		propagatePanics         bool
+		noStatusStack           bool
 		usageTemplate           *template.Template
+		usageFuncs              template.FuncMap
+		usageData               map[string]interface{}
 		validator               ValidateFunc
 		usageText               string
+		usageDirty              bool                   // The usage needs to be rendered
+		usageLock               sync.Mutex             // The lock of rendering the usage
+		usageFrame              atomic.Pointer[string] // The rendered template with the usage placeholder
 		execScopeUsageTexts     map[Scope]string
 		execScopeUsageTextsLock sync.RWMutex
 		scopeMatcherFunc        func(cmdScope, execScope Scope) error
+		scopeNames              map[Scope]string
+		translator              TranslateFunc
+		colorMode               ColorMode
+		colored                 bool
+		usePager                bool
+		externalCommands        bool
+		preRouters              []PreRouterFunc
+		frozen                  int32
+		tracer                  Tracer
+		logger                  *slog.Logger
+		auditor                 AuditFunc
+		metrics                 Collector
+		authorizer              Authorizer
+		auditSecrets            map[string]bool
+		configFlag              string
+		configDecoder           ConfigDecoder
+		configSearchPaths       []string
+		configNames             []string
+		envPrefix               string
+		expander                ExpandFunc
+		remoteSource            RemoteSource
+		remoteTimeout           time.Duration
+		remotePolicy            RemoteErrorPolicy
+		reloadEnabled           bool
+		reloadFuncs             []ReloadFunc
+		reload                  reloadState
+		shutdownEnabled         bool
+		shutdownGrace           time.Duration
+		shutdown                shutdownState
+		secretResolver          SecretResolver
+		defaultsProviders       []DefaultsProvider
+		fsys                    fs.FS
+		diagnostics             *slog.Logger
+		parseMode               ParseMode
+		ignoredFlagFunc         IgnoredFlagFunc
+		output                  io.Writer
+		completion              bool
+		versionFlag             string
+		versionTemplate         *template.Template
+		globals                 *FlagSet
 		lock                    sync.RWMutex
 	}
+	// execSnapshot the immutable settings of the app used by one execution
+	execSnapshot struct {
+		notFound          ActionFunc
+		errorHandler      ErrorHandlerFunc
+		propagatePanics   bool
+		noStatusStack     bool
+		validator         ValidateFunc
+		scopeMatcherFunc  func(cmdScope, execScope Scope) error
+		translator        TranslateFunc
+		externalCommands  bool
+		preRouters        []PreRouterFunc
+		tracer            Tracer
+		logger            *slog.Logger
+		auditor           AuditFunc
+		metrics           Collector
+		authorizer        Authorizer
+		auditSecrets      map[string]bool
+		cmdName           string
+		configFlag        string
+		configDecoder     ConfigDecoder
+		configSearchPaths []string
+		configNames       []string
+		config            map[string]string
+		envPrefix         string
+		expander          ExpandFunc
+		remoteSource      RemoteSource
+		remoteTimeout     time.Duration
+		remotePolicy      RemoteErrorPolicy
+		reloadEnabled     bool
+		shutdownEnabled   bool
+		secretResolver    SecretResolver
+		defaultsProviders []DefaultsProvider
+		fsys              fs.FS
+		diagnostics       *slog.Logger
+		parseMode         ParseMode
+		ignoredFlagFunc   IgnoredFlagFunc
+		output            io.Writer
+		completion        bool
+		versionFlag       string
+		result            *execResult       // The result collected by ExecResult
+		env               map[string]string // The environment injected by WithEnv, nil means the process one
+		ctx               context.Context   // The context of the execution
+		configFile        string            // The loaded config file
+		configFileDecoder ConfigDecoder     // The decoder of the loaded config file
+		bindings          []*flagBinding    // The parsed flag sets to be reloaded
+		outputFormat      OutputFormat      // The format selected by the output flag of the routed command
+		recycled          []recycledObject  // The pooled objects to be recycled after the execution
+		globals           *FlagSet          // The parsed global flags and non-flags, see App.GlobalFlags
+		shutdown          *execShutdown     // The shutdown hooks of the execution
+		resolvers         []func()          // The flag value resolutions deferred by the routing, see deferResolve
+	}
+	// recycledObject an object created by a pooled factory
+	recycledObject struct {
+		factory *actionFactory
+		obj     interface{}
+	}
 	// Scope command scope
 	Scope int32
+	// ErrorHandlerFunc handler of the non-OK status produced by Exec
+	ErrorHandlerFunc func(*Context, *Status)
 	// ValidateFunc validator for struct flag
 	ValidateFunc func(interface{}) error
 	// Author represents someone who has contributed to a cli project.
@@ -58,10 +170,18 @@ const (
 	StatusParseFailed    int32 = 3
 	StatusValidateFailed int32 = 4
 	StatusMismatchScope  int32 = 5
+	StatusLoadFailed     int32 = 6
+	StatusExternalFailed int32 = 7
+	StatusExecuteFailed  int32 = 8
+	StatusConfigFailed   int32 = 9
+	StatusLocked         int32 = 10 // Another instance holds the lock file, see Command.SetSingleton
+	StatusForbidden      int32 = 11 // The execution is denied by the authorizer, see App.SetAuthorizer
 )
 
 const (
 	currCmdName contextKey = iota
+	requestIDKey
+	callerKey
 )
 
 var (
@@ -116,6 +236,70 @@ func NewApp() *App {
 	return a
 }
 
+// Freeze freezes the command tree, after which adding commands, filters and actions panics.
+// NOTE:
+//  it is called automatically by the first Exec, so that the concurrent executions never
+//  race with the tree mutation; the settings of the app are snapshotted by each execution;
+//  lazy commands can still be loaded;
+//  the routing tables of the commands are built here, so that Exec routes without locking.
+func (a *App) Freeze() {
+	if !atomic.CompareAndSwapInt32(&a.frozen, 0, 1) {
+		return
+	}
+	a.Command.Walk(func(c *Command) bool {
+		c.routing()
+		return true
+	})
+}
+
+// Frozen reports whether the command tree is frozen.
+func (a *App) Frozen() bool {
+	return atomic.LoadInt32(&a.frozen) == 1
+}
+
+func (a *App) snapshot() *execSnapshot {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	return &execSnapshot{
+		notFound:          a.notFound,
+		errorHandler:      a.errorHandler,
+		propagatePanics:   a.propagatePanics,
+		noStatusStack:     a.noStatusStack,
+		validator:         a.validator,
+		scopeMatcherFunc:  a.scopeMatcherFunc,
+		translator:        a.translator,
+		externalCommands:  a.externalCommands,
+		preRouters:        a.preRouters,
+		tracer:            a.tracer,
+		logger:            a.logger,
+		auditor:           a.auditor,
+		metrics:           a.metrics,
+		authorizer:        a.authorizer,
+		auditSecrets:      a.auditSecrets,
+		cmdName:           a.cmdName,
+		configFlag:        a.configFlag,
+		configDecoder:     a.configDecoder,
+		configSearchPaths: a.configSearchPaths,
+		configNames:       a.configNames,
+		envPrefix:         a.envPrefix,
+		expander:          a.expander,
+		remoteSource:      a.remoteSource,
+		remoteTimeout:     a.remoteTimeout,
+		remotePolicy:      a.remotePolicy,
+		reloadEnabled:     a.reloadEnabled,
+		shutdownEnabled:   a.shutdownEnabled,
+		secretResolver:    a.secretResolver,
+		defaultsProviders: a.defaultsProviders,
+		fsys:              a.fsys,
+		diagnostics:       a.diagnostics,
+		parseMode:         a.parseMode,
+		ignoredFlagFunc:   a.ignoredFlagFunc,
+		output:            a.output,
+		completion:        a.completion,
+		versionFlag:       a.versionFlag,
+	}
+}
+
 // CmdName returns the command name of the application.
 // Defaults to filepath.Base(os.Args[0])
 func (a *App) CmdName() string {
@@ -251,6 +435,57 @@ func (a *App) SetNotFound(fn ActionFunc) {
 	a.notFound = fn
 }
 
+// SetErrorHandler sets the handler invoked when Exec produces a non-OK status.
+// NOTE:
+//  it is the central place to present errors and select the exit code.
+func (a *App) SetErrorHandler(fn ErrorHandlerFunc) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.errorHandler = fn
+}
+
+// SetRecoverPanics sets whether to recover the non-Status panics into a Status in Exec.
+// NOTE:
+//  the default is true;
+//  if false, the non-Status panics are re-panicked as *PanicError annotated with the command path.
+func (a *App) SetRecoverPanics(recover bool) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.propagatePanics = !recover
+}
+
+// SetStatusStack sets whether to capture the stack for the statuses thrown during Exec,
+// such as by *Context.ThrowStatus, *Context.CheckStatus and the routing failures.
+// NOTE:
+//  the default is true;
+//  disable it if the statuses are used for the expected control flow on hot paths,
+//  such as not found and validation failures, since capturing the stack is expensive;
+//  the stack of the recovered non-Status panics is always captured.
+func (a *App) SetStatusStack(capture bool) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.noStatusStack = !capture
+}
+
+// AddPreRouter adds the functions that rewrite the arguments before routing.
+// NOTE:
+//  they are called in the order of addition, and the rewritten arguments are used by routing;
+//  if one returns error, Exec returns a status with code StatusBadArgs.
+func (a *App) AddPreRouter(fns ...PreRouterFunc) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.preRouters = append(a.preRouters, fns...)
+}
+
+func (snap *execSnapshot) preRoute(ctx context.Context, arguments []string) []string {
+	var err error
+	for _, fn := range snap.preRouters {
+		arguments, err = fn(ctx, arguments)
+		snap.checkStatus(err, StatusBadArgs, "")
+	}
+	return arguments
+}
+
 // SetValidator sets parameter validator for struct action and struct filter.
 func (a *App) SetValidator(fn ValidateFunc) {
 	a.lock.Lock()
@@ -259,10 +494,69 @@ func (a *App) SetValidator(fn ValidateFunc) {
 }
 
 // SetUsageTemplate sets usage template.
+// NOTE:
+//  the template is cloned, and the "tr" function is bound to the translator of the app.
 func (a *App) SetUsageTemplate(tmpl *template.Template) {
 	a.lock.Lock()
 	defer a.lock.Unlock()
-	a.usageTemplate = tmpl
+	a.usageTemplate = template.Must(tmpl.Clone())
+	a.usageTemplate.Funcs(template.FuncMap{"tr": a.translate})
+	if a.usageFuncs != nil {
+		a.usageTemplate.Funcs(a.usageFuncs)
+	}
+	a.resetUsageLocked()
+}
+
+// SetUsageFuncs adds the functions to the function map of the usage template.
+// NOTE:
+//  the template set by SetUsageTemplate must be parsed with the same function names defined;
+//  it is legal to overwrite the functions, such as "tr".
+func (a *App) SetUsageFuncs(funcMap template.FuncMap) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.usageFuncs == nil {
+		a.usageFuncs = make(template.FuncMap, len(funcMap))
+	}
+	for k, v := range funcMap {
+		a.usageFuncs[k] = v
+	}
+	a.usageTemplate.Funcs(funcMap)
+	a.resetUsageLocked()
+}
+
+// SetUsageData sets the extra data of the usage template.
+// NOTE:
+//  the built-in data, such as AppName and Usage, cannot be overwritten.
+func (a *App) SetUsageData(key string, value interface{}) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.usageData == nil {
+		a.usageData = make(map[string]interface{}, 16)
+	}
+	a.usageData[key] = value
+	a.resetUsageLocked()
+}
+
+// LookupFlag lookups the flag or non-flag of the command addressed by the path names,
+// resolving through the action and filters of the command, and then the filters of its ancestors.
+// NOTE:
+//  the lazy commands on the path are loaded;
+//  returns nil if it does not exist.
+func (a *App) LookupFlag(pathCmdNames []string, name string) *Flag {
+	cmd := a.Command
+	for _, cmdName := range pathCmdNames {
+		if cmd.Load() != nil {
+			return nil
+		}
+		cmd = cmd.LookupSubcommand(cmdName)
+		if cmd == nil {
+			return nil
+		}
+	}
+	if cmd.Load() != nil {
+		return nil
+	}
+	return cmd.lookupFlag(strings.TrimLeft(name, "-"))
 }
 
 // SetScopeMatcher sets the scope matching function.
@@ -270,6 +564,55 @@ func (a *App) SetScopeMatcher(fn func(cmdScope, execScope Scope) error) {
 	a.lock.Lock()
 	defer a.lock.Unlock()
 	a.scopeMatcherFunc = fn
+	a.resetUsageLocked()
+}
+
+// SetScopeNames sets the scope names, which are rendered next to the action commands in usage.
+// NOTE:
+//  if no name matches the scope exactly, the names of the bitmask scopes it contains are joined.
+func (a *App) SetScopeNames(names map[Scope]string) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.scopeNames = make(map[Scope]string, len(names))
+	for k, v := range names {
+		a.scopeNames[k] = v
+	}
+	a.resetUsageLocked()
+}
+
+// ScopeName returns the name of the scope.
+// NOTE:
+//  returns empty string if it is not named.
+func (a *App) ScopeName(scope Scope) string {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	return a.scopeNameLocked(scope)
+}
+
+func (a *App) scopeNameLocked(scope Scope) string {
+	if len(a.scopeNames) == 0 {
+		return ""
+	}
+	if name, ok := a.scopeNames[scope]; ok {
+		return name
+	}
+	if scope == InitialScope {
+		return ""
+	}
+	scopes := make([]Scope, 0, len(a.scopeNames))
+	for s := range a.scopeNames {
+		if s != InitialScope && scope.Has(s) {
+			scopes = append(scopes, s)
+		}
+	}
+	sort.Slice(scopes, func(i, j int) bool {
+		return uint32(scopes[i]) < uint32(scopes[j])
+	})
+	names := make([]string, len(scopes))
+	for i, s := range scopes {
+		names[i] = a.scopeNames[s]
+	}
+	return strings.Join(names, "|")
 }
 
 // UsageText returns the usage text by by the executor scope.
@@ -278,6 +621,7 @@ func (a *App) SetScopeMatcher(fn func(cmdScope, execScope Scope) error) {
 func (a *App) UsageText(execScope ...Scope) string {
 	a.lock.RLock()
 	defer a.lock.RUnlock()
+	a.renderUsageIfDirtyLocked()
 	fn := a.scopeMatcherFunc
 	if len(execScope) == 0 || fn == nil {
 		return a.usageText
@@ -299,75 +643,187 @@ func (a *App) UsageText(execScope ...Scope) string {
 
 // defaultAppUsageTemplate is the text template for the Default help topic.
 var defaultAppUsageTemplate = template.Must(template.New("appUsage").
+	Funcs(template.FuncMap{"tr": translate}).
 	Parse(`{{if .AppName}}{{.AppName}}{{else}}{{.CmdName}}{{end}}{{if .Version}} - v{{.Version}}{{end}}{{if .Description}}
 
-{{.Description}}{{end}}
+{{.Description}}{{end}}{{if .UsageHeader}}
 
-USAGE:
-{{.Usage}}{{if len .Authors}}
+{{.UsageHeader}}{{end}}
 
-AUTHOR{{with $length := len .Authors}}{{if ne 1 $length}}S{{end}}{{end}}:
+{{tr "USAGE"}}:
+{{.Usage}}{{if len .Examples}}
+
+{{tr "EXAMPLES"}}:
+{{range $index, $example := .Examples}}{{if $index}}
+{{end}}  $ {{$example.Cmdline}}{{if $example.Description}}
+    {{$example.Description}}{{end}}{{end}}{{end}}{{if len .SeeAlso}}
+
+{{tr "SEE ALSO"}}:
+{{range $index, $line := .SeeAlso}}{{if $index}}
+{{end}}  {{$line}}{{end}}{{end}}{{if .UsageFooter}}
+
+{{.UsageFooter}}{{end}}{{if len .Authors}}
+
+{{if eq 1 (len .Authors)}}{{tr "AUTHOR"}}{{else}}{{tr "AUTHORS"}}{{end}}:
 {{range $index, $author := .Authors}}{{if $index}}
 {{end}}  {{$author}}{{end}}{{end}}{{if .Copyright}}
 
-COPYRIGHT:
+{{tr "COPYRIGHT"}}:
   {{.Copyright}}{{end}}
 `))
 
+// resetUsageLocked drops the usage cache of all executor scopes and marks all the commands stale,
+// which is used when the settings affecting the usage of all the commands change.
+func (a *App) resetUsageLocked() {
+	a.execScopeUsageTextsLock.Lock()
+	a.execScopeUsageTexts = nil
+	a.execScopeUsageTextsLock.Unlock()
+	a.Command.resetExecScopeUsageTexts()
+	a.usageLock.Lock()
+	a.Command.markUsageStaleLocked()
+	a.usageDirty = true
+	a.usageFrame.Store(nil)
+	a.usageLock.Unlock()
+}
+
+// updateUsageLocked marks the usage dirty, which is rendered on the first access,
+// so that building a large command tree does not render the usage repeatedly.
 func (a *App) updateUsageLocked() {
-	a.Command.updateUsageLocked()
-	text := goutil.Indent(a.Command.UsageText(), "  ")
-	data := map[string]interface{}{
-		"AppName":     a.appName,
-		"CmdName":     a.cmdName,
-		"Version":     a.version,
-		"Description": a.description,
-		"Authors":     a.authors,
-		"Usage":       text,
-		"Copyright":   a.copyright,
-	}
-	var buf bytes.Buffer
-	err := a.usageTemplate.Execute(&buf, data)
-	if err != nil {
-		panic(err)
-	}
-	s := buf.String()
-	for {
-		a.usageText = strings.Replace(s, "\n\n\n", "\n\n", -1)
-		if a.usageText == s {
-			return
-		}
-		s = a.usageText
+	a.usageLock.Lock()
+	a.usageDirty = true
+	a.usageFrame.Store(nil)
+	a.usageLock.Unlock()
+}
+
+// renderUsageIfDirtyLocked renders the usage of the app and all the commands if it is dirty.
+func (a *App) renderUsageIfDirtyLocked() {
+	a.usageLock.Lock()
+	defer a.usageLock.Unlock()
+	if !a.usageDirty {
+		return
 	}
+	a.Command.renderStaleUsageLocked()
+	a.usageText = a.renderUsageLocked(a.Command.usageText)
+	a.usageDirty = false
+	a.execScopeUsageTextsLock.Lock()
+	a.execScopeUsageTexts = nil
+	a.execScopeUsageTextsLock.Unlock()
 }
 
 func (a *App) createUsageLocked(execScope ...Scope) string {
-	cmdUsageText := a.Command.UsageText(execScope...)
+	return a.renderUsageLocked(a.Command.usageTextLocked(execScope...))
+}
+
+// usagePlaceholder the placeholder of the command usage in the cached usage frame
+const usagePlaceholder = "\x00flagx:usage\x00"
+
+func (a *App) renderUsageLocked(cmdUsageText string) string {
 	text := goutil.Indent(cmdUsageText, "  ")
-	data := map[string]interface{}{
-		"AppName":     a.appName,
-		"CmdName":     a.cmdName,
-		"Version":     a.version,
-		"Description": a.description,
-		"Authors":     a.authors,
-		"Usage":       text,
-		"Copyright":   a.copyright,
-	}
-	var buf bytes.Buffer
-	err := a.usageTemplate.Execute(&buf, data)
+	if text == "" || !usedPlainly(a.usageTemplate, "Usage") {
+		return a.executeUsageTemplateLocked(text)
+	}
+	frame := a.usageFrame.Load()
+	if frame == nil {
+		s := a.executeUsageTemplateLocked(usagePlaceholder)
+		frame = &s
+		a.usageFrame.Store(frame)
+	}
+	return squashBlankLines(strings.ReplaceAll(*frame, usagePlaceholder, text))
+}
+
+// executeUsageTemplateLocked executes the usage template with the command usage.
+func (a *App) executeUsageTemplateLocked(text string) string {
+	data := make(map[string]interface{}, len(a.usageData)+8)
+	for k, v := range a.usageData {
+		data[k] = v
+	}
+	data["AppName"] = a.appName
+	data["CmdName"] = a.cmdName
+	data["Version"] = a.version
+	data["Description"] = a.description
+	data["Authors"] = a.authors
+	data["Usage"] = text
+	data["Examples"] = a.Command.examples
+	data["SeeAlso"] = a.Command.seeAlsoLocked()
+	data["UsageHeader"] = a.Command.usageHeader
+	data["UsageFooter"] = a.Command.usageFooter
+	data["Copyright"] = a.copyright
+	var b strings.Builder
+	err := a.usageTemplate.Execute(&b, data)
 	if err != nil {
 		panic(err)
 	}
-	s := buf.String()
-	var usageText string
-	for {
-		usageText = strings.Replace(s, "\n\n\n", "\n\n", -1)
-		if usageText == s {
-			return usageText
+	if text == usagePlaceholder {
+		return b.String()
+	}
+	return squashBlankLines(b.String())
+}
+
+// usedPlainly reports whether the field of the data is only used as {{.Field}} in the template,
+// so that it can be substituted after the execution.
+func usedPlainly(t *template.Template, field string) bool {
+	if t == nil || t.Tree == nil || len(t.Templates()) > 1 {
+		return false
+	}
+	return nodeUsesPlainly(t.Tree.Root, field)
+}
+
+func nodeUsesPlainly(node parse.Node, field string) bool {
+	var branch *parse.BranchNode
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return true
+		}
+		for _, child := range n.Nodes {
+			if !nodeUsesPlainly(child, field) {
+				return false
+			}
+		}
+		return true
+	case *parse.ActionNode:
+		if len(n.Pipe.Decl) == 0 && len(n.Pipe.Cmds) == 1 && len(n.Pipe.Cmds[0].Args) == 1 {
+			if f, ok := n.Pipe.Cmds[0].Args[0].(*parse.FieldNode); ok && len(f.Ident) == 1 && f.Ident[0] == field {
+				return true
+			}
+		}
+		return !strings.Contains(n.Pipe.String(), field)
+	case *parse.IfNode:
+		branch = &n.BranchNode
+	case *parse.RangeNode:
+		branch = &n.BranchNode
+	case *parse.WithNode:
+		branch = &n.BranchNode
+	case *parse.TemplateNode:
+		return false
+	default:
+		return true
+	}
+	return !strings.Contains(branch.Pipe.String(), field) &&
+		nodeUsesPlainly(branch.List, field) &&
+		nodeUsesPlainly(branch.ElseList, field)
+}
+
+// squashBlankLines replaces the consecutive blank lines with one.
+func squashBlankLines(s string) string {
+	if !strings.Contains(s, "\n\n\n") {
+		return s
+	}
+	var b strings.Builder
+	b.Grow(len(s))
+	var newlines int
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\n' {
+			newlines++
+			if newlines > 2 {
+				continue
+			}
+		} else {
+			newlines = 0
 		}
-		s = usageText
+		b.WriteByte(s[i])
 	}
-	return usageText
+	return b.String()
 }
 
 // String makes Author comply to the Stringer interface, to allow an easy print in the templating process
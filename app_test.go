@@ -1,9 +1,22 @@
 package flagx_test
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"testing/fstest"
+	"text/template"
 	"time"
 
 	vd "github.com/bytedance/go-tagexpr/v2/validator"
@@ -286,3 +299,2091 @@ func TestScope(t *testing.T) {
 	t.Log("no scope:", app.UsageText())
 	t.Log("scope=0:", app.UsageText(flagx.Scope(0)))
 }
+
+func TestScopeUsageCache(t *testing.T) {
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.SetScopeMatcher(func(cmdScope, execScope flagx.Scope) error {
+		if cmdScope == execScope {
+			return nil
+		}
+		return fmt.Errorf("scopes are not equal: cmdScope=%d, execScope=%d", cmdScope, execScope)
+	})
+	a := app.AddSubcommand("a", "subcommand a")
+	a.SetAction(flagx.ActionFunc(Action3), flagx.Scope(1))
+	scope1 := app.UsageText(flagx.Scope(1))
+	assert.Contains(t, scope1, "$testapp a")
+	assert.NotContains(t, app.UsageText(flagx.Scope(2)), "$testapp a")
+
+	// the usage of scope 1 is kept when a command of scope 2 is added
+	app.AddSubaction("b", "subcommand b", flagx.ActionFunc(Action3), flagx.Scope(2))
+	assert.Equal(t, scope1, app.UsageText(flagx.Scope(1)))
+	assert.Contains(t, app.UsageText(flagx.Scope(2)), "$testapp b")
+	assert.NotContains(t, app.UsageText(flagx.Scope(2)), "$testapp a")
+
+	// the usage of scope 1 is rendered again when its command changes
+	a.AddExample("testapp a", "run a")
+	assert.Contains(t, app.UsageText(flagx.Scope(1)), "$ testapp a")
+	assert.NotContains(t, app.UsageText(flagx.Scope(2)), "$ testapp a")
+}
+
+func TestScopeBitmaskMatcher(t *testing.T) {
+	const (
+		user  = flagx.Scope(1 << 0)
+		admin = flagx.Scope(1 << 1)
+	)
+	assert.Equal(t, user, flagx.ScopeBit(0))
+	assert.Equal(t, admin, flagx.ScopeBit(1))
+	assert.Equal(t, user|admin, flagx.CombineScopes(user, admin))
+	assert.True(t, user.Union(admin).Has(admin))
+	assert.False(t, user.Has(admin))
+	assert.True(t, flagx.ScopeAll.Has(user|admin))
+
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.SetScopeMatcher(flagx.ScopeBitmaskMatcher)
+	app.AddSubaction("a", "subcommand a", flagx.ActionFunc(Action3))
+	app.AddSubaction("b", "subcommand b", flagx.ActionFunc(Action3), user)
+	app.AddSubaction("c", "subcommand c", flagx.ActionFunc(Action3), admin)
+
+	stat := app.Exec(context.TODO(), []string{"a"}, user)
+	assert.True(t, stat.OK())
+	stat = app.Exec(context.TODO(), []string{"b"}, user|admin)
+	assert.True(t, stat.OK())
+	stat = app.Exec(context.TODO(), []string{"c"}, user)
+	assert.False(t, stat.OK())
+	assert.Equal(t, flagx.StatusMismatchScope, stat.Code())
+	assert.NotContains(t, app.UsageText(admin), "$testapp b")
+	assert.Contains(t, app.UsageText(admin), "$testapp c")
+}
+
+func TestScopeNames(t *testing.T) {
+	const (
+		user  = flagx.Scope(1 << 0)
+		admin = flagx.Scope(1 << 1)
+	)
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.SetScopeMatcher(flagx.ScopeBitmaskMatcher)
+	app.AddSubaction("a", "subcommand a", flagx.ActionFunc(Action3))
+	app.AddSubaction("b", "subcommand b", flagx.ActionFunc(Action3), user)
+	app.AddSubaction("c", "subcommand c", flagx.ActionFunc(Action3), admin)
+	app.AddSubaction("d", "subcommand d", flagx.ActionFunc(Action3), user|admin)
+	app.SetScopeNames(map[flagx.Scope]string{user: "user", admin: "admin"})
+
+	assert.Equal(t, "admin", app.ScopeName(admin))
+	assert.Equal(t, "user|admin", app.ScopeName(user|admin))
+	assert.Equal(t, "", app.ScopeName(flagx.InitialScope))
+	assert.Equal(
+		t,
+		"$testapp a\n"+
+			"  subcommand a\n"+
+			"$testapp b [user]\n"+
+			"  subcommand b\n"+
+			"$testapp c [admin]\n"+
+			"  subcommand c\n"+
+			"$testapp d [user|admin]\n"+
+			"  subcommand d\n",
+		app.Command.UsageText(),
+	)
+	assert.Contains(t, app.UsageText(admin), "$testapp c [admin]")
+}
+
+func TestErrorHandler(t *testing.T) {
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.AddSubaction("a", "subcommand a", new(Action1))
+	var handled []*flagx.Status
+	var paths []string
+	app.SetErrorHandler(func(c *flagx.Context, stat *flagx.Status) {
+		handled = append(handled, stat)
+		paths = append(paths, c.CmdPathString())
+	})
+	stat := app.Exec(context.TODO(), []string{"a", "-id", "1"})
+	assert.True(t, stat.OK())
+	assert.Len(t, handled, 0)
+
+	stat = app.Exec(context.TODO(), []string{"x"})
+	assert.Equal(t, flagx.StatusNotFound, stat.Code())
+	stat = app.Exec(context.TODO(), []string{"a", "-id", "x"})
+	assert.Equal(t, flagx.StatusParseFailed, stat.Code())
+	assert.Len(t, handled, 2)
+	assert.Equal(t, flagx.StatusNotFound, handled[0].Code())
+	assert.Equal(t, flagx.StatusParseFailed, handled[1].Code())
+	assert.Equal(t, []string{"testapp", "testapp"}, paths)
+}
+
+func TestRecoverPanics(t *testing.T) {
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.AddSubaction("a", "subcommand a", flagx.ActionFunc(func(c *flagx.Context) {
+		panic("boom")
+	}))
+	app.AddSubaction("b", "subcommand b", flagx.ActionFunc(func(c *flagx.Context) {
+		c.ThrowStatus(100, "bad")
+	}))
+	stat := app.Exec(context.TODO(), []string{"a"})
+	assert.False(t, stat.OK())
+	assert.EqualError(t, stat.Cause(), "boom")
+
+	app.SetRecoverPanics(false)
+	stat = app.Exec(context.TODO(), []string{"b"})
+	assert.Equal(t, int32(100), stat.Code())
+	func() {
+		defer func() {
+			p, ok := recover().(*flagx.PanicError)
+			assert.True(t, ok)
+			assert.Equal(t, []string{"testapp", "a"}, p.CmdPath)
+			assert.Equal(t, "boom", p.Value)
+			assert.EqualError(t, p, `flagx: panic in command "testapp a": boom`)
+		}()
+		app.Exec(context.TODO(), []string{"a"})
+	}()
+}
+
+func TestTranslator(t *testing.T) {
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.SetAuthors([]flagx.Author{{Name: "henrylee2cn"}})
+	app.AddSubaction("a", "subcommand a", new(Action1))
+	app.SetTranslator(func(key string, args ...interface{}) string {
+		switch key {
+		case flagx.MsgUsage:
+			return "用法"
+		case flagx.MsgAuthor:
+			return "作者"
+		case flagx.MsgNotFound:
+			return fmt.Sprintf("未找到命令: %s", args...)
+		case flagx.MsgFlagNeedsArgument:
+			return fmt.Sprintf("参数缺少值: -%s", args...)
+		}
+		return fmt.Sprintf(key, args...)
+	})
+	assert.Contains(t, app.UsageText(), "用法:\n")
+	assert.Contains(t, app.UsageText(), "作者:\n  henrylee2cn")
+
+	stat := app.Exec(context.TODO(), []string{"x"})
+	assert.Equal(t, "未找到命令: testapp x", stat.Cause().Error())
+	stat = app.Exec(context.TODO(), []string{"a", "-id"})
+	assert.Equal(t, flagx.StatusParseFailed, stat.Code())
+	assert.Equal(t, "参数缺少值: -id", stat.Msg())
+	var needsArgument *flagx.ErrNeedsArgument
+	assert.True(t, errors.As(stat.Cause(), &needsArgument))
+	assert.Equal(t, "id", needsArgument.Name)
+}
+
+func TestUsageFuncsAndData(t *testing.T) {
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.SetUsageTemplate(template.Must(template.New("custom").
+		Funcs(template.FuncMap{"upper": strings.ToUpper}).
+		Parse(`{{upper .CmdName}} built at {{.BuildTime}}
+{{.Usage}}`)))
+	app.SetUsageFuncs(template.FuncMap{"upper": func(s string) string {
+		return "[" + strings.ToUpper(s) + "]"
+	}})
+	app.SetUsageData("BuildTime", "2020-02-13")
+	app.SetUsageData("CmdName", "ignored")
+	app.AddSubaction("a", "subcommand a", flagx.ActionFunc(Action3))
+	assert.Equal(t, "[TESTAPP] built at 2020-02-13\n  $testapp a\n    subcommand a\n", app.UsageText())
+}
+
+func TestColorMode(t *testing.T) {
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.AddSubaction("a", "subcommand a", new(Action1))
+	plain := app.UsageText()
+	assert.Equal(t, flagx.ColorNever, app.ColorMode())
+	assert.NotContains(t, plain, "\x1b[")
+
+	app.SetColorMode(flagx.ColorAlways)
+	assert.Equal(t,
+		"\x1b[1;36m$testapp a\x1b[0m\n"+
+			"  subcommand a\n"+
+			"  \x1b[32m-id\x1b[0m int\n"+
+			"    \tparam id\n"+
+			"  \x1b[32m?0\x1b[0m string\n"+
+			"    \tparam path\n",
+		app.Command.UsageText(),
+	)
+
+	app.SetColorMode(flagx.ColorAuto) // stdout is not a terminal in testing
+	assert.Equal(t, plain, app.UsageText())
+
+	// the configured output decides the coloring, not the standard output
+	t.Setenv("NO_COLOR", "")
+	t.Setenv("TERM", "xterm")
+	tty, err := os.Open(os.DevNull) // a character device
+	assert.NoError(t, err)
+	defer tty.Close()
+	stdout := os.Stdout
+	os.Stdout = tty
+	defer func() { os.Stdout = stdout }()
+	app.SetOutput(new(bytes.Buffer))
+	app.SetColorMode(flagx.ColorAuto)
+	assert.Equal(t, plain, app.UsageText())
+	app.SetOutput(tty)
+	assert.Contains(t, app.UsageText(), "\x1b[")
+}
+
+func ExampleApp_PrintUsage() {
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.SetVersion("v1.0.0")
+	app.SetUsePager(true) // stdout is not a terminal here, so no pager is used
+	app.AddSubaction("a", "subcommand a", flagx.ActionFunc(Action3))
+	app.PrintUsage()
+	// Output:
+	// testapp - v1.0.0
+	//
+	// USAGE:
+	//   $testapp a
+	//     subcommand a
+}
+
+func TestLazySubcommand(t *testing.T) {
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	var loaded int
+	lazy := app.AddLazySubcommand("b", "subcommand b", func() (*flagx.CommandSpec, error) {
+		loaded++
+		return &flagx.CommandSpec{
+			Filters: []flagx.Filter{flagx.FilterFunc(Filter2)},
+			Setup: func(b *flagx.Command) {
+				b.AddSubaction("c", "subcommand c", new(Action2))
+			},
+		}, nil
+	})
+	app.AddLazySubcommand("x", "subcommand x", func() (*flagx.CommandSpec, error) {
+		return nil, fmt.Errorf("cannot load x")
+	})
+	assert.Nil(t, app.LookupSubcommand("b", "c"))
+	assert.Equal(t, 0, loaded)
+
+	stat := app.Exec(context.TODO(), []string{"b", "c", "-name", "henry"})
+	assert.True(t, stat.OK())
+	stat = app.Exec(context.TODO(), []string{"b", "c"})
+	assert.True(t, stat.OK())
+	assert.Equal(t, 1, loaded)
+	assert.NoError(t, lazy.Load())
+	assert.NotNil(t, app.LookupSubcommand("b", "c"))
+
+	stat = app.Exec(context.TODO(), []string{"x"})
+	assert.Equal(t, flagx.StatusLoadFailed, stat.Code())
+	assert.EqualError(t, stat.Cause(), "cannot load x")
+}
+
+func TestLazySubcommandConcurrentLoad(t *testing.T) {
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	lazy := app.AddLazySubcommand("b", "subcommand b", func() (*flagx.CommandSpec, error) {
+		return &flagx.CommandSpec{
+			Setup: func(b *flagx.Command) {
+				b.AddSubaction("c", "subcommand c", new(Action2))
+			},
+		}, nil
+	})
+	app.Freeze()
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		assert.True(t, app.Exec(context.TODO(), []string{"b", "c"}).OK())
+	}()
+	go func() {
+		defer wg.Done()
+		// the frozen check reads the loading state concurrently
+		defer func() { recover() }()
+		lazy.SetUsageFooter("footer")
+	}()
+	wg.Wait()
+}
+
+func TestExternalCommands(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script plugin is not supported on windows")
+	}
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out.txt")
+	script := "#!/bin/sh\necho \"$@\" > " + out + "\necho plugin\n"
+	err := os.WriteFile(filepath.Join(dir, "testapp-hello"), []byte(script), 0755)
+	assert.NoError(t, err)
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.SetOutput(new(bytes.Buffer))
+	app.AddSubaction("a", "subcommand a", flagx.ActionFunc(Action3))
+	stat := app.Exec(context.TODO(), []string{"hello", "-x", "1"})
+	assert.Equal(t, flagx.StatusNotFound, stat.Code())
+
+	app.SetExternalCommands(true)
+	stat = app.Exec(context.TODO(), []string{"hello", "-x", "1"})
+	assert.True(t, stat.OK(), stat.String())
+	b, err := os.ReadFile(out)
+	assert.NoError(t, err)
+	assert.Equal(t, "-x 1\n", string(b))
+	result, err := app.ExecResult(context.TODO(), []string{"hello"})
+	assert.NoError(t, err)
+	assert.Equal(t, "plugin\n", result.Output)
+	stat = app.Exec(context.TODO(), []string{"world"})
+	assert.Equal(t, flagx.StatusNotFound, stat.Code())
+
+	app.SetAuthorizer(flagx.RoleAuthorizer(map[string]flagx.Scope{"viewer": flagx.Scope(1), "admin": flagx.ScopeAll}))
+	var external bool
+	stat = app.Exec(flagx.WithCaller(context.TODO(), roleUser{"viewer"}), []string{"hello"})
+	assert.Equal(t, flagx.StatusForbidden, stat.Code())
+	assert.True(t, app.Exec(flagx.WithCaller(context.TODO(), roleUser{"admin"}), []string{"hello"}).OK())
+	app.SetAuthorizer(flagx.AuthorizerFunc(func(c *flagx.Context) *flagx.Status {
+		external = c.IsExternal()
+		return nil
+	}))
+	assert.True(t, app.Exec(context.TODO(), []string{"hello"}).OK())
+	assert.True(t, external)
+	assert.True(t, app.Exec(context.TODO(), []string{"a"}).OK())
+	assert.False(t, external)
+}
+
+func TestPreRouter(t *testing.T) {
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	b := app.AddSubcommand("b", "subcommand b")
+	var args, rawArgs []string
+	var name string
+	b.AddSubaction("c", "subcommand c", flagx.ActionFunc(func(c *flagx.Context) {
+		args, rawArgs = c.Args(), c.RawArgs()
+		name, _ = flagx.LookupArgs(args[2:], "name")
+	}))
+	// alias expansion
+	app.AddPreRouter(func(_ context.Context, arguments []string) ([]string, error) {
+		if len(arguments) > 0 && arguments[0] == "bc" {
+			return append([]string{"b", "c"}, arguments[1:]...), nil
+		}
+		return arguments, nil
+	})
+	// legacy-flag rewriting
+	app.AddPreRouter(func(_ context.Context, arguments []string) ([]string, error) {
+		r := make([]string, len(arguments))
+		for i, arg := range arguments {
+			if arg == "-legacy" {
+				return nil, fmt.Errorf("unsupported flag: %s", arg)
+			}
+			r[i] = strings.Replace(arg, "-old-name=", "-name=", 1)
+		}
+		return r, nil
+	})
+	stat := app.Exec(context.TODO(), []string{"bc", "-old-name=henry"})
+	assert.True(t, stat.OK())
+	assert.Equal(t, []string{"b", "c", "-name=henry"}, args)
+	assert.Equal(t, []string{"bc", "-old-name=henry"}, rawArgs)
+	assert.Equal(t, "henry", name)
+
+	stat = app.Exec(context.TODO(), []string{"bc", "-legacy"})
+	assert.Equal(t, flagx.StatusBadArgs, stat.Code())
+	assert.EqualError(t, stat.Cause(), "unsupported flag: -legacy")
+}
+
+func TestFreeze(t *testing.T) {
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	b := app.AddSubcommand("b", "subcommand b")
+	b.AddSubaction("c", "subcommand c", new(Action2))
+	app.AddLazySubcommand("x", "subcommand x", func() (*flagx.CommandSpec, error) {
+		return &flagx.CommandSpec{Action: flagx.ActionFunc(Action3)}, nil
+	})
+	assert.False(t, app.Frozen())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			stat := app.Exec(context.TODO(), []string{"b", "c", "-name", strconv.Itoa(i)})
+			assert.True(t, stat.OK())
+			stat = app.Exec(context.TODO(), []string{"x"})
+			assert.True(t, stat.OK())
+			app.SetValidator(func(interface{}) error { return nil })
+			b.SetMeta("k", i)
+		}(i)
+	}
+	wg.Wait()
+	assert.True(t, app.Frozen())
+	assert.Panics(t, func() {
+		b.AddSubaction("d", "subcommand d", flagx.ActionFunc(Action3))
+	})
+	assert.Panics(t, func() {
+		app.AddFilter(flagx.FilterFunc(Filter2))
+	})
+}
+
+func TestRoutingAfterFreeze(t *testing.T) {
+	var count int32
+	counter := flagx.NamedFilter("count", flagx.FilterFunc(func(c *flagx.Context, next flagx.ActionFunc) {
+		atomic.AddInt32(&count, 1)
+		next(c)
+	}))
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.AddFilter(counter)
+	app.AddSubaction("a", "subcommand a", flagx.ActionFunc(Action3))
+	app.Freeze()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.True(t, app.Exec(context.TODO(), []string{"a"}).OK())
+			app.ReplaceFilterByName("count", counter)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(8), atomic.LoadInt32(&count))
+
+	app.RemoveFilterByName("count")
+	assert.True(t, app.Exec(context.TODO(), []string{"a"}).OK())
+	assert.Equal(t, int32(8), atomic.LoadInt32(&count))
+}
+
+type pooledAction struct {
+	N     int `flag:"n;def=1"`
+	calls int
+}
+
+var pooledResults []int
+
+func (a *pooledAction) Reset() {
+	a.calls = 0
+}
+
+func (a *pooledAction) Execute(c *flagx.Context) {
+	a.calls++
+	pooledResults = append(pooledResults, a.N*10+a.calls)
+}
+
+func TestResetter(t *testing.T) {
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.AddSubaction("a", "subcommand a", new(pooledAction))
+	pooledResults = nil
+	assert.True(t, app.Exec(context.TODO(), []string{"a", "-n", "5"}).OK())
+	assert.True(t, app.Exec(context.TODO(), []string{"a"}).OK())
+	assert.True(t, app.Exec(context.TODO(), []string{"a", "-n", "2"}).OK())
+	assert.Equal(t, []int{51, 11, 21}, pooledResults)
+}
+
+func TestStatusStack(t *testing.T) {
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.AddSubaction("a", "subcommand a", flagx.ActionFunc(func(c *flagx.Context) {
+		c.ThrowStatus(100, "expected")
+	}))
+	stat := app.Exec(context.TODO(), []string{"a"})
+	assert.Equal(t, int32(100), stat.Code())
+	assert.NotEmpty(t, stat.StackTrace())
+	stat = app.Exec(context.TODO(), []string{"b"})
+	assert.Equal(t, flagx.StatusNotFound, stat.Code())
+	assert.NotEmpty(t, stat.StackTrace())
+
+	app.SetStatusStack(false)
+	stat = app.Exec(context.TODO(), []string{"a"})
+	assert.Equal(t, int32(100), stat.Code())
+	assert.Empty(t, stat.StackTrace())
+	stat = app.Exec(context.TODO(), []string{"b"})
+	assert.Equal(t, flagx.StatusNotFound, stat.Code())
+	assert.Empty(t, stat.StackTrace())
+}
+
+type testTracer struct {
+	flagx.NopTracer
+	events []string
+}
+
+func (t *testTracer) RouteResolved(c *flagx.Context) {
+	t.events = append(t.events, "route:"+c.CmdPathString())
+}
+
+func (t *testTracer) FilterExit(c *flagx.Context, filter flagx.Filter, cost time.Duration, stat *flagx.Status) {
+	t.events = append(t.events, fmt.Sprintf("filter:%T:%d", filter, stat.Code()))
+}
+
+func (t *testTracer) ActionEnd(c *flagx.Context, action flagx.Action, cost time.Duration, stat *flagx.Status) {
+	t.events = append(t.events, fmt.Sprintf("action:%T:%d", action, stat.Code()))
+}
+
+func TestTracer(t *testing.T) {
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	tracer := new(testTracer)
+	app.SetTracer(tracer)
+	app.AddFilter(new(Filter1))
+	b := app.AddSubcommand("b", "subcommand b", flagx.FilterFunc(Filter2))
+	b.AddSubaction("c", "subcommand c", new(Action2))
+	b.AddSubaction("d", "subcommand d", flagx.ActionFunc(func(c *flagx.Context) {
+		c.ThrowStatus(100, "bad")
+	}))
+	stat := app.Exec(context.TODO(), []string{"-g=x", "false", "b", "c"})
+	assert.True(t, stat.OK())
+	stat = app.Exec(context.TODO(), []string{"-g=x", "false", "b", "d"})
+	assert.Equal(t, int32(100), stat.Code())
+	assert.Equal(t, []string{
+		"route:testapp b c",
+		"action:*flagx_test.Action2:0",
+		"filter:flagx.FilterFunc:0",
+		"filter:*flagx_test.Filter1:0",
+		"route:testapp b d",
+		"action:flagx.ActionFunc:100",
+		"filter:flagx.FilterFunc:100",
+		"filter:*flagx_test.Filter1:100",
+	}, tracer.events)
+}
+
+func TestContextLogger(t *testing.T) {
+	var buf bytes.Buffer
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.SetLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})))
+	var requestID string
+	app.AddSubaction("a", "subcommand a", flagx.ActionFunc(func(c *flagx.Context) {
+		requestID = c.RequestID()
+		c.Logger().Info("hello")
+	}))
+	stat := app.Exec(flagx.WithRequestID(context.TODO(), "req-1"), []string{"a"}, flagx.Scope(2))
+	assert.True(t, stat.OK())
+	assert.Equal(t, "req-1", requestID)
+	assert.Equal(t, "level=INFO msg=hello cmd=\"testapp a\" scope=2 request_id=req-1\n", buf.String())
+
+	stat = app.Exec(context.TODO(), []string{"a"})
+	assert.True(t, stat.OK())
+	assert.Len(t, requestID, 16)
+}
+
+type SudoAction struct {
+	Yes      bool   `flag:"y"`
+	Password string `flag:"password"`
+}
+
+func (a *SudoAction) Execute(c *flagx.Context) {}
+
+func TestAuditor(t *testing.T) {
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.AddSubaction("login", "subcommand login", flagx.ActionFunc(Action3))
+	app.AddSubaction("sudo", "subcommand sudo", new(SudoAction))
+	var records []*flagx.AuditRecord
+	app.SetAuditor(func(r *flagx.AuditRecord) {
+		records = append(records, r)
+	}, "password", "token")
+	stat := app.Exec(context.TODO(), []string{"login", "-user", "henry", "-password", "-123", "--token=abc", "--", "-password", "x"})
+	assert.True(t, stat.OK())
+	stat = app.Exec(context.TODO(), []string{"logout"})
+	assert.False(t, stat.OK())
+	assert.Len(t, records, 2)
+	assert.Equal(t, []string{"testapp", "login"}, records[0].CmdPath)
+	assert.Equal(t, []string{"login", "-user", "henry", "-password", flagx.SecretMask, "--token=" + flagx.SecretMask, "--", "-password", "x"}, records[0].Args)
+	assert.Equal(t, int32(0), records[0].Code)
+	assert.Equal(t, []string{"testapp"}, records[1].CmdPath)
+	assert.Equal(t, flagx.StatusNotFound, records[1].Code)
+
+	app.SetAuditor(func(r *flagx.AuditRecord) {
+		records = append(records, r)
+	}, "y", "password")
+	stat = app.Exec(context.TODO(), []string{"sudo", "-y", "-password", "-123"})
+	assert.True(t, stat.OK(), stat.String())
+	assert.Equal(t, []string{"sudo", "-y", "-password", flagx.SecretMask}, records[2].Args)
+
+	var buf bytes.Buffer
+	app.SetAuditor(flagx.NewAuditWriter(&buf), "-password")
+	app.Exec(flagx.WithRequestID(context.TODO(), "req-1"), []string{"login", "-password=123"})
+	assert.Contains(t, buf.String(), `"cmd_path":["testapp","login"],"args":["login","-password=******"],"code":0,"msg":"",`)
+	assert.Contains(t, buf.String(), `"request_id":"req-1"}`)
+}
+
+func TestWalk(t *testing.T) {
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.AddSubaction("a", "subcommand a", flagx.ActionFunc(Action3))
+	b := app.AddSubcommand("b", "subcommand b")
+	b.AddSubaction("d", "subcommand d", flagx.ActionFunc(Action3))
+	b.AddSubaction("c", "subcommand c", flagx.ActionFunc(Action3))
+	var paths []string
+	for _, cmd := range app.AllCommands() {
+		paths = append(paths, cmd.PathString())
+	}
+	assert.Equal(t, []string{"testapp a", "testapp b", "testapp b c", "testapp b d"}, paths)
+	assert.Len(t, b.AllCommands(), 2)
+
+	paths = paths[:0]
+	complete := app.Walk(func(cmd *flagx.Command) bool {
+		paths = append(paths, cmd.PathString())
+		return cmd.CmdName() != "b"
+	})
+	assert.False(t, complete)
+	assert.Equal(t, []string{"testapp", "testapp a", "testapp b"}, paths)
+
+	// the sorted subcommands are cached until the command is modified
+	subcommands := b.Subcommands()
+	assert.True(t, &subcommands[0] == &b.Subcommands()[0])
+	b.AddSubaction("a", "subcommand a", flagx.ActionFunc(Action3))
+	assert.Equal(t, "a", b.Subcommands()[0].CmdName())
+	assert.Equal(t, "c", subcommands[0].CmdName())
+}
+
+func TestLookupFlag(t *testing.T) {
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.AddFilter(new(Filter1))
+	b := app.AddSubcommand("b", "subcommand b", flagx.FilterFunc(Filter2))
+	b.AddSubaction("c", "subcommand c", new(Action2))
+	app.AddSubaction("a", "subcommand a", new(Action1))
+
+	f := app.LookupFlag([]string{"b", "c"}, "-name")
+	assert.NotNil(t, f)
+	assert.Equal(t, "param name", f.Usage)
+	f = app.LookupFlag([]string{"b", "c"}, "g")
+	assert.NotNil(t, f)
+	assert.Equal(t, "global param g", f.Usage)
+	f = app.LookupFlag([]string{"a"}, "?0")
+	assert.NotNil(t, f)
+	assert.Equal(t, "param path", f.Usage)
+	f = app.LookupFlag(nil, "?0")
+	assert.NotNil(t, f)
+	assert.Equal(t, "param view", f.Usage)
+	assert.Nil(t, app.LookupFlag([]string{"b"}, "name"))
+	assert.Nil(t, app.LookupFlag([]string{"x"}, "g"))
+}
+
+func TestRemoveAndReplaceFilter(t *testing.T) {
+	var trace []string
+	newFilter := func(name string) flagx.Filter {
+		return flagx.FilterFunc(func(c *flagx.Context, next flagx.ActionFunc) {
+			trace = append(trace, name)
+			next(c)
+		})
+	}
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.AddFilter(flagx.NamedFilter("auth", newFilter("auth")), flagx.NamedFilter("log", newFilter("log")), new(Filter1))
+	app.AddSubaction("a", "subcommand a", flagx.ActionFunc(func(c *flagx.Context) {}))
+	assert.Equal(t, []string{"auth", "log", "*flagx_test.Filter1"}, app.FilterNames())
+
+	stat := app.Exec(context.TODO(), []string{"-g=x", "false", "a"})
+	assert.True(t, stat.OK())
+	assert.Equal(t, []string{"auth", "log"}, trace)
+
+	// after the command tree is frozen
+	assert.True(t, app.RemoveFilterByName("auth"))
+	assert.False(t, app.RemoveFilterByName("auth"))
+	assert.True(t, app.ReplaceFilterByName("log", flagx.NamedFilter("log2", newFilter("log2"))))
+	app.RemoveFilter(1)
+	assert.Equal(t, []string{"log2"}, app.FilterNames())
+	assert.NotContains(t, app.UsageText(), "global param g")
+	assert.Panics(t, func() { app.RemoveFilter(1) })
+
+	trace = trace[:0]
+	stat = app.Exec(context.TODO(), []string{"a"})
+	assert.True(t, stat.OK())
+	assert.Equal(t, []string{"log2"}, trace)
+}
+
+type injectFilter struct {
+	Env string `flag:"env"`
+}
+
+func (f *injectFilter) Filter(c *flagx.Context, next flagx.ActionFunc) {
+	panic("unreachable")
+}
+
+func (f *injectFilter) FilterParsed(c *flagx.Context, action interface{}, next flagx.ActionFunc) {
+	if a, ok := action.(*injectedAction); ok {
+		a.Env = f.Env
+	}
+	next(c)
+}
+
+type injectedAction struct {
+	Name string `flag:"name"`
+	Env  string `flag:"-"`
+}
+
+var injectedResult string
+
+func (a *injectedAction) Execute(c *flagx.Context) {
+	injectedResult = a.Name + "@" + a.Env
+}
+
+func TestParsedFilter(t *testing.T) {
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	var audited []string
+	app.AddFilter(new(injectFilter), flagx.ParsedFilterFunc(func(c *flagx.Context, action interface{}, next flagx.ActionFunc) {
+		audited = append(audited, fmt.Sprintf("%+v", action))
+		next(c)
+	}))
+	app.AddSubaction("a", "subcommand a", new(injectedAction))
+	app.SetTracer(flagx.NopTracer{})
+	stat := app.Exec(context.TODO(), []string{"-env=prod", "a", "-name=henry"})
+	assert.True(t, stat.OK(), stat.String())
+	assert.Equal(t, []string{"&{Name:henry Env:prod}"}, audited)
+	assert.Equal(t, "henry@prod", injectedResult)
+}
+
+func TestContextValue(t *testing.T) {
+	type ctxKey string
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.AddFilter(flagx.FilterFunc(func(c *flagx.Context, next flagx.ActionFunc) {
+		c.SetValue(ctxKey("user"), "henry")
+		next(c)
+	}))
+	var user, trace interface{}
+	app.AddSubaction("a", "subcommand a", flagx.ActionFunc(func(c *flagx.Context) {
+		user = c.Value(ctxKey("user"))
+		trace = c.Value(ctxKey("trace"))
+	}))
+	ctx := context.WithValue(context.TODO(), ctxKey("trace"), "t-1")
+	stat := app.Exec(ctx, []string{"a"})
+	assert.True(t, stat.OK())
+	assert.Equal(t, "henry", user)
+	assert.Equal(t, "t-1", trace)
+	assert.Nil(t, ctx.Value(ctxKey("user")))
+}
+
+func TestCommandNotFound(t *testing.T) {
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	var notFound []string
+	app.SetNotFound(func(c *flagx.Context) {
+		notFound = append(notFound, "app:"+c.CmdPathString())
+	})
+	plugin := app.AddSubcommand("plugin", "plugin commands")
+	plugin.AddSubaction("list", "list plugins", flagx.ActionFunc(Action3))
+	plugin.SetNotFound(func(c *flagx.Context) {
+		notFound = append(notFound, "plugin:"+c.CmdPathString())
+	})
+	sub := plugin.AddSubcommand("sub", "sub plugin commands")
+	sub.AddSubaction("x", "subcommand x", flagx.ActionFunc(Action3))
+
+	assert.True(t, app.Exec(context.TODO(), []string{"foo"}).OK())
+	assert.True(t, app.Exec(context.TODO(), []string{"plugin", "foo"}).OK())
+	assert.True(t, app.Exec(context.TODO(), []string{"plugin", "sub", "foo"}).OK())
+	assert.Equal(t, []string{"app:testapp foo", "plugin:testapp plugin foo", "plugin:testapp plugin sub foo"}, notFound)
+}
+
+type ErrAction struct {
+	Name string `flag:"name"`
+}
+
+func (a *ErrAction) ExecuteE(c *flagx.Context) error {
+	if a.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	return nil
+}
+
+func TestErrorReturningAction(t *testing.T) {
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	var filtered bool
+	app.AddFilter(flagx.FilterErrFunc(func(c *flagx.Context, next flagx.ActionFunc) error {
+		filtered = true
+		next(c)
+		return nil
+	}))
+	app.AddSubaction("a", "subcommand a", flagx.WrapActionE(new(ErrAction)))
+	app.AddSubaction("b", "subcommand b", flagx.ActionErrFunc(func(c *flagx.Context) error {
+		return fmt.Errorf("b failed")
+	}))
+
+	stat := app.Exec(context.TODO(), []string{"a", "-name", "x"})
+	assert.True(t, stat.OK(), stat.String())
+	assert.True(t, filtered)
+
+	stat = app.Exec(context.TODO(), []string{"a"})
+	assert.Equal(t, flagx.StatusExecuteFailed, stat.Code())
+	assert.Equal(t, "name is required", stat.Msg())
+
+	stat = app.Exec(context.TODO(), []string{"b"})
+	assert.Equal(t, flagx.StatusExecuteFailed, stat.Code())
+	assert.Equal(t, "b failed", stat.Msg())
+}
+
+func TestCommandExamples(t *testing.T) {
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.AddExample("testapp a -name x", "run a with name x")
+	sub := app.AddSubcommand("a", "subcommand a")
+	sub.SetAction(flagx.WrapActionE(new(ErrAction)))
+	sub.AddExample("testapp a -name y", "")
+	assert.Equal(t, []flagx.Example{{Cmdline: "testapp a -name y"}}, sub.Examples())
+
+	usage := app.UsageText()
+	assert.Contains(t, usage, "EXAMPLES:\n  $ testapp a -name x\n    run a with name x\n")
+	assert.Contains(t, sub.UsageText(), "  EXAMPLES:\n    $ testapp a -name y\n")
+	assert.Equal(t, 1, strings.Count(usage, "$ testapp a -name x"))
+}
+
+func TestConfigFlag(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(filename, []byte(`{"g":true,"c":{"name":"from-c"},"name":"global"}`), 0644)
+	assert.NoError(t, err)
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.SetConfigFlag("config", flagx.JSONDecoder)
+	var g bool
+	app.AddFilter(flagx.FilterFunc(func(c *flagx.Context, next flagx.ActionFunc) { next(c) }))
+	app.AddFilter(new(ConfigFilter))
+	var names []string
+	app.AddSubaction("a", "subcommand a", flagx.ActionFunc(func(c *flagx.Context) {
+		g = true
+	}))
+	app.AddSubaction("b", "subcommand b", flagx.WrapActionE(flagx.ActionErrFunc(func(c *flagx.Context) error {
+		return nil
+	})))
+	app.AddSubcommand("c", "subcommand c").SetAction(new(ConfigAction))
+	app.AddSubcommand("d", "subcommand d").AddSubcommand("a", "subcommand d a").SetAction(new(ConfigAction))
+	app.AddFilter(flagx.FilterFunc(func(c *flagx.Context, next flagx.ActionFunc) {
+		next(c)
+		if v := c.Value("name"); v != nil {
+			names = append(names, v.(string))
+		}
+	}))
+	assert.Contains(t, app.UsageText(), "-config file\n    \tload the flag values from the config file")
+
+	stat := app.Exec(context.TODO(), []string{"-config", filename, "a"})
+	assert.True(t, stat.OK(), stat.String())
+	assert.True(t, g)
+	stat = app.Exec(context.TODO(), []string{"--config=" + filename, "c"})
+	assert.True(t, stat.OK(), stat.String())
+	stat = app.Exec(context.TODO(), []string{"-config", filename, "c", "-name", "cli"})
+	assert.True(t, stat.OK(), stat.String())
+	stat = app.Exec(context.TODO(), []string{"c"})
+	assert.True(t, stat.OK(), stat.String())
+	stat = app.Exec(context.TODO(), []string{"-config", filename, "d", "a"})
+	assert.True(t, stat.OK(), stat.String())
+	assert.Equal(t, []string{"from-c:true", "cli:true", "default:false", "global:true"}, names)
+
+	stat = app.Exec(context.TODO(), []string{"-config", "not-exist.json", "c"})
+	assert.Equal(t, flagx.StatusConfigFailed, stat.Code())
+}
+
+var errDefine error
+
+type definerAction struct{}
+
+func (a *definerAction) DefineFlags(fs *flagx.FlagSet) error { return errDefine }
+
+func (a *definerAction) Execute(c *flagx.Context) {}
+
+func TestDefineFlagsError(t *testing.T) {
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.AddSubaction("a", "subcommand a", new(definerAction))
+	assert.True(t, app.Exec(context.TODO(), []string{"a"}).OK())
+	errDefine = errors.New("cannot define")
+	defer func() { errDefine = nil }()
+	stat := app.Exec(context.TODO(), []string{"a"})
+	assert.Equal(t, flagx.StatusParseFailed, stat.Code())
+	assert.EqualError(t, stat.Cause(), "cannot define")
+}
+
+func TestConfigFlagLeading(t *testing.T) {
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.SetConfigFlag("config", flagx.JSONDecoder)
+	app.AddFilter(new(leadingFilter))
+	app.AddSubaction("sub", "subcommand sub", new(leadingAction))
+	result, err := app.ExecResult(context.TODO(), []string{"-name", "-config", "sub", "-config", "x"})
+	assert.NoError(t, err)
+	assert.True(t, result.Status.OK(), result.Status.String())
+	assert.Equal(t, "-config:x", result.Output)
+	result, err = app.ExecResult(context.TODO(), []string{"sub", "-config=y"})
+	assert.NoError(t, err)
+	assert.True(t, result.Status.OK(), result.Status.String())
+	assert.Equal(t, ":y", result.Output)
+}
+
+type leadingFilter struct {
+	Name string `flag:"name"`
+}
+
+func (f *leadingFilter) Filter(c *flagx.Context, next flagx.ActionFunc) {
+	c.SetValue("name", f.Name)
+	next(c)
+}
+
+type leadingAction struct {
+	Config string `flag:"config"`
+}
+
+func (a *leadingAction) Execute(c *flagx.Context) {
+	fmt.Fprintf(c.Output(), "%s:%s", c.Value("name"), a.Config)
+}
+
+type ConfigFilter struct {
+	G bool `flag:"g"`
+}
+
+func (f *ConfigFilter) Filter(c *flagx.Context, next flagx.ActionFunc) {
+	c.SetValue("g", f.G)
+	next(c)
+}
+
+type ConfigAction struct {
+	Name string `flag:"name;def=default"`
+}
+
+func (a *ConfigAction) Execute(c *flagx.Context) {
+	c.SetValue("name", fmt.Sprintf("%s:%v", a.Name, c.Value("g")))
+}
+
+func TestConfigSearchPaths(t *testing.T) {
+	dir := t.TempDir()
+	err := os.MkdirAll(filepath.Join(dir, "xdg", "testapp"), 0755)
+	assert.NoError(t, err)
+	err = os.WriteFile(filepath.Join(dir, "xdg", "testapp", "testapp.toml"), []byte("name = \"discovered\"\n"), 0644)
+	assert.NoError(t, err)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "xdg"))
+
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.SetConfigFlag("config", nil)
+	app.SetConfigSearchPaths(filepath.Join(dir, "not-exist"), "$XDG_CONFIG_HOME/{name}")
+	var name string
+	app.AddSubcommand("d", "subcommand d").SetAction(new(ConfigAction))
+	app.AddFilter(flagx.FilterFunc(func(c *flagx.Context, next flagx.ActionFunc) {
+		next(c)
+		if v := c.Value("name"); v != nil {
+			name = v.(string)
+		}
+	}))
+	stat := app.Exec(context.TODO(), []string{"d"})
+	assert.True(t, stat.OK(), stat.String())
+	assert.Equal(t, "discovered:<nil>", name)
+
+	filename := filepath.Join(dir, "app.ini")
+	assert.NoError(t, os.WriteFile(filename, []byte("name = flag\n"), 0644))
+	stat = app.Exec(context.TODO(), []string{"-config", filename, "d"})
+	assert.True(t, stat.OK(), stat.String())
+	assert.Equal(t, "flag:<nil>", name)
+
+	filename = filepath.Join(dir, "app.yaml")
+	assert.NoError(t, os.WriteFile(filename, []byte("name: flag\n"), 0644))
+	stat = app.Exec(context.TODO(), []string{"-config", filename, "d"})
+	assert.Equal(t, flagx.StatusConfigFailed, stat.Code())
+}
+
+func TestConfigCommand(t *testing.T) {
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.SetConfigFlag("config", nil)
+	app.AddFilter(new(ConfigFilter))
+	app.AddSubcommand("c", "subcommand c").SetAction(new(ConfigAction))
+	app.AddConfigCommand()
+
+	var buf bytes.Buffer
+	assert.NoError(t, app.WriteConfigSkeleton(&buf, "toml"))
+	assert.Equal(t, "# The config file of testapp, generated by `testapp config init`.\n"+
+		"\n"+
+		"g = false\n"+
+		"\n"+
+		"[c]\n"+
+		"\n"+
+		"name = \"default\"\n", buf.String())
+	buf.Reset()
+	assert.NoError(t, app.WriteConfigSkeleton(&buf, "json"))
+	assert.Equal(t, "{\n  \"c\": {\n    \"name\": \"default\"\n  },\n  \"g\": false\n}\n", buf.String())
+	assert.EqualError(t, app.WriteConfigSkeleton(&buf, "yaml"), "unknown config file format: yaml")
+
+	filename := filepath.Join(t.TempDir(), "app.ini")
+	stat := app.Exec(context.TODO(), []string{"config", "init", "-o", filename})
+	assert.True(t, stat.OK(), stat.String())
+	b, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), "[c]\n\nname = default\n")
+	stat = app.Exec(context.TODO(), []string{"config", "init", "-o", filename})
+	assert.Equal(t, flagx.StatusConfigFailed, stat.Code())
+	stat = app.Exec(context.TODO(), []string{"config", "init", "-o", filename, "-force", "-format", "toml"})
+	assert.True(t, stat.OK(), stat.String())
+	stat = app.Exec(context.TODO(), []string{"-config", filename, "c"})
+	assert.True(t, stat.OK(), stat.String())
+}
+
+func TestEnvPrefix(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "app.json")
+	err := os.WriteFile(filename, []byte(`{"g":false,"c":{"name":"from-config"}}`), 0644)
+	assert.NoError(t, err)
+	t.Setenv("TESTAPP_G", "true")
+	t.Setenv("TESTAPP_C_NAME", "from-env")
+
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.SetConfigFlag("config", nil)
+	app.SetEnvPrefix("TESTAPP_")
+	assert.Equal(t, "TESTAPP", app.EnvPrefix())
+	app.AddFilter(new(ConfigFilter))
+	var name string
+	app.AddFilter(flagx.FilterFunc(func(c *flagx.Context, next flagx.ActionFunc) {
+		next(c)
+		name, _ = c.Value("name").(string)
+	}))
+	app.AddSubcommand("c", "subcommand c").SetAction(new(ConfigAction))
+	assert.Contains(t, app.UsageText(), "-g\t [$TESTAPP_G]\n")
+	assert.Contains(t, app.UsageText(), "-name string\n      \t [$TESTAPP_C_NAME] (default default)\n")
+	assert.NotContains(t, app.UsageText(), "TESTAPP_CONFIG")
+
+	stat := app.Exec(context.TODO(), []string{"-config", filename, "c"})
+	assert.True(t, stat.OK(), stat.String())
+	assert.Equal(t, "from-env:true", name)
+	stat = app.Exec(context.TODO(), []string{"-config", filename, "c", "-name", "cli"})
+	assert.True(t, stat.OK(), stat.String())
+	assert.Equal(t, "cli:true", name)
+
+	t.Setenv("TESTAPP_G", "yes")
+	stat = app.Exec(context.TODO(), []string{"c"})
+	assert.Equal(t, flagx.StatusParseFailed, stat.Code())
+}
+
+type mapRemoteSource map[string]string
+
+func (m mapRemoteSource) Get(key string) (string, bool) {
+	v, ok := m[key]
+	return v, ok
+}
+
+type slowRemoteSource struct{}
+
+func (slowRemoteSource) Get(key string) (string, bool) {
+	return "", false
+}
+
+func (slowRemoteSource) GetContext(ctx context.Context, key string) (string, bool, error) {
+	<-ctx.Done()
+	return "", false, ctx.Err()
+}
+
+func TestRemoteSource(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "app.json")
+	err := os.WriteFile(filename, []byte(`{"c":{"name":"from-config"}}`), 0644)
+	assert.NoError(t, err)
+
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.SetConfigFlag("config", nil)
+	app.AddFilter(new(ConfigFilter))
+	var name string
+	app.AddFilter(flagx.FilterFunc(func(c *flagx.Context, next flagx.ActionFunc) {
+		next(c)
+		name, _ = c.Value("name").(string)
+	}))
+	app.AddSubcommand("c", "subcommand c").SetAction(new(ConfigAction))
+	app.SetRemoteSource(mapRemoteSource{"g": "true", "c.name": "from-remote"}, time.Second, flagx.RemoteFail)
+
+	stat := app.Exec(context.TODO(), []string{"-config", filename, "c"})
+	assert.True(t, stat.OK(), stat.String())
+	assert.Equal(t, "from-remote:true", name)
+	stat = app.Exec(context.TODO(), []string{"c", "-name", "cli"})
+	assert.True(t, stat.OK(), stat.String())
+	assert.Equal(t, "cli:true", name)
+
+	app.SetRemoteSource(slowRemoteSource{}, time.Millisecond, flagx.RemoteFallback)
+	stat = app.Exec(context.TODO(), []string{"-config", filename, "c"})
+	assert.True(t, stat.OK(), stat.String())
+	assert.Equal(t, "from-config:false", name)
+	app.SetRemoteSource(slowRemoteSource{}, time.Millisecond, flagx.RemoteFail)
+	stat = app.Exec(context.TODO(), []string{"-config", filename, "c"})
+	assert.Equal(t, flagx.StatusConfigFailed, stat.Code())
+	assert.Contains(t, stat.Msg(), "context deadline exceeded")
+}
+
+type ReloadAction struct {
+	Name  string   `flag:"name;def=default"`
+	Level string   `flag:"level;def=info"`
+	Tags  []string `flag:"tags"`
+}
+
+func (a *ReloadAction) Execute(c *flagx.Context) {
+	started := c.Value("started").(chan struct{})
+	reloaded := c.Value("reloaded").(chan []string)
+	close(started)
+	changed := <-reloaded
+	c.SetValue("result", fmt.Sprintf("%s:%s:%v:%v", a.Name, a.Level, a.Tags, changed))
+}
+
+func TestReload(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "app.json")
+	err := os.WriteFile(filename, []byte(`{"r":{"name":"v1","level":"debug","tags":["a","b"]}}`), 0644)
+	assert.NoError(t, err)
+
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.SetConfigFlag("config", nil)
+	stop := app.EnableReload()
+	defer stop()
+	started, reloaded := make(chan struct{}), make(chan []string, 1)
+	var result string
+	app.AddFilter(flagx.FilterFunc(func(c *flagx.Context, next flagx.ActionFunc) {
+		c.SetValue("started", started)
+		c.SetValue("reloaded", reloaded)
+		next(c)
+		result, _ = c.Value("result").(string)
+	}))
+	app.AddSubcommand("r", "subcommand r").SetAction(new(ReloadAction))
+	app.OnReload(func(c *flagx.Context, changed []string) {
+		reloaded <- changed
+	})
+
+	done := make(chan *flagx.Status)
+	go func() {
+		done <- app.Exec(context.TODO(), []string{"-config", filename, "r", "-level", "warn"})
+	}()
+	<-started
+	err = os.WriteFile(filename, []byte(`{"r":{"name":"v2","level":"error","tags":["c"]}}`), 0644)
+	assert.NoError(t, err)
+	app.Reload()
+	stat := <-done
+	assert.True(t, stat.OK(), stat.String())
+	assert.Equal(t, "v2:warn:[c]:[name tags]", result)
+}
+
+func TestReloadWithEnv(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "app.json")
+	err := os.WriteFile(filename, []byte(`{"r":{"name":"v1","level":"debug","tags":["a","b"]}}`), 0644)
+	assert.NoError(t, err)
+	// the process environment is not used by the execution with the injected one
+	t.Setenv("TESTAPP_R_LEVEL", "fatal")
+
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.SetConfigFlag("config", nil)
+	app.SetEnvPrefix("TESTAPP")
+	stop := app.EnableReload()
+	defer stop()
+	started, reloaded := make(chan struct{}), make(chan []string, 1)
+	var result string
+	app.AddFilter(flagx.FilterFunc(func(c *flagx.Context, next flagx.ActionFunc) {
+		c.SetValue("started", started)
+		c.SetValue("reloaded", reloaded)
+		next(c)
+		result, _ = c.Value("result").(string)
+	}))
+	app.AddSubcommand("r", "subcommand r").SetAction(new(ReloadAction))
+	app.OnReload(func(c *flagx.Context, changed []string) {
+		reloaded <- changed
+	})
+
+	done := make(chan *flagx.Status)
+	ctx := flagx.WithEnv(context.TODO(), map[string]string{"TESTAPP_R_NAME": "injected"})
+	go func() {
+		done <- app.Exec(ctx, []string{"-config", filename, "r"})
+	}()
+	<-started
+	err = os.WriteFile(filename, []byte(`{"r":{"name":"v2","level":"error","tags":["c"]}}`), 0644)
+	assert.NoError(t, err)
+	app.Reload()
+	stat := <-done
+	assert.True(t, stat.OK(), stat.String())
+	assert.Equal(t, "injected:error:[c]:[level tags]", result)
+}
+
+func TestContextSnapshot(t *testing.T) {
+	t.Setenv("TESTAPP_G", "true")
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.SetEnvPrefix("TESTAPP")
+	app.AddFilter(new(ConfigFilter))
+	var snapshot map[string]flagx.ResolvedValue
+	var buf bytes.Buffer
+	app.AddSubaction("c", "subcommand c", flagx.ActionFunc(func(c *flagx.Context) {
+		snapshot = c.Snapshot()
+		c.PrintEffective(&buf)
+	}))
+	stat := app.Exec(context.TODO(), []string{"c"})
+	assert.True(t, stat.OK(), stat.String())
+	assert.Equal(t, map[string]flagx.ResolvedValue{
+		"g": {Value: "true", Source: flagx.SourceEnv, Raw: "true", From: "TESTAPP_G"},
+	}, snapshot)
+	assert.Equal(t, "  -g=true\t(from env TESTAPP_G)\n", buf.String())
+}
+
+type SecretAction struct {
+	Token    string `flag:"token;secret;usage=the token; a vault reference is allowed"`
+	Password string `flag:"password;def=vault:kv/app#password;secret"`
+	Name     string `flag:"name"`
+}
+
+func (a *SecretAction) Execute(c *flagx.Context) {
+	c.SetValue("result", a.Token+","+a.Password+","+a.Name)
+}
+
+func TestSecretResolver(t *testing.T) {
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.SetSecretResolver(flagx.SecretResolverFunc(func(ref string) (string, error) {
+		if !strings.HasPrefix(ref, "vault:") {
+			return ref, nil
+		}
+		if ref == "vault:kv/app#fail" {
+			return "", fmt.Errorf("permission denied")
+		}
+		return "secret-" + ref[strings.IndexByte(ref, '#')+1:], nil
+	}))
+	var result string
+	app.AddFilter(flagx.FilterFunc(func(c *flagx.Context, next flagx.ActionFunc) {
+		next(c)
+		result, _ = c.Value("result").(string)
+	}))
+	app.AddSubcommand("s", "subcommand s").SetAction(new(SecretAction))
+	assert.Contains(t, app.UsageText(), "the token; a vault reference is allowed")
+
+	stat := app.Exec(context.TODO(), []string{"s", "-token", "vault:kv/app#token", "-name", "vault:kv/app#name"})
+	assert.True(t, stat.OK(), stat.String())
+	assert.Equal(t, "secret-token,secret-password,vault:kv/app#name", result)
+	stat = app.Exec(context.TODO(), []string{"s", "-token", "plain"})
+	assert.True(t, stat.OK(), stat.String())
+	assert.Equal(t, "plain,secret-password,", result)
+	stat = app.Exec(context.TODO(), []string{"s", "-token", "vault:kv/app#fail"})
+	assert.Equal(t, flagx.StatusConfigFailed, stat.Code())
+	assert.Equal(t, "resolve secret flag -token: permission denied", stat.Msg())
+}
+
+type CommandLineFilter struct {
+	Verbose bool   `flag:"v"`
+	Region  string `flag:"region;def=cn"`
+}
+
+func (f *CommandLineFilter) Filter(c *flagx.Context, next flagx.ActionFunc) {
+	next(c)
+}
+
+type CommandLineAction struct {
+	ID    int    `flag:"id"`
+	Token string `flag:"token;secret"`
+	Debug bool   `flag:"debug"`
+	Src   string `flag:"?0"`
+	Dst   string `flag:"?1"`
+}
+
+func (a *CommandLineAction) Execute(c *flagx.Context) {
+	c.SetValue("cmdline", c.CommandLine())
+}
+
+func TestCommandLine(t *testing.T) {
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.AddFilter(new(CommandLineFilter))
+	var cmdline []string
+	app.AddFilter(flagx.FilterFunc(func(c *flagx.Context, next flagx.ActionFunc) {
+		next(c)
+		cmdline, _ = c.Value("cmdline").([]string)
+	}))
+	app.AddSubcommand("sub", "subcommand sub").AddSubaction("run", "subcommand run", new(CommandLineAction))
+	stat := app.Exec(context.TODO(), []string{"-v", "sub", "run", "-token", "abc", "-debug", "-id", "0x10", "a"})
+	assert.True(t, stat.OK(), stat.String())
+	assert.Equal(t, []string{"testapp", "-v", "sub", "run", "-debug", "-id=16", "-token=******", "a"}, cmdline)
+}
+
+type ForwardAction struct {
+	ID    int    `flag:"id"`
+	Token string `flag:"token;secret"`
+	Debug bool   `flag:"debug"`
+}
+
+func (a *ForwardAction) Execute(c *flagx.Context) {
+	c.SetValue("all", c.ForwardArgs(nil))
+	c.SetValue("selected", c.ForwardArgs(func(f *flagx.Flag) bool { return f.Name != "v" }))
+}
+
+func TestForwardArgs(t *testing.T) {
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.AddFilter(new(CommandLineFilter))
+	var all, selected []string
+	app.AddFilter(flagx.FilterFunc(func(c *flagx.Context, next flagx.ActionFunc) {
+		next(c)
+		all, _ = c.Value("all").([]string)
+		selected, _ = c.Value("selected").([]string)
+	}))
+	app.AddSubaction("run", "subcommand run", new(ForwardAction))
+	stat := app.Exec(context.TODO(), []string{"-v", "run", "-token", "abc", "-debug", "-id", "0x10", "--", "ps", "-a"})
+	assert.True(t, stat.OK(), stat.String())
+	assert.Equal(t, []string{"-v", "-debug", "-id=16", "-token=******", "ps", "-a"}, all)
+	assert.Equal(t, []string{"-debug", "-id=16", "-token=******", "ps", "-a"}, selected)
+}
+
+type SchemaAction struct {
+	ID      int           `flag:"id;def=1;usage=the id"`
+	Token   string        `flag:"token;secret"`
+	Timeout time.Duration `flag:"timeout;def=1m"`
+	Src     string        `flag:"?0;usage=the source"`
+}
+
+func (a *SchemaAction) Execute(c *flagx.Context) {}
+
+func TestJSONSchema(t *testing.T) {
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.AddFilter(new(CommandLineFilter))
+	app.AddSubaction("run", "run it", new(SchemaAction))
+	schemas := app.JSONSchemas()
+	assert.Len(t, schemas, 2)
+	assert.Equal(t, map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"v":      map[string]interface{}{"type": "boolean"},
+			"region": map[string]interface{}{"type": "string", "default": "cn"},
+		},
+	}, map[string]interface{}{"type": schemas["testapp"]["type"], "properties": schemas["testapp"]["properties"]})
+	assert.Equal(t, map[string]interface{}{
+		"$schema":     "https://json-schema.org/draft/2020-12/schema",
+		"title":       "testapp run",
+		"description": "run it",
+		"type":        "object",
+		"properties": map[string]interface{}{
+			"id":      map[string]interface{}{"type": "integer", "description": "the id", "default": int64(1)},
+			"token":   map[string]interface{}{"type": "string", "format": "password", "writeOnly": true},
+			"timeout": map[string]interface{}{"type": "string", "format": "duration", "default": "1m0s"},
+			"?0":      map[string]interface{}{"type": "string", "description": "the source", "x-nonflag": true},
+		},
+	}, schemas["testapp run"])
+}
+
+func TestSetFS(t *testing.T) {
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.SetConfigFlag("config", nil)
+	app.SetConfigSearchPaths("/etc/{name}")
+	app.SetFS(fstest.MapFS{
+		"etc/testapp/testapp.json": {Data: []byte(`{"name":"embedded"}`)},
+		"conf/app.ini":             {Data: []byte("name = flag\n")},
+	})
+	var name string
+	app.AddSubcommand("d", "subcommand d").SetAction(new(ConfigAction))
+	app.AddFilter(flagx.FilterFunc(func(c *flagx.Context, next flagx.ActionFunc) {
+		next(c)
+		name, _ = c.Value("name").(string)
+	}))
+	stat := app.Exec(context.TODO(), []string{"d"})
+	assert.True(t, stat.OK(), stat.String())
+	assert.Equal(t, "embedded:<nil>", name)
+	stat = app.Exec(context.TODO(), []string{"-config", "conf/app.ini", "d"})
+	assert.True(t, stat.OK(), stat.String())
+	assert.Equal(t, "flag:<nil>", name)
+	stat = app.Exec(context.TODO(), []string{"-config", "conf/not-exist.ini", "d"})
+	assert.Equal(t, flagx.StatusConfigFailed, stat.Code())
+
+	fs := flagx.NewFlagSet("test", flagx.ContinueOnError)
+	x := fs.String("x", "", "")
+	fs.SetFS(fstest.MapFS{"config.json": {Data: []byte(`{"x":"embedded"}`)}})
+	assert.NoError(t, fs.BindConfigFile("/config.json", flagx.JSONDecoder))
+	assert.NoError(t, fs.Parse(nil))
+	assert.Equal(t, "embedded", *x)
+}
+
+func TestAppSetDiagnostics(t *testing.T) {
+	var buf bytes.Buffer
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.SetDiagnostics(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})))
+	app.AddFilter(new(CommandLineFilter))
+	app.AddSubaction("run", "run it", new(SchemaAction))
+	stat := app.Exec(context.TODO(), []string{"-region", "us", "run", "-id", "2", "-unknown", "x"})
+	assert.True(t, stat.OK(), stat.String())
+	assert.Equal(t, `level=WARN msg="flagx: undefined flag ignored" cmd="testapp run" flag=unknown flagset=run`+"\n", buf.String())
+}
+
+func TestLazyUsage(t *testing.T) {
+	var renders int
+	app := flagx.NewApp()
+	app.SetUsageTemplate(template.Must(template.New("usage").Funcs(template.FuncMap{"count": func() string {
+		renders++
+		return ""
+	}}).Parse("{{count}}{{.CmdName}}\n{{.Usage}}")))
+	app.SetCmdName("testapp")
+	for i := 0; i < 10; i++ {
+		app.AddSubaction("a"+strconv.Itoa(i), "subcommand", new(SchemaAction))
+	}
+	assert.Equal(t, 0, renders)
+	assert.Contains(t, app.UsageText(), "$testapp a9")
+	assert.Contains(t, app.LookupSubcommand("a1").UsageText(), "$testapp a1")
+	assert.Equal(t, 1, renders)
+	app.SetName("app")
+	assert.Equal(t, 1, renders)
+	app.UsageText()
+	assert.Equal(t, 2, renders)
+
+	// the rendered template is reused when only the commands change
+	app.AddSubaction("b", "subcommand", new(SchemaAction), flagx.Scope(1))
+	assert.Contains(t, app.UsageText(), "$testapp b")
+	assert.Equal(t, 2, renders)
+	app.SetScopeMatcher(func(cmdScope, execScope flagx.Scope) error {
+		if cmdScope == execScope {
+			return nil
+		}
+		return fmt.Errorf("scopes are not equal: cmdScope=%d, execScope=%d", cmdScope, execScope)
+	})
+	assert.Contains(t, app.UsageText(flagx.Scope(1)), "$testapp b")
+	assert.NotContains(t, app.UsageText(flagx.Scope(0)), "$testapp b")
+	assert.Equal(t, 3, renders)
+}
+
+func newBenchmarkApp(n int) *flagx.App {
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.AddFilter(new(Filter1))
+	for i := 0; i < n; i++ {
+		group := app.AddSubcommand("g"+strconv.Itoa(i), "group "+strconv.Itoa(i))
+		group.AddSubaction("a", "action a", new(Action1), flagx.Scope(i%4))
+	}
+	return app
+}
+
+func BenchmarkUsageText(b *testing.B) {
+	app := newBenchmarkApp(500)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		app.SetScopeNames(nil) // drops the usage cache of all the commands
+		_ = app.UsageText()
+	}
+}
+
+func BenchmarkUsageTextOneChanged(b *testing.B) {
+	app := newBenchmarkApp(500)
+	cmd := app.LookupSubcommand("g0", "a")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cmd.SetParentVisible(true) // marks the usage of one command stale
+		_ = app.UsageText()
+	}
+}
+
+func TestParseAbbreviation(t *testing.T) {
+	var path string
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.AddFilter(flagx.FilterFunc(func(c *flagx.Context, next flagx.ActionFunc) {
+		path = c.CmdPathString()
+		next(c)
+	}))
+	app.AddSubaction("migrate", "migrate the database", new(Action1))
+	app.AddSubaction("mirror", "mirror the database", flagx.ActionFunc(func(*flagx.Context) {}))
+	stat := app.Exec(context.TODO(), []string{"migr", "-i", "5"})
+	assert.Equal(t, flagx.StatusNotFound, stat.Code())
+
+	app.SetParseMode(flagx.ParseAbbreviation)
+	stat = app.Exec(context.TODO(), []string{"migr", "-i", "5"})
+	assert.True(t, stat.OK(), stat.String())
+	assert.Equal(t, "testapp migrate", path)
+	stat = app.Exec(context.TODO(), []string{"mi"})
+	assert.Equal(t, flagx.StatusNotFound, stat.Code())
+	assert.EqualError(t, stat.Cause(), `ambiguous command "testapp mi", candidates: migrate, mirror`)
+}
+
+func TestAppParseAllErrors(t *testing.T) {
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.SetParseMode(flagx.ParseAllErrors)
+	app.SetValidator(func(interface{}) error { return errors.New("empty ID") })
+	app.AddSubaction("a", "subcommand a", new(Action1))
+	stat := app.Exec(context.TODO(), []string{"a", "-id=x", "-y"})
+	assert.Equal(t, flagx.StatusParseFailed, stat.Code())
+	assert.Contains(t, stat.String(), `invalid value \"x\" for flag -id: parse error`)
+	assert.Contains(t, stat.String(), "empty ID")
+}
+
+func TestAppSetOutput(t *testing.T) {
+	var buf bytes.Buffer
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.SetOutput(&buf)
+	app.AddFilter(new(Filter1))
+	app.AddSubaction("a", "subcommand a", new(Action1))
+	stat := app.Exec(context.TODO(), []string{"-g=x", "y", "a"})
+	assert.Equal(t, flagx.StatusParseFailed, stat.Code())
+	assert.Contains(t, buf.String(), `invalid value "y" for non-flag 0: parse error`)
+	stat = app.Exec(context.TODO(), []string{"true", "a", "-id", "x"})
+	assert.Equal(t, flagx.StatusParseFailed, stat.Code())
+	assert.Contains(t, buf.String(), `invalid value "x" for flag -id: parse error`)
+	buf.Reset()
+	app.PrintUsage()
+	assert.Equal(t, app.UsageText(), buf.String())
+}
+
+func TestUsageHeaderFooter(t *testing.T) {
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.SetUsageHeader("docs: https://example.com/testapp")
+	app.SetUsageFooter("support: support@example.com")
+	app.AddSubaction("a", "subcommand a", new(Action1))
+	sub := app.LookupSubcommand("a")
+	sub.SetUsageHeader("env: TESTAPP_HOME")
+	sub.SetUsageFooter("see also: testapp b")
+	usage := app.UsageText()
+	t.Log(usage)
+	assert.Contains(t, usage, "docs: https://example.com/testapp\n\nUSAGE:\n")
+	assert.Contains(t, usage, "  $testapp a\n    subcommand a\n    env: TESTAPP_HOME\n    -id int\n")
+	assert.Contains(t, usage, "    see also: testapp b\n")
+	assert.True(t, strings.HasSuffix(usage, "support: support@example.com\n"))
+}
+
+func TestSeeAlso(t *testing.T) {
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.AddSeeAlso("a")
+	app.AddSubaction("a", "subcommand a", new(Action1))
+	app.AddSubaction("b", "subcommand b", new(Action1))
+	app.LookupSubcommand("a").AddSeeAlso("b", " ", "config  init")
+	assert.Equal(t, []string{"b", "config init"}, app.LookupSubcommand("a").SeeAlso())
+	usage := app.UsageText()
+	t.Log(usage)
+	assert.Contains(t, usage, "    SEE ALSO:\n      $testapp b\n      $testapp config init\n  $testapp b\n")
+	assert.True(t, strings.HasSuffix(usage, "SEE ALSO:\n  $testapp a\n"))
+}
+
+type orderedAction struct {
+	Zeta  string `flag:"zeta;usage=param zeta"`
+	Path  string `flag:"?0;usage=param path"`
+	Alpha int    `flag:"alpha;usage=param alpha"`
+}
+
+func (a *orderedAction) Execute(c *flagx.Context) {}
+
+func TestSetFlagOrder(t *testing.T) {
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.AddFilter(new(Filter1))
+	app.AddSubaction("a", "subcommand a", new(orderedAction))
+	sub := app.LookupSubcommand("a")
+	names := func() []string {
+		var a []string
+		for _, line := range strings.Split(app.UsageText(), "\n") {
+			line = strings.TrimSpace(line)
+			if strings.HasPrefix(line, "-") || strings.HasPrefix(line, "?") {
+				a = append(a, strings.Fields(line)[0])
+			}
+		}
+		return a
+	}
+	assert.Equal(t, []string{"-g", "?0", "-alpha", "-zeta", "?0"}, names())
+	sub.SetFlagOrder(flagx.FlagOrderDefinition)
+	assert.Equal(t, []string{"-g", "?0", "-zeta", "?0", "-alpha"}, names())
+	sub.SetFlagOrder(flagx.FlagOrderNonFlagFirst)
+	assert.Equal(t, []string{"-g", "?0", "?0", "-alpha", "-zeta"}, names())
+	app.SetFlagOrder(flagx.FlagOrderLexical)
+	sub.SetFlagLess(func(a, b *flagx.Flag) bool {
+		return a.Name == "zeta" && b.Name != "zeta"
+	})
+	assert.Equal(t, []string{"-g", "?0", "-zeta", "?0", "-alpha"}, names())
+}
+
+func TestCompletion(t *testing.T) {
+	var buf bytes.Buffer
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.SetOutput(&buf)
+	app.SetCompletion(true)
+	app.AddFilter(new(Filter1))
+	app.AddSubaction("a", "subcommand a", new(Action1))
+	app.AddSubaction("ab", "subcommand ab", new(Action1))
+	app.AddSubaction("hidden", "hidden subcommand", new(Action1))
+	app.LookupSubcommand("hidden").SetParentVisible(false)
+	app.LookupSubcommand("a").SetCompletionFunc("id", func(ctx context.Context, args []string, toComplete string) ([]string, flagx.CompletionDirective) {
+		return []string{"1", "2", "10"}, flagx.CompletionNoFileComp
+	})
+	complete := func(args ...string) string {
+		buf.Reset()
+		stat := app.Exec(context.TODO(), append([]string{flagx.CompleteCmdName}, args...))
+		assert.True(t, stat.OK())
+		return buf.String()
+	}
+	assert.Equal(t, "a\tsubcommand a\nab\tsubcommand ab\n:4\n", complete("true", "a"))
+	assert.Equal(t, "-g\tglobal param g\n:4\n", complete("-"))
+	assert.Equal(t, "-id\tparam id\n:4\n", complete("true", "a", "-i"))
+	assert.Equal(t, "--id\tparam id\n:4\n", complete("true", "a", "--"))
+	assert.Equal(t, "1\n10\n:4\n", complete("true", "a", "-id", "1"))
+	assert.Equal(t, "-id=2\n:4\n", complete("true", "a", "-id=2"))
+	assert.Equal(t, ":0\n", complete("true", "ab", "-id", ""))
+	assert.Equal(t, ":0\n", complete("true", "x"))
+}
+
+type resultAction struct {
+	ID   int    `flag:"id;usage=param id"`
+	Path string `flag:"?0;usage=param path"`
+}
+
+func (a *resultAction) Execute(c *flagx.Context) {
+	fmt.Fprintf(c.Output(), "id=%d", a.ID)
+}
+
+func TestExecResult(t *testing.T) {
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.AddSubaction("a", "subcommand a", new(resultAction))
+	result, err := app.ExecResult(context.TODO(), []string{"a", "-id", "1", "x"})
+	assert.NoError(t, err)
+	assert.True(t, result.Status.OK())
+	assert.Equal(t, []string{"testapp", "a"}, result.CmdPath)
+	assert.Equal(t, &resultAction{ID: 1, Path: "x"}, result.Action)
+	assert.Equal(t, "id=1", result.Output)
+
+	result, err = app.ExecResult(context.TODO(), []string{"a", "-id", "x"})
+	assert.EqualError(t, err, `invalid value "x" for flag -id: parse error`)
+	assert.Equal(t, flagx.StatusParseFailed, result.Status.Code())
+	assert.Contains(t, result.Output, `invalid value "x" for flag -id: parse error`)
+}
+
+type authFilter struct {
+	User string `flag:"user;usage=the user"`
+}
+
+func (f *authFilter) Filter(c *flagx.Context, next flagx.ActionFunc) {
+	f.User = strings.ToUpper(f.User)
+	next(c)
+}
+
+func TestContextFilter(t *testing.T) {
+	var user, user2 string
+	var missing interface{}
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.AddFilter(new(authFilter))
+	app.AddSubaction("a", "subcommand a", flagx.ActionFunc(func(c *flagx.Context) {
+		user = c.Filter(reflect.TypeOf(authFilter{})).(*authFilter).User
+		f, ok := flagx.FilterOf[*authFilter](c)
+		assert.True(t, ok)
+		user2 = f.User
+		missing = c.Filter(reflect.TypeOf(resultAction{}))
+	}))
+	stat := app.Exec(context.TODO(), []string{"-user", "bob", "a"})
+	assert.True(t, stat.OK(), stat.String())
+	assert.Equal(t, "BOB", user)
+	assert.Equal(t, "BOB", user2)
+	assert.Nil(t, missing)
+}
+
+func TestRangeMeta(t *testing.T) {
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.AddSubaction("a", "subcommand a", flagx.ActionFunc(func(c *flagx.Context) {
+		meta := make(map[interface{}]interface{})
+		c.RangeCmdMeta(func(key, val interface{}) bool {
+			meta[key] = val
+			return true
+		})
+		assert.Equal(t, map[interface{}]interface{}{"k1": 1, "k2": 2, "k3": 3}, meta)
+	}))
+	sub := app.LookupSubcommand("a")
+	sub.SetMeta("k1", 1)
+	sub.SetMeta("k2", 2)
+	var n int
+	sub.RangeMeta(func(key, val interface{}) bool {
+		n++
+		sub.SetMeta("k3", 3) // modifying the meta does not deadlock
+		return false
+	})
+	assert.Equal(t, 1, n)
+	assert.True(t, app.Exec(context.TODO(), []string{"a"}).OK())
+}
+
+type envAction struct {
+	Name string `flag:"name"`
+}
+
+var envActionName string
+
+func (a *envAction) Execute(c *flagx.Context) {
+	envActionName = a.Name
+}
+
+func TestWithEnv(t *testing.T) {
+	var home string
+	var homeOK bool
+	var env map[string]string
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.SetEnvPrefix("TESTAPP")
+	app.AddSubaction("a", "subcommand a", flagx.ActionFunc(func(c *flagx.Context) {
+		home, homeOK = c.LookupEnv("HOME")
+		env = c.Env()
+	}))
+	app.AddSubaction("b", "subcommand b", new(envAction))
+	ctx := flagx.WithEnv(context.TODO(), map[string]string{"HOME": "/home/x", "TESTAPP_B_NAME": "y"})
+	assert.True(t, app.Exec(ctx, []string{"a"}).OK())
+	assert.Equal(t, "/home/x", home)
+	assert.True(t, homeOK)
+	assert.Equal(t, map[string]string{"HOME": "/home/x", "TESTAPP_B_NAME": "y"}, env)
+	assert.True(t, app.Exec(ctx, []string{"b"}).OK())
+	assert.Equal(t, "y", envActionName)
+	assert.True(t, app.Exec(flagx.WithEnv(context.TODO(), nil), []string{"a"}).OK())
+	assert.False(t, homeOK)
+}
+
+func TestGlobalFlags(t *testing.T) {
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.NonString(0, "dev", "the environment name")
+	region := app.GlobalFlags().String("region", "cn", "the region")
+	app.GlobalFlags().Int("n", 1, "the number")
+	var got []string
+	app.AddSubaction("a", "subcommand a", flagx.ActionFunc(func(c *flagx.Context) {
+		got = append([]string{c.Global("?0").(string), c.Global("-region").(string)}, c.Args()...)
+	}))
+	app.AddSubaction("wait", "subcommand wait", flagx.ActionFunc(func(c *flagx.Context) {
+		n := c.Global("n").(int)
+		time.Sleep(time.Millisecond)
+		if c.Global("n").(int) != n {
+			c.ThrowStatus(flagx.StatusExecuteFailed, "the global flag is changed by another execution")
+		}
+	}))
+	usage := app.UsageText()
+	assert.Contains(t, usage, "  -region string\n")
+	assert.Contains(t, usage, "  ?0 string\n")
+	assert.True(t, app.Exec(context.TODO(), []string{"-region", "us", "prod", "a", "x"}).OK())
+	assert.Equal(t, []string{"prod", "us", "a", "x"}, got)
+	assert.True(t, app.Exec(context.TODO(), []string{"test", "a"}).OK())
+	assert.Equal(t, []string{"test", "cn", "a"}, got)
+	stat := app.Exec(context.TODO(), []string{"-region"})
+	assert.Equal(t, flagx.StatusParseFailed, stat.Code())
+	assert.Equal(t, "cn", *region)
+	assert.Panics(t, func() { app.GlobalFlags() })
+
+	// the concurrent executions have their own values
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			stat := app.Exec(context.TODO(), []string{"-n", strconv.Itoa(i), "prod", "wait"})
+			assert.True(t, stat.OK(), stat.String())
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestExecCommand(t *testing.T) {
+	var steps []string
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.AddSubaction("build", "build", flagx.ActionFunc(func(c *flagx.Context) {
+		steps = append(steps, "build:"+strings.Join(c.Args(), ",")+":"+c.CmdPathString())
+	}))
+	app.AddSubaction("publish", "publish", new(resultAction))
+	app.AddSubaction("release", "release", flagx.ActionFunc(func(c *flagx.Context) {
+		c.SetValue("k", "v")
+		stat := c.ExecCommand([]string{"build"}, []string{"x"})
+		assert.True(t, stat.OK())
+		stat = c.ExecCommand([]string{"publish"}, []string{"-id", "7"})
+		assert.True(t, stat.OK())
+		stat = c.ExecCommand([]string{"publish"}, []string{"-id", "x"})
+		assert.Equal(t, flagx.StatusParseFailed, stat.Code())
+		stat = c.ExecCommand([]string{"deploy"}, nil)
+		assert.Equal(t, flagx.StatusNotFound, stat.Code())
+		steps = append(steps, "release")
+	}))
+	result, err := app.ExecResult(context.TODO(), []string{"release"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"build:x:testapp build", "release"}, steps)
+	// the nested commands share the output of the execution
+	assert.True(t, strings.HasPrefix(result.Output, "id=7"))
+	assert.Equal(t, []string{"testapp", "release"}, result.CmdPath)
+
+	// the nested execution does not share the parsed flags
+	app = flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.AddFilter(new(CommandLineFilter))
+	app.AddSubaction("run", "run", new(forwardPrintAction))
+	app.AddSubaction("release", "release", flagx.ActionFunc(func(c *flagx.Context) {
+		before := c.ForwardArgs(nil)
+		assert.True(t, c.ExecCommand([]string{"run"}, []string{"-id", "1"}).OK())
+		assert.Equal(t, before, c.ForwardArgs(nil))
+		fmt.Fprint(c.Output(), before)
+	}))
+	result, err = app.ExecResult(context.TODO(), []string{"-v", "release"})
+	assert.NoError(t, err)
+	assert.Equal(t, "[-id=1][-v]", result.Output)
+}
+
+type forwardPrintAction struct {
+	ID int `flag:"id"`
+}
+
+func (a *forwardPrintAction) Execute(c *flagx.Context) {
+	fmt.Fprint(c.Output(), c.ForwardArgs(nil))
+}
+
+type outputItem struct {
+	Name string            `json:"name"`
+	Tags []string          `json:"tags"`
+	Meta map[string]string `json:"meta,omitempty"`
+}
+
+func (o outputItem) String() string {
+	return o.Name + " " + strings.Join(o.Tags, ",")
+}
+
+func TestOutputFormat(t *testing.T) {
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.AddSubaction("get", "get the item", flagx.ActionFunc(func(c *flagx.Context) {
+		c.Print(outputItem{Name: "a", Tags: []string{"x", "y"}, Meta: map[string]string{"on": "1: 2"}})
+	}))
+	app.LookupSubcommand("get").SetOutputFormat(flagx.OutputText)
+	assert.Panics(t, func() { app.LookupSubcommand("get").SetOutputFormat("xml") })
+	assert.Contains(t, app.UsageText(), "-output format")
+	exec := func(args ...string) (string, *flagx.Status) {
+		result, _ := app.ExecResult(context.TODO(), args)
+		return result.Output, result.Status
+	}
+	out, stat := exec("get")
+	assert.True(t, stat.OK(), stat.String())
+	assert.Equal(t, "a x,y\n", out)
+	out, _ = exec("get", "-o", "json")
+	assert.Equal(t, "{\n  \"name\": \"a\",\n  \"tags\": [\n    \"x\",\n    \"y\"\n  ],\n  \"meta\": {\n    \"on\": \"1: 2\"\n  }\n}\n", out)
+	out, _ = exec("get", "--output=yaml")
+	assert.Equal(t, "name: a\ntags:\n  - x\n  - \"y\"\nmeta:\n  \"on\": \"1: 2\"\n", out)
+	_, stat = exec("get", "-o", "xml")
+	assert.Equal(t, flagx.StatusBadArgs, stat.Code())
+
+	// the output flag is not taken after the first non-flag or as the value of a preceding flag
+	app2 := flagx.NewApp()
+	app2.SetCmdName("testapp")
+	app2.AddSubaction("ssh", "ssh to the host", new(sshAction))
+	app2.LookupSubcommand("ssh").SetOutputFormat(flagx.OutputText)
+	result, err := app2.ExecResult(context.TODO(), []string{"ssh", "-i", "-o", "-v", "-o=json", "host", "-o", "ProxyJump=x"})
+	assert.NoError(t, err)
+	assert.Equal(t, "{\n  \"identity\": \"-o\",\n  \"verbose\": true,\n  \"host\": \"host\"\n}\n", result.Output)
+}
+
+type sshAction struct {
+	Identity string `flag:"i" json:"identity"`
+	Verbose  bool   `flag:"v" json:"verbose"`
+	Host     string `flag:"?0" json:"host"`
+}
+
+func (a *sshAction) Execute(c *flagx.Context) {
+	c.Print(a)
+}
+
+func TestWriteOutputYAML(t *testing.T) {
+	var buf bytes.Buffer
+	v := map[string]interface{}{
+		"list":  []interface{}{map[string]interface{}{"id": 1, "ok": true}, []int{}, nil},
+		"empty": map[string]int{},
+		"str":   "-1",
+	}
+	assert.NoError(t, flagx.WriteOutput(&buf, flagx.OutputYAML, v))
+	assert.Equal(t, "empty: {}\nlist:\n  - id: 1\n    ok: true\n  - []\n  - null\nstr: \"-1\"\n", buf.String())
+	assert.EqualError(t, flagx.WriteOutput(&buf, "xml", v), "unknown output format: xml")
+}
+
+func TestContextGo(t *testing.T) {
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	var cancelled int32
+	app.AddSubaction("fail", "fail", flagx.ActionFunc(func(c *flagx.Context) {
+		for i := 0; i < 2; i++ {
+			c.Go(func(c *flagx.Context) error {
+				<-c.Done()
+				atomic.AddInt32(&cancelled, 1)
+				return nil
+			})
+		}
+		c.Go(func(c *flagx.Context) error {
+			return errors.New("worker failed")
+		})
+		c.Wait()
+		t.Error("unreachable")
+	}))
+	app.AddSubaction("panic", "panic", flagx.ActionFunc(func(c *flagx.Context) {
+		c.Go(func(c *flagx.Context) error {
+			panic("worker panic")
+		})
+	}))
+	var done int32
+	app.AddSubaction("detach", "detach", flagx.ActionFunc(func(c *flagx.Context) {
+		c.Go(func(c *flagx.Context) error {
+			time.Sleep(10 * time.Millisecond)
+			atomic.StoreInt32(&done, 1)
+			return nil
+		})
+	}))
+	stat := app.Exec(context.TODO(), []string{"fail"})
+	assert.Equal(t, flagx.StatusExecuteFailed, stat.Code())
+	assert.EqualError(t, stat.Cause(), "worker failed")
+	assert.Equal(t, int32(2), atomic.LoadInt32(&cancelled))
+	stat = app.Exec(context.TODO(), []string{"panic"})
+	assert.False(t, stat.OK())
+	assert.Contains(t, stat.Cause().Error(), "worker panic")
+	stat = app.Exec(context.TODO(), []string{"detach"})
+	assert.True(t, stat.OK(), stat.String())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&done))
+}
+
+func TestShutdown(t *testing.T) {
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	started := make(chan struct{})
+	var steps []string
+	var lock sync.Mutex
+	step := func(s string) {
+		lock.Lock()
+		defer lock.Unlock()
+		steps = append(steps, s)
+	}
+	app.AddSubaction("serve", "serve", flagx.ActionFunc(func(c *flagx.Context) {
+		c.OnShutdown(func() {
+			step("drain")
+		})
+		close(started)
+		<-c.Done()
+		step("cancelled")
+	}))
+	app.AddSubaction("check", "check", flagx.ActionFunc(func(c *flagx.Context) {
+		c.OnShutdown(func() {
+			step("late")
+		})
+		assert.Error(t, c.Err())
+	}))
+	stop := app.EnableShutdown()
+	defer stop()
+	app.SetShutdownGrace(time.Second)
+	done := make(chan *flagx.Status)
+	go func() {
+		done <- app.Exec(context.TODO(), []string{"serve"})
+	}()
+	<-started
+	assert.True(t, app.Shutdown())
+	assert.True(t, (<-done).OK())
+	assert.Equal(t, []string{"drain", "cancelled"}, steps)
+	assert.True(t, app.Exec(context.TODO(), []string{"check"}).OK())
+	assert.Equal(t, []string{"drain", "cancelled", "late"}, steps)
+
+	app2 := flagx.NewApp()
+	app2.SetCmdName("testapp")
+	app2.AddSubaction("stuck", "stuck", flagx.ActionFunc(func(c *flagx.Context) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer app2.EnableShutdown()()
+	app2.SetShutdownGrace(10 * time.Millisecond)
+	started = make(chan struct{})
+	go app2.Exec(context.TODO(), []string{"stuck"})
+	<-started
+	assert.False(t, app2.Shutdown())
+}
+
+func TestSingleton(t *testing.T) {
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	lockPath := filepath.Join(t.TempDir(), "gc.lock")
+	var nested, nestedGo *flagx.Status
+	var runs int
+	app.AddSubaction("gc", "gc", flagx.ActionFunc(func(c *flagx.Context) {
+		runs++
+		if runs == 1 {
+			nested = app.Exec(context.TODO(), []string{"gc"})
+			c.Go(func(*flagx.Context) error {
+				time.Sleep(20 * time.Millisecond)
+				nestedGo = app.Exec(context.TODO(), []string{"gc"})
+				return nil
+			})
+		}
+	}))
+	app.LookupSubcommand("gc").SetSingleton(lockPath)
+	assert.Equal(t, lockPath, app.LookupSubcommand("gc").Singleton())
+	stat := app.Exec(context.TODO(), []string{"gc"})
+	assert.True(t, stat.OK(), stat.String())
+	assert.Equal(t, 1, runs)
+	assert.Equal(t, flagx.StatusLocked, nested.Code())
+	assert.Equal(t, fmt.Sprintf("command \"testapp gc\" is already running, locked by %s (pid %d)", lockPath, os.Getpid()), nested.Msg())
+	assert.Equal(t, flagx.StatusLocked, nestedGo.Code())
+	stat = app.Exec(context.TODO(), []string{"gc"})
+	assert.True(t, stat.OK(), stat.String())
+	assert.Equal(t, 2, runs)
+}
+
+func TestSetMetrics(t *testing.T) {
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.AddSubcommand("sub", "sub").AddSubaction("run", "run", flagx.ActionFunc(func(c *flagx.Context) {
+		if args := c.Args(); args[len(args)-1] == "x" {
+			c.ThrowStatus(flagx.StatusBadArgs, "bad args")
+		}
+	}))
+	var observed []string
+	app.SetMetrics(flagx.CollectorFunc(func(cmdPath []string, d time.Duration, code int32) {
+		assert.True(t, d >= 0)
+		observed = append(observed, strings.Join(cmdPath, " ")+":"+strconv.Itoa(int(code)))
+	}))
+	app.Exec(context.TODO(), []string{"sub", "run"})
+	app.Exec(context.TODO(), []string{"sub", "run", "x"})
+	app.Exec(context.TODO(), []string{"sub", "typo"})
+	assert.Equal(t, []string{"testapp sub run:0", "testapp sub run:1", "testapp:2"}, observed)
+}
+
+type roleUser []string
+
+func (u roleUser) Roles() []string { return u }
+
+func TestAuthorizer(t *testing.T) {
+	const readScope, writeScope = flagx.Scope(1), flagx.Scope(2)
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	var ran []string
+	app.AddSubaction("get", "get", flagx.ActionFunc(func(c *flagx.Context) { ran = append(ran, "get") }), readScope)
+	app.AddSubaction("set", "set", flagx.ActionFunc(func(c *flagx.Context) { ran = append(ran, "set") }), writeScope)
+	app.AddSubaction("ping", "ping", flagx.ActionFunc(func(c *flagx.Context) { ran = append(ran, "ping") }))
+	app.SetAuthorizer(flagx.RoleAuthorizer(map[string]flagx.Scope{"viewer": readScope, "admin": flagx.ScopeAll}))
+	viewer := flagx.WithCaller(context.TODO(), roleUser{"viewer"})
+	assert.True(t, app.Exec(viewer, []string{"get"}).OK())
+	stat := app.Exec(viewer, []string{"set"})
+	assert.Equal(t, flagx.StatusForbidden, stat.Code())
+	assert.Equal(t, `forbidden command: "testapp set", roles: [viewer]`, stat.Msg())
+	assert.True(t, app.Exec(flagx.WithCaller(context.TODO(), roleUser{"admin"}), []string{"set"}).OK())
+	assert.Equal(t, flagx.StatusForbidden, app.Exec(context.TODO(), []string{"get"}).Code())
+	assert.True(t, app.Exec(context.TODO(), []string{"ping"}).OK())
+	assert.Equal(t, []string{"get", "set", "ping"}, ran)
+
+	var caller interface{}
+	var scopes []flagx.Scope
+	app.SetAuthorizer(flagx.AuthorizerFunc(func(c *flagx.Context) *flagx.Status {
+		caller = c.Caller()
+		scopes = append(scopes, c.CmdScope(), c.ExecScope())
+		return nil
+	}))
+	assert.True(t, app.Exec(viewer, []string{"get"}).OK())
+	assert.Equal(t, roleUser{"viewer"}, caller)
+	assert.True(t, app.Exec(viewer, []string{"get"}, flagx.ScopeAll).OK())
+	assert.Equal(t, []flagx.Scope{readScope, flagx.InitialScope, readScope, flagx.ScopeAll}, scopes)
+
+	// the flag values are resolved only after the execution is authorized
+	var validated int
+	app2 := flagx.NewApp()
+	app2.SetCmdName("testapp")
+	app2.AddSubaction("echo", "echo", new(ConfigAction), writeScope)
+	app2.SetValidator(func(interface{}) error {
+		validated++
+		return nil
+	})
+	app2.SetAuthorizer(flagx.RoleAuthorizer(map[string]flagx.Scope{"admin": flagx.ScopeAll}))
+	assert.Equal(t, flagx.StatusForbidden, app2.Exec(viewer, []string{"echo", "-name=x"}).Code())
+	assert.Equal(t, 0, validated)
+	assert.True(t, app2.Exec(flagx.WithCaller(context.TODO(), roleUser{"admin"}), []string{"echo", "-name=x"}).OK())
+	assert.Equal(t, 1, validated)
+}
@@ -0,0 +1,109 @@
+package flagx
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// AuditRecord the audit record of an execution
+	AuditRecord struct {
+		Time      time.Time     `json:"time"`       // The start time of the execution
+		CmdPath   []string      `json:"cmd_path"`   // The command path
+		Args      []string      `json:"args"`       // The arguments with the secret flags masked
+		Code      int32         `json:"code"`       // The status code
+		Msg       string        `json:"msg"`        // The status message
+		Duration  time.Duration `json:"duration"`   // The duration of the execution
+		RequestID string        `json:"request_id"` // The request ID of the execution
+	}
+	// AuditFunc records the audit record of an execution
+	AuditFunc func(*AuditRecord)
+)
+
+// SecretMask the mask of the secret flag values in the audit records and the outputs
+const SecretMask = "******"
+
+// SetAuditor sets the function that records every execution, and the names of
+// the secret flags whose values are masked in the records.
+// NOTE:
+//  set nil to disable auditing.
+func (a *App) SetAuditor(fn AuditFunc, secretFlags ...string) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.auditor = fn
+	a.auditSecrets = make(map[string]bool, len(secretFlags))
+	for _, name := range secretFlags {
+		a.auditSecrets[strings.TrimLeft(name, "-")] = true
+	}
+}
+
+// NewAuditWriter returns an AuditFunc that writes the records to w as JSON lines.
+// NOTE:
+//  it is safe for concurrent use.
+func NewAuditWriter(w io.Writer) AuditFunc {
+	var lock sync.Mutex
+	enc := json.NewEncoder(w)
+	return func(r *AuditRecord) {
+		lock.Lock()
+		defer lock.Unlock()
+		enc.Encode(r)
+	}
+}
+
+func (snap *execSnapshot) audit(ctxObj *Context, start time.Time, stat *Status) {
+	snap.auditor(&AuditRecord{
+		Time:      start,
+		CmdPath:   ctxObj.CmdPath(),
+		Args:      maskArgs(ctxObj.RawArgs(), snap.auditSecrets, snap.isBoolFlag),
+		Code:      stat.Code(),
+		Msg:       stat.Msg(),
+		Duration:  time.Since(start),
+		RequestID: ctxObj.RequestID(),
+	})
+}
+
+// maskArgs returns a copy of the arguments with the values of the secret flags masked.
+// NOTE:
+//  the argument following a non-bool secret flag is always masked, even if it starts with '-',
+//  since the parser takes it as the value.
+func maskArgs(args []string, secrets map[string]bool, isBool func(name string) bool) []string {
+	r := make([]string, len(args))
+	copy(r, args)
+	if len(secrets) == 0 {
+		return r
+	}
+	for i := 0; i < len(r); i++ {
+		s := r[i]
+		if s == "--" {
+			break
+		}
+		if len(s) < 2 || s[0] != '-' {
+			continue
+		}
+		name := strings.TrimLeft(s, "-")
+		if j := strings.IndexByte(name, '='); j >= 0 {
+			if secrets[name[:j]] {
+				r[i] = s[:len(s)-len(name)+j+1] + SecretMask
+			}
+			continue
+		}
+		if secrets[name] && i+1 < len(r) && !isBool(name) {
+			i++
+			r[i] = SecretMask
+		}
+	}
+	return r
+}
+
+// isBoolFlag reports whether the parsed flag of the execution does not need an argument.
+func (snap *execSnapshot) isBoolFlag(name string) bool {
+	for _, b := range snap.bindings {
+		if f := b.flagSet.Lookup(name); f != nil {
+			return isBoolFlag(f)
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package flagx
+
+import (
+	"context"
+	"fmt"
+)
+
+type (
+	// Authorizer authorizes the execution of the resolved command, such as the RBAC of the
+	// remotely-invoked or multi-tenant executions.
+	// NOTE:
+	//  the Context provides the command path, the command scope, the executor scope,
+	//  the command meta, the caller identity injected by WithCaller and whether the command is external;
+	//  the flag values are not resolved yet when it is called.
+	Authorizer interface {
+		// Authorize returns nil or an OK status to allow the execution,
+		// otherwise the status is returned by Exec.
+		Authorize(c *Context) *Status
+	}
+	// AuthorizerFunc authorizer function
+	AuthorizerFunc func(c *Context) *Status
+	// RoleHolder the caller identity holding the roles, see RoleAuthorizer
+	RoleHolder interface {
+		Roles() []string
+	}
+)
+
+// Authorize implements Authorizer interface.
+func (fn AuthorizerFunc) Authorize(c *Context) *Status {
+	return fn(c)
+}
+
+// WithCaller returns a copy of ctx with the caller identity, such as the authenticated user
+// of a remote invocation, which is returned by Context.Caller.
+func WithCaller(ctx context.Context, caller interface{}) context.Context {
+	return context.WithValue(ctx, callerKey, caller)
+}
+
+// Caller returns the caller identity injected by WithCaller, or nil.
+func (c *Context) Caller() interface{} {
+	if c.Context == nil {
+		return nil
+	}
+	return c.Context.Value(callerKey)
+}
+
+// SetAuthorizer sets the authorizer of the executions, which is called after the command is resolved,
+// before the flag values are resolved from the environment, the config, the remote source and the secrets,
+// and before the filters and the action.
+// NOTE:
+//  the flags are parsed to resolve the command, but their values are not ready in the authorizer;
+//  set nil to disable it.
+// Example:
+//  app.SetAuthorizer(flagx.RoleAuthorizer(map[string]flagx.Scope{"admin": flagx.ScopeAll, "viewer": readScope}))
+//  app.Exec(flagx.WithCaller(ctx, user), args)
+func (a *App) SetAuthorizer(authorizer Authorizer) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.authorizer = authorizer
+}
+
+// RoleAuthorizer returns an authorizer granting the bitmask scopes to the roles of the caller,
+// which allows the execution if any of the granted scopes overlaps the command scope.
+// NOTE:
+//  the caller should implement RoleHolder, otherwise it has no roles;
+//  InitialScope command is allowed for any caller;
+//  the external command, see App.SetExternalCommands, is only allowed for the roles granted ScopeAll;
+//  the denied execution returns a status with code StatusForbidden.
+func RoleAuthorizer(roleScopes map[string]Scope) Authorizer {
+	return AuthorizerFunc(func(c *Context) *Status {
+		cmdScope := c.CmdScope()
+		if cmdScope == InitialScope && !c.external {
+			return nil
+		}
+		var roles []string
+		if holder, ok := c.Caller().(RoleHolder); ok {
+			roles = holder.Roles()
+		}
+		for _, role := range roles {
+			if c.external {
+				if roleScopes[role] == ScopeAll {
+					return nil
+				}
+			} else if roleScopes[role].Overlaps(cmdScope) {
+				return nil
+			}
+		}
+		return NewStatus(StatusForbidden, fmt.Sprintf("forbidden command: %q, roles: %v", c.CmdPathString(), roles))
+	})
+}
+
+// IsExternal reports whether the execution runs an external command, see App.SetExternalCommands.
+func (c *Context) IsExternal() bool {
+	return c.external
+}
+
+// authorizeAction wraps the handler to be authorized before the filters and the action.
+func authorizeAction(authorizer Authorizer, handler ActionFunc) ActionFunc {
+	return func(c *Context) {
+		if stat := authorizer.Authorize(c); !stat.OK() {
+			panic(stat)
+		}
+		handler(c)
+	}
+}
@@ -0,0 +1,69 @@
+package flagx
+
+import (
+	"errors"
+	"strings"
+)
+
+// ConfigStore the hierarchical config store keyed by the '.'-joined path,
+// such as *koanf.Koanf and *viper.Viper
+type ConfigStore interface {
+	// Get returns the value of the key, or nil if it is not set
+	Get(key string) interface{}
+}
+
+// StoreDefaults returns the defaults provider backed by the config store,
+// so that the flags share the same source of truth with it, see AddDefaultsProvider.
+// NOTE:
+//  the elements of an array are joined by ',';
+//  the nested maps are not flag values, and are ignored.
+func StoreDefaults(store ConfigStore) DefaultsProvider {
+	return func(name string) (string, bool) {
+		return configValueString(store.Get(name))
+	}
+}
+
+// FlagProvider exposes the values of the flag set as a config provider, which implements
+// the koanf.Provider interface, and whose Read result can be merged by viper.MergeConfigMap.
+type FlagProvider struct {
+	flagSet     *FlagSet
+	onlyChanged bool
+}
+
+// NewFlagProvider creates the config provider of the flag set,
+// if @onlyChanged is true, only the flags that have been set are provided, such as on the command line.
+func NewFlagProvider(f *FlagSet, onlyChanged bool) *FlagProvider {
+	return &FlagProvider{flagSet: f, onlyChanged: onlyChanged}
+}
+
+// ReadBytes is not supported, use Read instead.
+func (p *FlagProvider) ReadBytes() ([]byte, error) {
+	return nil, errors.New("flagx: FlagProvider does not support ReadBytes")
+}
+
+// Read returns the typed values of the flags as a nested map, whose keys are split from the flag names by '.',
+// such as -db.host to {"db":{"host":""}}.
+// NOTE:
+//  the non-flags are skipped.
+func (p *FlagProvider) Read() (map[string]interface{}, error) {
+	m := make(map[string]interface{}, 16)
+	visit := func(fl *Flag) {
+		keys := strings.Split(fl.Name, ".")
+		t := m
+		for _, key := range keys[:len(keys)-1] {
+			sub, ok := t[key].(map[string]interface{})
+			if !ok {
+				sub = make(map[string]interface{}, 8)
+				t[key] = sub
+			}
+			t = sub
+		}
+		t[keys[len(keys)-1]] = flagValue(fl)
+	}
+	if p.onlyChanged {
+		p.flagSet.FlagSet.Visit(visit)
+	} else {
+		p.flagSet.FlagSet.VisitAll(visit)
+	}
+	return m, nil
+}
@@ -0,0 +1,75 @@
+package flagx
+
+// CommandLine rebuilds the normalized invocation of the execution, which is the command path
+// followed by the flags set on the command line in canonical form, such as
+// ["app", "-g=true", "sub", "-id=1", "x"], for logging, re-exec and "copy this command" output.
+// NOTE:
+//  the flags of each command are sorted by name and follow its name, and the non-flags are the last;
+//  the values of the secret flags are masked;
+//  the bool flags set to true are in the form -name.
+func (c *Context) CommandLine() []string {
+	line := make([]string, 0, 8)
+	var chain []*Command
+	for r := c.cmd; r != nil; r = r.parent {
+		chain = append(chain, r)
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		cmd := chain[i]
+		if cmd.parent == nil {
+			line = append(line, c.snapCmdName())
+		} else {
+			line = append(line, cmd.cmdName)
+		}
+		if c.snap == nil {
+			continue
+		}
+		for _, b := range c.snap.bindings {
+			if b.cmd == cmd {
+				line = b.flagSet.appendCommandLine(line)
+			}
+		}
+	}
+	return line
+}
+
+func (c *Context) snapCmdName() string {
+	if len(c.cmdPath) > 0 {
+		return c.cmdPath[0]
+	}
+	return c.cmd.cmdName
+}
+
+// appendCommandLine appends the flags and non-flags set on the command line in canonical form.
+func (f *FlagSet) appendCommandLine(line []string) []string {
+	snapshot := f.Snapshot()
+	f.VisitAll(func(fl *Flag) {
+		r := snapshot[fl.Name]
+		if r.Source != SourceCommandLine {
+			return
+		}
+		value := r.Value
+		if f.IsSecret(fl.Name) {
+			value = SecretMask
+		} else if b, ok := fl.Value.(boolFlag); ok && b.IsBoolFlag() && value == "true" {
+			line = append(line, "-"+fl.Name)
+			return
+		}
+		line = append(line, "-"+fl.Name+"="+value)
+	})
+	last := -1
+	f.NonVisit(func(fl *Flag) {
+		if snapshot[fl.Name].Source == SourceCommandLine {
+			_, last = f.nonLookup(fl.Name)
+		}
+	})
+	f.NonVisitAll(func(fl *Flag) {
+		if _, idx := f.nonLookup(fl.Name); idx <= last {
+			if f.IsSecret(fl.Name) {
+				line = append(line, SecretMask)
+			} else {
+				line = append(line, snapshot[fl.Name].Value)
+			}
+		}
+	})
+	return line
+}
@@ -0,0 +1,103 @@
+package flagx
+
+import (
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// ColorMode the mode of ANSI coloring of the usage output
+type ColorMode int8
+
+// Color modes
+const (
+	ColorNever  ColorMode = iota // Never color the usage (default)
+	ColorAuto                    // Color the usage if the output is a terminal and NO_COLOR is not set
+	ColorAlways                  // Always color the usage
+)
+
+// ANSI color codes of the usage output
+const (
+	colorCommand = "1;36"
+	colorFlag    = "32"
+	colorDefault = "33"
+)
+
+var defaultValueRegexp = regexp.MustCompile(` \(default .*\)$`)
+
+// SetColorMode sets the mode of ANSI coloring of the usage output.
+func (a *App) SetColorMode(mode ColorMode) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.colorMode = mode
+	a.colored = mode.enabled(a.usageOutputLocked())
+	a.resetUsageLocked()
+}
+
+// usageOutputLocked returns the writer the usage is printed to, which is the output set by SetOutput or the standard output.
+func (a *App) usageOutputLocked() io.Writer {
+	if a.output != nil {
+		return a.output
+	}
+	return os.Stdout
+}
+
+// ColorMode returns the mode of ANSI coloring of the usage output.
+func (a *App) ColorMode() ColorMode {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	return a.colorMode
+}
+
+// enabled reports whether the usage printed to @w is colored.
+// NOTE:
+//  in auto mode, only an *os.File of a terminal is colored.
+func (m ColorMode) enabled(w io.Writer) bool {
+	switch m {
+	case ColorAlways:
+		return true
+	case ColorAuto:
+		if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+			return false
+		}
+		f, ok := w.(*os.File)
+		return ok && isTerminal(f)
+	default:
+		return false
+	}
+}
+
+// isTerminal reports whether the file is a character device, such as a TTY.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+func paint(code, s string) string {
+	return "\x1b[" + code + "m" + s + "\x1b[0m"
+}
+
+// colorizeFlags colors the flag names and default values of the flags usage.
+func colorizeFlags(body string) string {
+	lines := strings.Split(body, "\n")
+	for i, line := range lines {
+		line = defaultValueRegexp.ReplaceAllStringFunc(line, func(s string) string {
+			return " " + paint(colorDefault, s[1:])
+		})
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed != "" && (trimmed[0] == '-' || trimmed[0] == '?') {
+			indent := line[:len(line)-len(trimmed)]
+			name, rest := trimmed, ""
+			if j := strings.IndexAny(trimmed, " \t"); j >= 0 {
+				name, rest = trimmed[:j], trimmed[j:]
+			}
+			line = indent + paint(colorFlag, name) + rest
+		}
+		lines[i] = line
+	}
+	return strings.Join(lines, "\n")
+}
@@ -1,15 +1,19 @@
 package flagx
 
 import (
-	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"reflect"
+	"runtime/debug"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/henrylee2cn/ameda"
+	"github.com/henrylee2cn/goutil"
 	"github.com/henrylee2cn/goutil/status"
 )
 
@@ -25,14 +29,58 @@ type Command struct {
 	subcommands             map[string]*Command
 	scopeCommandMap         map[Scope][]*Command // commands with actions by scope
 	scopeCommands           []*Command           // commands with actions by scope
-	usageText               string
+	usageText               string               // The usage of the command subtree
+	ownUsageText            string               // The usage of the command itself
+	usageStale              bool                 // The usage of the command itself needs to be rendered
 	execScopeUsageTexts     map[Scope]string
 	execScopeUsageTextsLock sync.RWMutex
 	parentUsageVisible      bool
 	meta                    map[interface{}]interface{}
+	notFound                ActionFunc
+	examples                []Example
+	usageHeader             string
+	usageFooter             string
+	seeAlso                 []string
+	flagOrder               FlagOrder
+	flagLess                func(a, b *Flag) bool
+	completionFuncs         map[string]CompletionFunc
+	outputFormat            OutputFormat // The default format of the output flag, empty if disabled
+	singleton               string       // The lock file of the action, empty if disabled
+	loader                  func() (*CommandSpec, error)
+	loadOnce                sync.Once
+	loadErr                 error
+	loading                 atomic.Bool // Whether the lazy command is applying its spec, see Load
+	routes                  atomic.Pointer[routeTable]
 	lock                    sync.RWMutex
 }
 
+// routeTable the immutable routing view of a command, which is read by Exec without locking
+// and dropped whenever the command is modified.
+type routeTable struct {
+	filters           []*filterObject
+	action            *actionObject
+	scope             Scope
+	notFound          ActionFunc
+	outputFormat      OutputFormat
+	singleton         string
+	subcommands       map[string]*Command
+	sortedSubcommands []*Command // The subcommands sorted by name
+}
+
+// CommandSpec the specification of a lazily loaded command
+type CommandSpec struct {
+	Filters []Filter       // The filters of the command
+	Action  Action         // The action of the command, if any
+	Scope   Scope          // The scope of the action
+	Setup   func(*Command) // The function to add subcommands, if any
+}
+
+// Example an example invocation of the command, which is rendered in the usage
+type Example struct {
+	Cmdline     string // The example command line
+	Description string // The description of the example
+}
+
 func newCommand(app *App, cmdName, description string) *Command {
 	return &Command{
 		app:                app,
@@ -40,10 +88,13 @@ func newCommand(app *App, cmdName, description string) *Command {
 		description:        description,
 		subcommands:        make(map[string]*Command, 16),
 		parentUsageVisible: true, // default
+		usageStale:         true,
 	}
 }
 
 // SetMeta sets the command meta.
+// NOTE:
+//  it is safe for concurrent use with Exec, even after the command tree is frozen.
 func (c *Command) SetMeta(key interface{}, val interface{}) {
 	c.lock.Lock()
 	defer c.lock.Unlock()
@@ -60,6 +111,23 @@ func (c *Command) GetMeta(key interface{}) interface{} {
 	return c.meta[key]
 }
 
+// RangeMeta calls fn for each command meta in no particular order, and stops if fn returns false.
+// NOTE:
+//  fn is called on a copy of the meta, so it can modify the meta.
+func (c *Command) RangeMeta(fn func(key, val interface{}) bool) {
+	c.lock.RLock()
+	meta := make(map[interface{}]interface{}, len(c.meta))
+	for k, v := range c.meta {
+		meta[k] = v
+	}
+	c.lock.RUnlock()
+	for k, v := range meta {
+		if !fn(k, v) {
+			return
+		}
+	}
+}
+
 // AddSubaction adds a subcommand and its action.
 // NOTE:
 //  panic when something goes wrong
@@ -74,6 +142,7 @@ func (c *Command) AddSubcommand(cmdName, description string, filters ...Filter)
 	if cmdName == "" {
 		panic("command name is empty")
 	}
+	c.checkMutable()
 	c.lock.Lock()
 	defer c.lock.Unlock()
 	if c.action != nil {
@@ -86,50 +155,310 @@ func (c *Command) AddSubcommand(cmdName, description string, filters ...Filter)
 	subCmd.parent = c
 	subCmd.AddFilter(filters...)
 	c.subcommands[cmdName] = subCmd
+	c.routes.Store(nil)
+	return subCmd
+}
+
+// SetNotFound sets the action when the correct subcommand of this command subtree cannot be found.
+// NOTE:
+//  it takes precedence over the ancestors and the app;
+//  panic when the command tree is frozen
+func (c *Command) SetNotFound(fn ActionFunc) {
+	c.checkMutable()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.notFound = fn
+	c.routes.Store(nil)
+}
+
+// AddExample adds an example invocation that is rendered in the EXAMPLES section of the usage.
+func (c *Command) AddExample(cmdline, description string) {
+	c.checkMutable()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.examples = append(c.examples, Example{Cmdline: cmdline, Description: description})
+	c.updateUsageLocked()
+}
+
+// SetUsageHeader sets the text rendered before the flags in the usage,
+// such as the links to docs or environment notes.
+// NOTE:
+//  the header of the app (root command) is rendered before the USAGE section.
+func (c *Command) SetUsageHeader(text string) {
+	c.checkMutable()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.usageHeader = text
+	c.updateUsageLocked()
+}
+
+// SetUsageFooter sets the text rendered after the flags and examples in the usage,
+// such as the support contacts.
+// NOTE:
+//  the footer of the app (root command) is rendered after the EXAMPLES section.
+func (c *Command) SetUsageFooter(text string) {
+	c.checkMutable()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.usageFooter = text
+	c.updateUsageLocked()
+}
+
+// AddSeeAlso adds the paths of the related commands rendered in the SEE ALSO section of the usage,
+// such as "config init".
+// NOTE:
+//  the path excludes the app command name;
+//  panic when the command tree is frozen
+func (c *Command) AddSeeAlso(paths ...string) {
+	c.checkMutable()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, p := range paths {
+		if p = strings.Join(strings.Fields(p), " "); p != "" {
+			c.seeAlso = append(c.seeAlso, p)
+		}
+	}
+	c.updateUsageLocked()
+}
+
+// SeeAlso returns the paths of the related commands.
+func (c *Command) SeeAlso() []string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return append([]string(nil), c.seeAlso...)
+}
+
+// seeAlsoLocked returns the command lines of the related commands, such as "$testapp config init".
+func (c *Command) seeAlsoLocked() []string {
+	lines := make([]string, 0, len(c.seeAlso))
+	root := c.Root().cmdName
+	for _, p := range c.seeAlso {
+		lines = append(lines, "$"+root+" "+p)
+	}
+	return lines
+}
+
+// Examples returns the example invocations of the command.
+func (c *Command) Examples() []Example {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return append([]Example(nil), c.examples...)
+}
+
+// AddLazySubcommand adds a subcommand whose filters, action and subcommands are
+// loaded by @load only when it is first routed to or Load is called.
+// NOTE:
+//  panic when something goes wrong;
+//  the lazy subcommand is not in the usage of executor scope until it is loaded.
+func (c *Command) AddLazySubcommand(cmdName, description string, load func() (*CommandSpec, error)) *Command {
+	if load == nil {
+		panic("lazy command loader is nil")
+	}
+	subCmd := c.AddSubcommand(cmdName, description)
+	subCmd.loader = load
 	return subCmd
 }
 
+// Load loads the lazy command, it is only executed once.
+// NOTE:
+//  returns nil if it is not a lazy command.
+func (c *Command) Load() error {
+	c.loadOnce.Do(func() {
+		if c.loader == nil {
+			return
+		}
+		spec, err := c.loader()
+		if err == nil && spec == nil {
+			err = fmt.Errorf("lazy command spec is nil: %q", c.PathString())
+		}
+		if err == nil {
+			c.loading.Store(true)
+			err = c.applySpec(spec)
+			c.loading.Store(false)
+		}
+		c.loadErr = err
+	})
+	return c.loadErr
+}
+
+// checkMutable panics if the command tree is frozen and the command is not being loaded.
+func (c *Command) checkMutable() {
+	if !c.app.Frozen() {
+		return
+	}
+	for r := c; r != nil; r = r.parent {
+		if r.loading.Load() {
+			return
+		}
+	}
+	panic(fmt.Errorf("the command tree is frozen after the first Exec, cannot modify: %q", c.PathString()))
+}
+
+func (c *Command) applySpec(spec *CommandSpec) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	if len(spec.Filters) > 0 {
+		c.AddFilter(spec.Filters...)
+	}
+	if spec.Action != nil {
+		c.SetAction(spec.Action, spec.Scope)
+	}
+	if spec.Setup != nil {
+		spec.Setup(c)
+	}
+	return nil
+}
+
 // AddFilter adds the filter action.
 // NOTE:
-//  if filter is a struct, it can implement the copier interface;
+//  if filter is a struct, it can implement the copier interface, or Resetter to be pooled;
+//  the filter can be named by NamedFilter, defaults to its type name;
 //  panic when something goes wrong
 func (c *Command) AddFilter(filters ...Filter) {
+	c.checkMutable()
 	c.lock.Lock()
 	defer c.lock.Unlock()
 	for _, filter := range filters {
-		var obj filterObject
-		obj.flagSet = NewFlagSet(c.cmdName, ContinueOnError|ContinueOnUndefined)
-		elemType := ameda.DereferenceType(reflect.TypeOf(filter))
-		switch elemType.Kind() {
-		case reflect.Struct:
-			var ok bool
-			obj.factory, ok = filter.(FilterCopier)
-			if !ok {
-				obj.factory = &factory{elemType: elemType}
-			}
-			err := obj.flagSet.StructVars(obj.factory.DeepCopy())
-			if err != nil {
-				panic(err)
-			}
-			obj.flagSet.VisitAll(func(f *Flag) {
-				if obj.options == nil {
-					obj.options = make(map[string]*Flag)
-				}
-				obj.options[f.Name] = f
-			})
-		case reflect.Func:
-			obj.filterFunc = filter.Filter
+		c.filters = append(c.filters, c.newFilterObject(filter))
+	}
+	c.routes.Store(nil)
+	c.updateUsageLocked()
+}
+
+// FilterNames returns the names of the filters in order.
+func (c *Command) FilterNames() []string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	names := make([]string, len(c.filters))
+	for i, filter := range c.filters {
+		names[i] = filter.name
+	}
+	return names
+}
+
+// RemoveFilter removes the filter by index.
+// NOTE:
+//  it is allowed after the command tree is frozen;
+//  panic when the index is out of range
+func (c *Command) RemoveFilter(index int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.checkFilterIndex(index)
+	filters := make([]*filterObject, 0, len(c.filters)-1)
+	filters = append(filters, c.filters[:index]...)
+	c.filters = append(filters, c.filters[index+1:]...)
+	c.routes.Store(nil)
+	c.updateUsageLocked()
+}
+
+// RemoveFilterByName removes the first filter with the name,
+// and reports whether it exists.
+// NOTE:
+//  it is allowed after the command tree is frozen
+func (c *Command) RemoveFilterByName(name string) bool {
+	index := c.filterIndex(name)
+	if index < 0 {
+		return false
+	}
+	c.RemoveFilter(index)
+	return true
+}
+
+// ReplaceFilter replaces the filter by index.
+// NOTE:
+//  it is allowed after the command tree is frozen;
+//  panic when something goes wrong
+func (c *Command) ReplaceFilter(index int, filter Filter) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.checkFilterIndex(index)
+	filters := make([]*filterObject, len(c.filters))
+	copy(filters, c.filters)
+	filters[index] = c.newFilterObject(filter)
+	c.filters = filters
+	c.routes.Store(nil)
+	c.updateUsageLocked()
+}
+
+// ReplaceFilterByName replaces the first filter with the name,
+// and reports whether it exists.
+// NOTE:
+//  it is allowed after the command tree is frozen;
+//  panic when something goes wrong
+func (c *Command) ReplaceFilterByName(name string, filter Filter) bool {
+	index := c.filterIndex(name)
+	if index < 0 {
+		return false
+	}
+	c.ReplaceFilter(index, filter)
+	return true
+}
+
+func (c *Command) filterIndex(name string) int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	for i, filter := range c.filters {
+		if filter.name == name {
+			return i
 		}
-		c.filters = append(c.filters, &obj)
 	}
-	c.app.updateUsageLocked()
+	return -1
+}
+
+func (c *Command) checkFilterIndex(index int) {
+	if index < 0 || index >= len(c.filters) {
+		panic(fmt.Errorf("filter index out of range: %d, command: %q", index, c.PathString()))
+	}
+}
+
+func (c *Command) getFilters() []*filterObject {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.filters
+}
+
+func (c *Command) newFilterObject(filter Filter) *filterObject {
+	var obj filterObject
+	if nf, ok := filter.(*namedFilter); ok {
+		obj.name = nf.name
+		filter = nf.filter
+	} else {
+		obj.name = reflect.TypeOf(filter).String()
+	}
+	obj.flagSet = NewFlagSet(c.cmdName, ContinueOnError|ContinueOnUndefined)
+	elemType := ameda.DereferenceType(reflect.TypeOf(rawObject(filter)))
+	switch elemType.Kind() {
+	case reflect.Struct:
+		var ok bool
+		obj.factory, ok = filter.(FilterCopier)
+		if !ok {
+			obj.factory = (*factory)(newActionFactory(elemType))
+		}
+		err := obj.flagSet.StructVars(rawObject(obj.factory.DeepCopy()))
+		if err != nil {
+			panic(err)
+		}
+		obj.flagSet.VisitAll(func(f *Flag) {
+			if obj.options == nil {
+				obj.options = make(map[string]*Flag)
+			}
+			obj.options[f.Name] = f
+		})
+	case reflect.Func:
+		obj.filterFunc = filter
+	}
+	return &obj
 }
 
 // SetAction sets the action of the command.
 // NOTE:
-//  if action is a struct, it can implement the copier interface;
+//  if action is a struct, it can implement the copier interface, or Resetter to be pooled;
 //  panic when something goes wrong.
 func (c *Command) SetAction(action Action, scope ...Scope) {
+	c.checkMutable()
 	c.lock.Lock()
 	defer c.lock.Unlock()
 	if len(c.subcommands) > 0 {
@@ -141,15 +470,15 @@ func (c *Command) SetAction(action Action, scope ...Scope) {
 	var obj actionObject
 	obj.cmd = c
 	obj.flagSet = NewFlagSet(c.cmdName, ContinueOnError|ContinueOnUndefined)
-	elemType := ameda.DereferenceType(reflect.TypeOf(action))
+	elemType := ameda.DereferenceType(reflect.TypeOf(rawObject(action)))
 	switch elemType.Kind() {
 	case reflect.Struct:
 		var ok bool
 		obj.actionFactory, ok = action.(ActionCopier)
 		if !ok {
-			obj.actionFactory = &actionFactory{elemType: elemType}
+			obj.actionFactory = newActionFactory(elemType)
 		}
-		err := obj.flagSet.StructVars(obj.actionFactory.DeepCopy())
+		err := obj.flagSet.StructVars(rawObject(obj.actionFactory.DeepCopy()))
 		if err != nil {
 			panic(err)
 		}
@@ -166,95 +495,332 @@ func (c *Command) SetAction(action Action, scope ...Scope) {
 	if len(scope) > 0 {
 		c.scope = scope[0]
 	}
-	c.app.execScopeUsageTexts = make(map[Scope]string, len(c.app.execScopeUsageTexts))
+	c.routes.Store(nil)
 	c.bubbleSetScopeCmd(c.scope, nil)
-	c.app.updateUsageLocked()
+	c.updateUsageLocked()
 }
 
 func (c *Command) bubbleSetScopeCmd(scope Scope, subcmds []*Command) {
-	c.execScopeUsageTexts = make(map[Scope]string, len(c.execScopeUsageTexts))
 	if c.scopeCommandMap == nil {
 		c.scopeCommandMap = make(map[Scope][]*Command, 16)
 	}
 	cmds := append(subcmds, c)
-	c.scopeCommandMap[scope] = cmdsDistinctAndSort(append(c.scopeCommandMap[scope], cmds...))
-	c.scopeCommands = cmdsDistinctAndSort(append(c.scopeCommands, cmds...))
+	c.scopeCommandMap[scope] = cmdsInsertSorted(c.scopeCommandMap[scope], cmds...)
+	c.scopeCommands = cmdsInsertSorted(c.scopeCommands, cmds...)
 	if c.parent != nil {
 		c.parent.bubbleSetScopeCmd(scope, cmds)
 	}
 }
 
-func cmdsDistinctAndSort(cmds []*Command) []*Command {
-	m := make(map[*Command]bool, len(cmds))
+// cmdsInsertSorted inserts the commands that do not exist into the sorted list.
+func cmdsInsertSorted(list []*Command, cmds ...*Command) []*Command {
 	for _, cmd := range cmds {
-		m[cmd] = true
-	}
-	cmds = cmds[:0]
-	for cmd := range m {
-		cmds = append(cmds, cmd)
+		path := cmd.PathString()
+		i := sort.Search(len(list), func(i int) bool {
+			return list[i].PathString() >= path
+		})
+		if i < len(list) && list[i] == cmd {
+			continue
+		}
+		list = append(list, nil)
+		copy(list[i+1:], list[i:])
+		list[i] = cmd
 	}
-	sort.Sort(commandList(cmds))
-	return cmds
+	return list
 }
 
 // Exec executes the command.
 // NOTE:
 //  @arguments does not contain the command name;
-//  the default value of @scope is 0.
+//  the default value of @scope is 0;
+//  the error handler of app is invoked if the returned status is not OK.
 func (c *Command) Exec(ctx context.Context, arguments []string, execScope ...Scope) (stat *Status) {
-	defer status.Catch(&stat)
+	return c.exec(ctx, arguments, nil, execScope...)
+}
+
+// exec executes the command, and collects the result into @result if it is not nil.
+func (c *Command) exec(ctx context.Context, arguments []string, result *execResult, execScope ...Scope) (stat *Status) {
 	var s Scope
 	if len(execScope) > 0 {
 		s = execScope[0]
 	}
-	handle, ctxObj := c.route(ctx, arguments, s)
+	c.app.Freeze()
+	snap := c.app.snapshot()
+	if snap.shutdownEnabled {
+		ctx = c.app.trackShutdown(snap, ctx)
+		defer c.app.untrackShutdown(snap)
+	}
+	snap.ctx = ctx
+	if ctx != nil {
+		snap.env, _ = ctx.Value(envKey{}).(map[string]string)
+	}
+	if result != nil {
+		snap.result = result
+		snap.output = result.output
+	}
+	defer snap.release()
+	if snap.completion && c.parent == nil && len(arguments) > 0 && arguments[0] == CompleteCmdName {
+		return c.complete(ctx, snap, arguments[1:])
+	}
+	if format, ok := snap.extractVersionFlag(arguments); ok && c.parent == nil {
+		return c.printVersion(snap, format)
+	}
+	ctxObj := &Context{args: arguments, rawArgs: arguments, cmdPath: []string{c.cmdName}, Context: ctx, cmd: c, execScope: s, snap: snap}
+	if snap.auditor != nil {
+		start := time.Now()
+		defer func() {
+			snap.audit(ctxObj, start, stat)
+		}()
+	}
+	if snap.metrics != nil {
+		start := time.Now()
+		defer func() {
+			snap.observe(ctxObj, start, stat)
+		}()
+	}
+	defer func() {
+		if !stat.OK() && snap.errorHandler != nil {
+			snap.errorHandler(ctxObj, stat)
+		}
+	}()
+	defer func() {
+		if r := recover(); r != nil {
+			ctxObj.stopGoroutines()
+			stat = snap.recoverStatus(ctxObj, r)
+		} else if stat == nil {
+			stat = new(Status)
+		}
+	}()
+	rawArgs := arguments
+	arguments = snap.preRoute(ctx, arguments)
+	arguments = snap.loadConfig(c, arguments)
+	if c.parent == nil {
+		arguments = snap.parseGlobals(c, arguments)
+	}
+	var handle ActionFunc
+	handle, ctxObj = c.route(ctx, snap, []string{c.cmdName}, arguments, s)
+	ctxObj.rawArgs = rawArgs
+	if result != nil {
+		result.result.CmdPath = ctxObj.cmdPath
+	}
+	if snap.reloadEnabled {
+		c.app.trackReload(ctxObj)
+		defer c.app.untrackReload(ctxObj)
+	}
+	if snap.tracer != nil {
+		snap.tracer.RouteResolved(ctxObj)
+	}
 	handle(ctxObj)
+	ctxObj.Wait()
 	return
 }
 
-func (c *Command) route(ctx context.Context, arguments []string, execScope Scope) (ActionFunc, *Context) {
-	c.lock.RLock()
-	defer c.lock.RUnlock()
-	filters, action, cmdPath, cmd, found := c.findFiltersAndAction([]string{c.cmdName}, arguments, execScope)
+func (snap *execSnapshot) recoverStatus(ctxObj *Context, r interface{}) *Status {
+	switch v := r.(type) {
+	case *Status:
+		if v == nil {
+			return new(Status)
+		}
+		return v
+	case Status:
+		return &v
+	case *PanicError: // propagated from a goroutine started by Context.Go
+		panic(v)
+	}
+	if snap.propagatePanics {
+		panic(&PanicError{
+			CmdPath: ctxObj.CmdPath(),
+			Value:   r,
+			Stack:   debug.Stack(),
+		})
+	}
+	return NewStatusWithStack(status.UnknownError, "", r)
+}
+
+func (c *Command) route(ctx context.Context, snap *execSnapshot, cmdPath, arguments []string, execScope Scope) (ActionFunc, *Context) {
+	snap.outputFormat, snap.resolvers = "", nil
+	filters, action, cmdPath, cmd, found := c.findFiltersAndAction(snap, cmdPath, arguments, execScope)
+	// only the external command is found without the action of the command
+	external := found && cmd.routing().action == nil
+	if snap.result != nil && found && snap.result.result.Action == nil {
+		snap.result.result.Action = rawObject(action)
+	}
 	actionFunc := action.Execute
+	if snap.tracer != nil {
+		actionFunc = traceAction(snap.tracer, action)
+	}
+	if lockPath := cmd.routing().singleton; found && lockPath != "" {
+		actionFunc = singletonAction(lockPath, actionFunc)
+	}
 	if found {
 		for i := len(filters) - 1; i >= 0; i-- {
 			filter := filters[i]
 			nextAction := actionFunc
+			if snap.tracer != nil {
+				actionFunc = traceFilter(snap.tracer, filter, action, nextAction)
+				continue
+			}
 			actionFunc = func(c *Context) {
-				filter.Filter(c, nextAction)
+				callFilter(c, filter, action, nextAction)
 			}
 		}
+		actionFunc = resolveValues(snap.resolvers, actionFunc)
+		if snap.authorizer != nil {
+			actionFunc = authorizeAction(snap.authorizer, actionFunc)
+		}
 	}
-	return actionFunc, &Context{args: arguments, cmdPath: cmdPath, Context: ctx, cmd: cmd, execScope: execScope}
+	snap.resolvers = nil
+	return actionFunc, &Context{args: arguments, cmdPath: cmdPath, Context: ctx, cmd: cmd, execScope: execScope, snap: snap, filters: filters, outputFormat: snap.outputFormat, external: external}
 }
 
-func (c *Command) findFiltersAndAction(cmdPath, arguments []string, execScope Scope) ([]Filter, Action, []string, *Command, bool) {
-	if c.action != nil && c.app.scopeMatcherFunc != nil {
-		CheckStatus(c.app.scopeMatcherFunc(c.scope, execScope), StatusMismatchScope, "")
+// ExecCommand executes the command of the path under the same app, such as a composite command
+// `app release` calling `build` and `publish`, without shelling out to the own binary.
+// NOTE:
+//  @path excludes the app command name, such as []string{"build"};
+//  the settings of the app, the output, the environment and the executor scope of the current execution
+//  are shared, and the values set by SetValue are copied;
+//  only the filters of the command and its descendants are executed, not the ones of its ancestors;
+//  the error handler of app is not invoked, the caller handles the returned status.
+func (c *Context) ExecCommand(path []string, args []string) (stat *Status) {
+	cmd := c.cmd.Root()
+	for _, name := range path {
+		if cmd.Load() != nil {
+			break
+		}
+		if cmd = cmd.LookupSubcommand(name); cmd == nil {
+			break
+		}
 	}
-	filters, arguments := c.newFilters(arguments)
-	action, arguments, found := c.newAction(arguments)
+	if cmd == nil {
+		return c.snap.newStatus(StatusNotFound, "", c.snap.translate(MsgNotFound, strings.Join(append([]string{c.snap.cmdName}, path...), " ")))
+	}
+	// the nested execution has its own parsed flag sets, pooled objects and output format
+	snap := *c.snap
+	snap.result, snap.bindings, snap.recycled, snap.outputFormat = nil, nil, nil, ""
+	defer snap.release()
+	var ctxObj *Context
+	defer func() {
+		if r := recover(); r != nil {
+			if ctxObj != nil {
+				ctxObj.stopGoroutines()
+			}
+			stat = snap.recoverStatus(c, r)
+		} else if stat == nil {
+			stat = new(Status)
+		}
+	}()
+	var handle ActionFunc
+	handle, ctxObj = cmd.route(c.Context, &snap, cmd.Path(), args, c.execScope)
+	ctxObj.rawArgs = args
+	c.valuesLock.RLock()
+	for k, v := range c.values {
+		ctxObj.SetValue(k, v)
+	}
+	c.valuesLock.RUnlock()
+	handle(ctxObj)
+	ctxObj.Wait()
+	return
+}
+
+// routing returns the routing table of the command,
+// which is only rebuilt under the read lock after the command is modified.
+func (c *Command) routing() *routeTable {
+	if t := c.routes.Load(); t != nil {
+		return t
+	}
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	t := &routeTable{
+		filters:      c.filters,
+		action:       c.action,
+		scope:        c.scope,
+		notFound:     c.notFound,
+		outputFormat: c.outputFormat,
+		singleton:    c.singleton,
+		subcommands:  make(map[string]*Command, len(c.subcommands)),
+	}
+	names := make([]string, 0, len(c.subcommands))
+	for name, subCmd := range c.subcommands {
+		t.subcommands[name] = subCmd
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	t.sortedSubcommands = make([]*Command, len(names))
+	for i, name := range names {
+		t.sortedSubcommands[i] = c.subcommands[name]
+	}
+	// the writers hold the write lock, so the table can not be stale here
+	c.routes.Store(t)
+	return t
+}
+
+// lookupAbbreviation returns the subcommand whose name has the unique prefix,
+// or throws the status listing the candidates if the prefix is ambiguous.
+func (t *routeTable) lookupAbbreviation(snap *execSnapshot, cmdPath []string, prefix string) *Command {
+	var candidates []*Command
+	for _, subCmd := range t.sortedSubcommands {
+		if strings.HasPrefix(subCmd.cmdName, prefix) {
+			candidates = append(candidates, subCmd)
+		}
+	}
+	switch len(candidates) {
+	case 0:
+		return nil
+	case 1:
+		return candidates[0]
+	}
+	names := make([]string, len(candidates))
+	for i, subCmd := range candidates {
+		names[i] = subCmd.cmdName
+	}
+	snap.throwStatus(
+		StatusNotFound,
+		"",
+		snap.translate(MsgAmbiguousCommand, strings.Join(append(cmdPath, prefix), " "), strings.Join(names, ", ")),
+	)
+	return nil
+}
+
+func (c *Command) findFiltersAndAction(snap *execSnapshot, cmdPath, arguments []string, execScope Scope) ([]Filter, Action, []string, *Command, bool) {
+	snap.checkStatus(c.Load(), StatusLoadFailed, "")
+	t := c.routing()
+	if t.action != nil && snap.scopeMatcherFunc != nil {
+		snap.checkStatus(snap.scopeMatcherFunc(t.scope, execScope), StatusMismatchScope, "")
+	}
+	filters, arguments := c.newFilters(snap, t.filters, arguments)
+	if t.action != nil && t.outputFormat != "" {
+		snap.outputFormat, arguments = snap.extractOutputFlag(t.outputFormat, t.action.flagSet, arguments)
+	}
+	action, arguments, found := c.newAction(snap, t.action, arguments)
 	if found {
 		return filters, action, cmdPath, c, true
 	}
 	subCmdName, arguments := SplitArgs(arguments)
-	subCmd := c.subcommands[subCmdName]
+	subCmd := t.subcommands[subCmdName]
+	if subCmd == nil && subCmdName != "" && snap.parseMode&ParseAbbreviation != 0 {
+		subCmd = t.lookupAbbreviation(snap, cmdPath, subCmdName)
+		if subCmd != nil {
+			subCmdName = subCmd.cmdName
+		}
+	}
 	if subCmdName != "" {
 		cmdPath = append(cmdPath, subCmdName)
 	}
 	if subCmd == nil {
-		if c.app.notFound != nil {
-			return nil, c.app.notFound, cmdPath, c, false
+		if action := c.lookupExternalAction(snap, subCmdName, arguments); action != nil {
+			return filters, action, cmdPath, c, true
+		}
+		if notFound := c.lookupNotFound(snap); notFound != nil {
+			return nil, notFound, cmdPath, c, false
 		}
-		ThrowStatus(
+		snap.throwStatus(
 			StatusNotFound,
 			"",
-			fmt.Sprintf("not found command action: %q", strings.Join(cmdPath, " ")),
+			snap.translate(MsgNotFound, strings.Join(cmdPath, " ")),
 		)
 		return nil, nil, cmdPath, c, false
 	}
-	subFilters, action, cmdPath, subCmd2, found := subCmd.findFiltersAndAction(cmdPath, arguments, execScope)
+	subFilters, action, cmdPath, subCmd2, found := subCmd.findFiltersAndAction(snap, cmdPath, arguments, execScope)
 	if found {
 		filters = append(filters, subFilters...)
 		return filters, action, cmdPath, subCmd2, true
@@ -262,22 +828,38 @@ func (c *Command) findFiltersAndAction(cmdPath, arguments []string, execScope Sc
 	return nil, action, cmdPath, subCmd2, false
 }
 
-func (c *Command) newFilters(arguments []string) (r []Filter, args []string) {
-	r = make([]Filter, len(c.filters))
+// lookupNotFound returns the not found action of the nearest command, or the app.
+func (c *Command) lookupNotFound(snap *execSnapshot) ActionFunc {
+	for r := c; r != nil; r = r.parent {
+		if notFound := r.routing().notFound; notFound != nil {
+			return notFound
+		}
+	}
+	return snap.notFound
+}
+
+func (c *Command) newFilters(snap *execSnapshot, filters []*filterObject, arguments []string) (r []Filter, args []string) {
+	r = make([]Filter, len(filters))
 	args = arguments
-	for i, filter := range c.filters {
+	for i, filter := range filters {
 		if filter.filterFunc != nil {
 			r[i] = filter.filterFunc
 		} else {
 			flagSet := NewFlagSet(c.cmdName, filter.flagSet.ErrorHandling())
 			newObj := filter.factory.DeepCopy()
-			flagSet.StructVars(newObj)
-			err := flagSet.Parse(arguments)
-			CheckStatus(err, StatusParseFailed, "")
-			if c.app.validator != nil {
-				err = c.app.validator(newObj)
+			if f, ok := filter.factory.(*factory); ok {
+				snap.recycle((*actionFactory)(f), newObj)
+			}
+			rawObj := rawObject(newObj)
+			snap.checkStatus(flagSet.StructVars(rawObj), StatusParseFailed, "")
+			snap.setDiagnostics(c, flagSet, false)
+			flagSet.parseMode |= snap.parseMode
+			parseErr := snap.translateError(flagSet.Parse(arguments))
+			if flagSet.parseMode&ParseAllErrors == 0 {
+				snap.checkStatus(parseErr, StatusParseFailed, "")
 			}
-			CheckStatus(err, StatusValidateFailed, "")
+			snap.bind(c, flagSet)
+			snap.deferResolve(c, flagSet, rawObj, parseErr)
 			r[i] = newObj
 			nargs := flagSet.NextArgs()
 			if len(args) > len(nargs) {
@@ -288,8 +870,16 @@ func (c *Command) newFilters(arguments []string) (r []Filter, args []string) {
 	return r, args
 }
 
-func (c *Command) newAction(cmdline []string) (Action, []string, bool) {
-	a := c.action
+// checkValidated throws the status of the parse error collected by ParseAllErrors together with
+// the validation error, or the status of the validation error.
+func (snap *execSnapshot) checkValidated(parseErr, validateErr error) {
+	if parseErr != nil {
+		snap.checkStatus(errors.Join(parseErr, validateErr), StatusParseFailed, "")
+	}
+	snap.checkStatus(validateErr, StatusValidateFailed, "")
+}
+
+func (c *Command) newAction(snap *execSnapshot, a *actionObject, cmdline []string) (Action, []string, bool) {
 	if a == nil {
 		return nil, cmdline, false
 	}
@@ -300,14 +890,53 @@ func (c *Command) newAction(cmdline []string) (Action, []string, bool) {
 	}
 	flagSet := NewFlagSet(cmdName, a.flagSet.ErrorHandling())
 	newObj := a.actionFactory.DeepCopy()
-	flagSet.StructVars(newObj)
-	err := flagSet.Parse(cmdline)
-	CheckStatus(err, StatusParseFailed, "")
-	if a.cmd.app.validator != nil {
-		err = a.cmd.app.validator(newObj)
+	if h, ok := a.actionFactory.(*actionFactory); ok {
+		snap.recycle(h, newObj)
+	}
+	rawObj := rawObject(newObj)
+	snap.checkStatus(flagSet.StructVars(rawObj), StatusParseFailed, "")
+	snap.setDiagnostics(c, flagSet, true)
+	flagSet.parseMode |= snap.parseMode
+	if snap.ignoredFlagFunc != nil {
+		flagSet.ignoredFlagFunc = snap.ignoredFlagFunc
+	}
+	parseErr := snap.translateError(flagSet.Parse(cmdline))
+	if flagSet.parseMode&ParseAllErrors == 0 {
+		snap.checkStatus(parseErr, StatusParseFailed, "")
+	}
+	snap.bind(c, flagSet)
+	snap.deferResolve(c, flagSet, rawObj, parseErr)
+	return newObj, flagSet.NextArgs(), true
+}
+
+// deferResolve defers resolving the values of the parsed flag set from the environment, the remote source,
+// the config, the defaults providers and the secret resolver, and validating the object,
+// until the command is resolved and the execution is authorized, see resolveValues.
+func (snap *execSnapshot) deferResolve(c *Command, flagSet *FlagSet, rawObj interface{}, parseErr error) {
+	snap.resolvers = append(snap.resolvers, func() {
+		snap.checkStatus(snap.applyEnv(c, flagSet), StatusParseFailed, "")
+		snap.checkStatus(snap.applyRemote(c, flagSet), StatusConfigFailed, "")
+		snap.checkStatus(snap.applyConfig(c, flagSet), StatusParseFailed, "")
+		snap.checkStatus(snap.applyDefaults(c, flagSet), StatusParseFailed, "")
+		snap.checkStatus(flagSet.expandValues(snap.expander), StatusParseFailed, "")
+		snap.checkStatus(flagSet.resolveSecrets(snap.secretResolver), StatusConfigFailed, "")
+		var err error
+		if snap.validator != nil {
+			err = snap.validator(rawObj)
+		}
+		snap.checkValidated(parseErr, err)
+	})
+}
+
+// resolveValues wraps the handler to resolve the values of the parsed flag sets before it,
+// in the order of the command path.
+func resolveValues(resolvers []func(), handler ActionFunc) ActionFunc {
+	return func(c *Context) {
+		for _, resolve := range resolvers {
+			resolve()
+		}
+		handler(c)
 	}
-	CheckStatus(err, StatusValidateFailed, "")
-	return newObj.(Action), flagSet.NextArgs(), true
 }
 
 // CmdName returns the command name of the command.
@@ -371,17 +1000,39 @@ func (c *Command) LookupSubcommand(pathCmdNames ...string) *Command {
 	return r
 }
 
-// Subcommands returns the subcommands.
+// Subcommands returns the subcommands sorted by name.
+// NOTE:
+//  the sorted list is cached until the command is modified, it must not be modified.
 func (c *Command) Subcommands() []*Command {
-	names := make([]string, 0, len(c.subcommands))
-	for name := range c.subcommands {
-		names = append(names, name)
+	return c.routing().sortedSubcommands
+}
+
+// Walk walks the command and all its descendants in depth-first order, sorted by name,
+// calling fn for each; the walk stops if fn returns false.
+// NOTE:
+//  the lazy commands that are not loaded have no descendants;
+//  reports whether the whole tree is walked.
+func (c *Command) Walk(fn func(*Command) bool) bool {
+	if !fn(c) {
+		return false
 	}
-	sort.Strings(names)
-	cmds := make([]*Command, len(names))
-	for i, name := range names {
-		cmds[i] = c.subcommands[name]
+	for _, subCmd := range c.Subcommands() {
+		if !subCmd.Walk(fn) {
+			return false
+		}
 	}
+	return true
+}
+
+// AllCommands returns all the descendant commands in depth-first order, sorted by name.
+func (c *Command) AllCommands() []*Command {
+	var cmds []*Command
+	c.Walk(func(cmd *Command) bool {
+		if cmd != c {
+			cmds = append(cmds, cmd)
+		}
+		return true
+	})
 	return cmds
 }
 
@@ -405,6 +1056,26 @@ func (c *Command) FindActionCommands(execScope ...Scope) []*Command {
 	return list
 }
 
+func (c *Command) lookupFlag(name string) *Flag {
+	for r := c; r != nil; r = r.parent {
+		r.lock.RLock()
+		if r.action != nil {
+			if f := r.action.flagSet.Lookup(name); f != nil {
+				r.lock.RUnlock()
+				return f
+			}
+		}
+		for i := len(r.filters) - 1; i >= 0; i-- {
+			if f := r.filters[i].flagSet.Lookup(name); f != nil {
+				r.lock.RUnlock()
+				return f
+			}
+		}
+		r.lock.RUnlock()
+	}
+	return nil
+}
+
 // Flags returns the formal flags.
 func (c *Command) Flags() map[string]*Flag {
 	if c.action == nil {
@@ -413,6 +1084,32 @@ func (c *Command) Flags() map[string]*Flag {
 	return c.action.options
 }
 
+// FilterFlags returns the formal flags of the filters.
+func (c *Command) FilterFlags() map[string]*Flag {
+	var flags map[string]*Flag
+	for _, filter := range c.getFilters() {
+		for name, f := range filter.options {
+			if flags == nil {
+				flags = make(map[string]*Flag, len(filter.options))
+			}
+			flags[name] = f
+		}
+	}
+	return flags
+}
+
+// HasAction reports whether the command has an action.
+func (c *Command) HasAction() bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.action != nil
+}
+
+// Description returns the description of the command.
+func (c *Command) Description() string {
+	return c.description
+}
+
 // ParentVisible returns the visibility in parent command usage.
 func (c *Command) ParentVisible() bool {
 	return c.parentUsageVisible
@@ -420,13 +1117,24 @@ func (c *Command) ParentVisible() bool {
 
 // SetParentVisible sets the visibility in parent command usage.
 func (c *Command) SetParentVisible(visible bool) {
+	c.checkMutable()
+	c.lock.Lock()
+	defer c.lock.Unlock()
 	c.parentUsageVisible = visible
+	c.updateUsageLocked()
 }
 
 // UsageText returns the usage text by by the executor scope.
 // NOTE:
 //  if @scopes is empty, all command usage are returned.
 func (c *Command) UsageText(execScope ...Scope) string {
+	c.app.lock.RLock()
+	defer c.app.lock.RUnlock()
+	c.app.renderUsageIfDirtyLocked()
+	return c.usageTextLocked(execScope...)
+}
+
+func (c *Command) usageTextLocked(execScope ...Scope) string {
 	fn := c.app.scopeMatcherFunc
 	if len(execScope) == 0 || fn == nil {
 		return c.usageText
@@ -460,61 +1168,180 @@ func (c *Command) UsageText(execScope ...Scope) string {
 	return txt
 }
 
+func (c *Command) resetExecScopeUsageTexts() {
+	c.execScopeUsageTextsLock.Lock()
+	c.execScopeUsageTexts = nil
+	c.execScopeUsageTextsLock.Unlock()
+	for _, subCmd := range c.subcommands {
+		subCmd.resetExecScopeUsageTexts()
+	}
+}
+
+// updateUsageLocked marks the usage of the command itself stale,
+// so that only it and the cache of its ancestors are rendered again.
 func (c *Command) updateUsageLocked() {
-	c.usageText = c.newUsageLocked()
+	c.app.usageLock.Lock()
+	c.usageStale = true
+	c.app.usageDirty = true
+	if c.parent == nil { // the examples of app are rendered by the app template
+		c.app.usageFrame.Store(nil)
+	}
+	c.app.usageLock.Unlock()
+}
+
+// markUsageStaleLocked marks the usage of all the commands in the subtree stale.
+func (c *Command) markUsageStaleLocked() {
+	c.usageStale = true
+	for _, subCmd := range c.subcommands {
+		subCmd.markUsageStaleLocked()
+	}
+}
+
+// renderStaleUsageLocked renders the usage of the stale commands in the subtree,
+// rebuilds the usage of the subtrees containing them, and returns them.
+func (c *Command) renderStaleUsageLocked() (stale []*Command) {
+	if c.usageStale {
+		c.ownUsageText = c.newUsageLocked()
+		c.usageStale = false
+		stale = append(stale, c)
+	}
 	subcommands := c.Subcommands()
 	for _, subCmd := range subcommands {
-		subCmd.updateUsageLocked()
+		stale = append(stale, subCmd.renderStaleUsageLocked()...)
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+	var b strings.Builder
+	b.WriteString(c.ownUsageText)
+	for _, subCmd := range subcommands {
 		if subCmd.parentUsageVisible {
-			c.usageText += subCmd.usageText
+			b.WriteString(subCmd.usageText)
 		}
 	}
+	c.usageText = b.String()
+	c.dropExecScopeUsageTexts(stale)
+	return stale
+}
+
+// dropExecScopeUsageTexts drops the cached usage of the executor scopes in which
+// any of the commands is visible.
+func (c *Command) dropExecScopeUsageTexts(cmds []*Command) {
+	fn := c.app.scopeMatcherFunc
+	c.execScopeUsageTextsLock.Lock()
+	defer c.execScopeUsageTextsLock.Unlock()
+	for scope := range c.execScopeUsageTexts {
+		for _, cmd := range cmds {
+			if fn == nil || cmd.visibleInScope(fn, scope) {
+				delete(c.execScopeUsageTexts, scope)
+				break
+			}
+		}
+	}
+}
+
+// visibleInScope reports whether the command is in the usage of the executor scope.
+func (c *Command) visibleInScope(fn func(cmdScope, execScope Scope) error, execScope Scope) bool {
+	for s := range c.scopeCommandMap {
+		if fn(s, execScope) == nil {
+			return true
+		}
+	}
+	return false
 }
 
 func (c *Command) createUsageLocked(m map[*Command]bool) string {
 	if !m[c] {
 		return ""
 	}
-	usageText := c.newUsageLocked()
+	var b strings.Builder
+	b.WriteString(c.ownUsageText)
 	for _, subCmd := range c.Subcommands() {
 		if subCmd.parentUsageVisible {
-			usageText += subCmd.createUsageLocked(m)
+			b.WriteString(subCmd.createUsageLocked(m))
 		}
 	}
-	return usageText
+	return b.String()
 }
 
-func (c *Command) newUsageLocked() (text string) {
-	var buf bytes.Buffer
-	flags := make([]*Flag, 0, len(c.filters)+1)
-	for _, filter := range c.filters {
-		filter.flagSet.RangeAll(func(f *Flag) {
-			flags = append(flags, f)
-		})
+func (c *Command) newUsageLocked() string {
+	flags := c.orderedFlagsLocked()
+	if f := c.outputFlagObject(); f != nil {
+		flags = append(flags, f)
 	}
-	if c.action != nil {
-		c.action.flagSet.RangeAll(func(f *Flag) {
-			flags = append(flags, f)
-		})
+	if c.parent == nil {
+		flags = append(c.app.globalFlagObjects(), flags...)
+		if f := c.app.versionFlagObject(); f != nil {
+			flags = append([]*Flag{f}, flags...)
+		}
+		if f := c.app.configFlagObject(); f != nil {
+			flags = append([]*Flag{f}, flags...)
+		}
 	}
-	fn := newPrintOneDefault(&buf, true)
-	for _, f := range flags {
-		fn(f)
+	var env func(*Flag) string
+	if prefix := c.app.envPrefix; prefix != "" {
+		cmdPath := c.Path()
+		env = func(f *Flag) string {
+			if IsNonFlag(f) || (c.parent == nil && (f.Name == c.app.configFlag || f.Name == c.app.versionFlag)) || (c.outputFormat != "" && f.Name == outputFlagName) {
+				return ""
+			}
+			return envName(prefix, cmdPath, f.Name)
+		}
 	}
-	body := buf.String()
+	var b strings.Builder
 	if c.parent != nil { // non-global command
-		var ellipsis string
+		cmdPath := "$" + c.PathString()
+		if c.app.colored {
+			cmdPath = paint(colorCommand, cmdPath)
+		}
+		b.WriteString(cmdPath)
 		if c.action == nil {
-			ellipsis = " ..."
+			b.WriteString(" ...")
+		} else if name := c.app.scopeNameLocked(c.scope); name != "" {
+			b.WriteString(" [" + name + "]")
+		}
+		b.WriteString("\n  " + c.description + "\n")
+		if c.usageHeader != "" { // the header of app is rendered by the app template
+			b.WriteString(goutil.Indent(strings.TrimRight(c.usageHeader, "\n"), "  ") + "\n")
 		}
-		text = fmt.Sprintf("$%s%s\n  %s\n", c.PathString(), ellipsis, c.description)
+	}
+	// the flags of the global command are not indented
+	var indent string
+	if c.parent != nil {
+		indent = "  "
+	}
+	var body strings.Builder
+	for _, f := range flags {
+		prefix := "-"
+		if IsNonFlag(f) {
+			prefix = ""
+		}
+		writeOneDefault(&body, f, indent, prefix, len(f.Name) <= 1, env)
+	}
+	if c.app.colored {
+		b.WriteString(colorizeFlags(body.String()))
 	} else {
-		body = strings.Replace(body, "  -", "-", -1)
-		body = strings.Replace(body, "\n    \t", "\n  \t", -1)
+		b.WriteString(body.String())
+	}
+	if c.parent != nil && len(c.examples) > 0 { // the examples of app are rendered by the app template
+		b.WriteString("  " + c.app.translate(MsgExamples) + ":\n")
+		for _, e := range c.examples {
+			b.WriteString("    $ " + e.Cmdline + "\n")
+			if e.Description != "" {
+				b.WriteString("      " + e.Description + "\n")
+			}
+		}
+	}
+	if c.parent != nil && len(c.seeAlso) > 0 { // the see-also of app is rendered by the app template
+		b.WriteString("  " + c.app.translate(MsgSeeAlso) + ":\n")
+		for _, line := range c.seeAlsoLocked() {
+			b.WriteString("    " + line + "\n")
+		}
+	}
+	if c.parent != nil && c.usageFooter != "" { // the footer of app is rendered by the app template
+		b.WriteString(goutil.Indent(strings.TrimRight(c.usageFooter, "\n"), "  ") + "\n")
 	}
-	body = strings.Replace(body, "-?", "?", -1)
-	text += body
-	return text
+	return b.String()
 }
 
 type commandList []*Command
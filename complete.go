@@ -0,0 +1,242 @@
+package flagx
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// CompleteCmdName the name of the hidden command printing the completions, see SetCompletion.
+const CompleteCmdName = "__complete"
+
+type (
+	// CompletionDirective the bit flags telling the shell how to handle the completions,
+	// which is compatible with the cobra completion scripts.
+	CompletionDirective int
+	// CompletionFunc returns the candidate values of the flag or non-flag with the directive,
+	// @args are the arguments of the command before the one being completed.
+	CompletionFunc func(ctx context.Context, args []string, toComplete string) ([]string, CompletionDirective)
+)
+
+// Completion directives
+const (
+	CompletionDefault    CompletionDirective = 0      // Use the default completion of the shell, such as the file names
+	CompletionError      CompletionDirective = 1 << 0 // An error occurred and the completions should be ignored
+	CompletionNoSpace    CompletionDirective = 1 << 1 // Do not add a space after the completion
+	CompletionNoFileComp CompletionDirective = 1 << 2 // Do not fall back to the file completion
+	CompletionKeepOrder  CompletionDirective = 1 << 5 // Keep the order of the completions
+)
+
+// SetCompletion sets whether to serve the hidden "__complete <args> <toComplete>" command,
+// which prints the candidate completions, one per line with the tab separated description,
+// and then the directive line such as ":4" to the output, see SetOutput.
+// NOTE:
+//  the completions cover the subcommands, the flags and the values returned by the CompletionFunc;
+//  the generated shell scripts call it, such as `testapp __complete sub -f ""`.
+func (a *App) SetCompletion(enable bool) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.completion = enable
+}
+
+// SetCompletionFunc sets the function returning the candidate values of the flag or non-flag,
+// such as "name" and "?0".
+// NOTE:
+//  nil deletes the function;
+//  panic when the command tree is frozen
+func (c *Command) SetCompletionFunc(name string, fn CompletionFunc) {
+	c.checkMutable()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if fn == nil {
+		delete(c.completionFuncs, name)
+		return
+	}
+	if c.completionFuncs == nil {
+		c.completionFuncs = make(map[string]CompletionFunc, 4)
+	}
+	c.completionFuncs[name] = fn
+}
+
+// complete prints the completions of the last argument and the directive.
+func (c *Command) complete(ctx context.Context, snap *execSnapshot, arguments []string) *Status {
+	output := snap.output
+	if output == nil {
+		output = os.Stdout
+	}
+	var toComplete string
+	if n := len(arguments); n > 0 {
+		toComplete = arguments[n-1]
+		arguments = arguments[:n-1]
+	}
+	candidates, directive := c.completions(ctx, arguments, toComplete)
+	writeCompletions(output, candidates, directive)
+	return new(Status)
+}
+
+// completions returns the candidates of @toComplete after walking the command tree by @arguments.
+func (c *Command) completions(ctx context.Context, arguments []string, toComplete string) ([]string, CompletionDirective) {
+	cmd := c
+	var args []string    // The arguments of cmd
+	var nonFlagIndex int // The index of the next non-flag
+	var valueOf *Flag    // The flag waiting for the value
+	terminated := false
+	for _, arg := range arguments {
+		switch {
+		case valueOf != nil:
+			valueOf = nil
+		case !terminated && arg == "--":
+			terminated = true
+		case !terminated && isFlagArg(arg):
+			name, _, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+			if fl := cmd.completionFlag(name); fl != nil && !hasValue && !isBoolFlag(fl) {
+				valueOf = fl
+			}
+		default:
+			if !terminated {
+				if subCmd := cmd.LookupSubcommand(arg); subCmd != nil {
+					subCmd.Load()
+					cmd, args, nonFlagIndex = subCmd, nil, 0
+					continue
+				}
+			}
+			nonFlagIndex++
+		}
+		args = append(args, arg)
+	}
+	switch {
+	case valueOf != nil:
+		return cmd.completeValue(ctx, valueOf.Name, args, toComplete, "")
+	case !terminated && strings.HasPrefix(toComplete, "-"):
+		dashes := "-"
+		if strings.HasPrefix(toComplete, "--") {
+			dashes = "--"
+		}
+		name, value, hasValue := strings.Cut(toComplete[len(dashes):], "=")
+		if hasValue {
+			return cmd.completeValue(ctx, name, args, value, dashes+name+"=")
+		}
+		var candidates []string
+		for _, fl := range cmd.completionFlags() {
+			if strings.HasPrefix(fl.Name, name) {
+				_, usage := UnquoteUsage(fl)
+				candidates = append(candidates, completionLine(dashes+fl.Name, usage))
+			}
+		}
+		return candidates, CompletionNoFileComp
+	}
+	if fn := cmd.completionFunc(getNonFlagName(nonFlagIndex)); fn != nil {
+		return fn(ctx, args, toComplete)
+	}
+	if terminated {
+		return nil, CompletionDefault
+	}
+	var candidates []string
+	for _, subCmd := range cmd.Subcommands() {
+		if subCmd.parentUsageVisible && strings.HasPrefix(subCmd.cmdName, toComplete) {
+			candidates = append(candidates, completionLine(subCmd.cmdName, subCmd.description))
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, CompletionDefault
+	}
+	return candidates, CompletionNoFileComp
+}
+
+// completeValue returns the candidate values of the flag prefixed by @prefix.
+func (c *Command) completeValue(ctx context.Context, name string, args []string, toComplete, prefix string) ([]string, CompletionDirective) {
+	fn := c.completionFunc(name)
+	if fn == nil {
+		if fl := c.completionFlag(name); fl != nil && isBoolFlag(fl) {
+			fn = func(context.Context, []string, string) ([]string, CompletionDirective) {
+				return []string{"true", "false"}, CompletionNoFileComp
+			}
+		} else if fl != nil && fl.Name == outputFlagName && c.OutputFormat() != "" {
+			fn = func(context.Context, []string, string) ([]string, CompletionDirective) {
+				return []string{string(OutputText), string(OutputJSON), string(OutputYAML)}, CompletionNoFileComp
+			}
+		} else {
+			return nil, CompletionDefault
+		}
+	}
+	values, directive := fn(ctx, args, toComplete)
+	candidates := make([]string, 0, len(values))
+	for _, v := range values {
+		if strings.HasPrefix(v, toComplete) {
+			candidates = append(candidates, prefix+v)
+		}
+	}
+	return candidates, directive
+}
+
+func (c *Command) completionFunc(name string) CompletionFunc {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.completionFuncs[name]
+}
+
+// completionFlags returns the flags of the filters and the action, excluding the non-flags.
+func (c *Command) completionFlags() []*Flag {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	var flags []*Flag
+	if c.parent == nil {
+		c.app.lock.RLock()
+		if f := c.app.configFlagObject(); f != nil {
+			flags = append(flags, f)
+		}
+		if f := c.app.versionFlagObject(); f != nil {
+			flags = append(flags, f)
+		}
+		for _, f := range c.app.globalFlagObjects() {
+			if !IsNonFlag(f) {
+				flags = append(flags, f)
+			}
+		}
+		c.app.lock.RUnlock()
+	}
+	for _, filter := range c.filters {
+		filter.flagSet.VisitAll(func(f *Flag) {
+			flags = append(flags, f)
+		})
+	}
+	if c.action != nil && c.action.actionFunc == nil {
+		c.action.flagSet.VisitAll(func(f *Flag) {
+			flags = append(flags, f)
+		})
+	}
+	if f := c.outputFlagObject(); f != nil {
+		flags = append(flags, f)
+	}
+	return flags
+}
+
+func (c *Command) completionFlag(name string) *Flag {
+	for _, fl := range c.completionFlags() {
+		if fl.Name == name {
+			return fl
+		}
+	}
+	return nil
+}
+
+// completionLine returns the completion with the tab separated description.
+func completionLine(candidate, description string) string {
+	if description = strings.TrimSpace(strings.SplitN(description, "\n", 2)[0]); description == "" {
+		return candidate
+	}
+	return candidate + "\t" + description
+}
+
+// writeCompletions writes the completions one per line and then the directive line.
+func writeCompletions(w io.Writer, candidates []string, directive CompletionDirective) {
+	var b strings.Builder
+	for _, s := range candidates {
+		b.WriteString(s + "\n")
+	}
+	b.WriteString(":" + strconv.Itoa(int(directive)) + "\n")
+	fmt.Fprint(w, b.String())
+}
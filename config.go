@@ -0,0 +1,392 @@
+package flagx
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ConfigDecoder decodes the config data into a map, whose nested keys are
+// joined by '.' as the flag names, such as {"db":{"host":""}} to -db.host
+type ConfigDecoder func(data []byte) (map[string]interface{}, error)
+
+// JSONDecoder decodes the JSON config data.
+func JSONDecoder(data []byte) (map[string]interface{}, error) {
+	var m map[string]interface{}
+	err := json.Unmarshal(data, &m)
+	return m, err
+}
+
+// LoadConfigFile reads the config file and flattens it into the flag values.
+func LoadConfigFile(filename string, decoder ConfigDecoder) (map[string]string, error) {
+	return loadConfigFile(nil, filename, decoder)
+}
+
+// loadConfigFile reads the config file from the file system and flattens it into the flag values.
+func loadConfigFile(fsys fs.FS, filename string, decoder ConfigDecoder) (map[string]string, error) {
+	data, err := readFile(fsys, filename)
+	if err != nil {
+		return nil, err
+	}
+	m, err := decoder(data)
+	if err != nil {
+		return nil, fmt.Errorf("flagx: decode config file %s: %v", filename, err)
+	}
+	values := make(map[string]string, len(m))
+	flattenConfig(values, "", m)
+	return values, nil
+}
+
+func flattenConfig(values map[string]string, prefix string, m map[string]interface{}) {
+	for k, v := range m {
+		if sub, ok := v.(map[string]interface{}); ok {
+			flattenConfig(values, prefix+k+".", sub)
+			continue
+		}
+		if s, ok := configValueString(v); ok {
+			values[prefix+k] = s
+		}
+	}
+}
+
+// configValueString formats the config value as the flag value,
+// the elements of an array are joined by ','.
+func configValueString(v interface{}) (string, bool) {
+	switch x := v.(type) {
+	case nil:
+		return "", false
+	case string:
+		return x, true
+	case bool:
+		return strconv.FormatBool(x), true
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64), true
+	case []interface{}:
+		a := make([]string, 0, len(x))
+		for _, e := range x {
+			s, ok := configValueString(e)
+			if !ok {
+				return "", false
+			}
+			a = append(a, s)
+		}
+		return strings.Join(a, ","), true
+	case []string:
+		return strings.Join(x, ","), true
+	case map[string]interface{}:
+		return "", false
+	default:
+		return fmt.Sprint(x), true
+	}
+}
+
+// BindConfigFile loads the values of the flags that are not set on the command line from the config file.
+// NOTE:
+//  the precedence is command line > config file > default;
+//  if the flag set has been parsed, the values are applied immediately.
+func (f *FlagSet) BindConfigFile(filename string, decoder ConfigDecoder) error {
+	values, err := loadConfigFile(f.fsys, filename, decoder)
+	if err != nil {
+		return err
+	}
+	f.configFiles = append(f.configFiles, configFile{filename: filename, decoder: decoder})
+	if f.config == nil {
+		f.config = make(map[string]string, len(values))
+		f.configFrom = make(map[string]string, len(values))
+	}
+	for k, v := range values {
+		f.config[k] = v
+		f.configFrom[k] = filename
+	}
+	if f.Parsed() {
+		return f.applyConfig(f.config, f.configFileOf)
+	}
+	return nil
+}
+
+// applyConfig sets the flags that are not set on the command line by the config values,
+// @from returns the config file name of the flag.
+// NOTE:
+//  the value with the first matched prefix is used, and the one without prefix is the last.
+func (f *FlagSet) applyConfig(values map[string]string, from func(name string) string, prefixes ...string) error {
+	if len(values) == 0 {
+		return nil
+	}
+	for _, name := range f.unsetFlagNames() {
+		value, ok := lookupConfig(values, name, prefixes)
+		if !ok {
+			continue
+		}
+		if err := f.setJoinedFlag(name, value); err != nil {
+			return fmt.Errorf("invalid value %q for flag -%s from config: %v", value, name, err)
+		}
+		f.setOrigin(name, SourceConfig, value, from(name))
+	}
+	return nil
+}
+
+// setJoinedFlag sets the flag by the value from the config file or defaults provider,
+// whose array elements are joined by ',', see configValueString.
+// NOTE:
+//  the elements are set one by one for the slice flag.
+func (f *FlagSet) setJoinedFlag(name, value string) error {
+	fl := f.FlagSet.Lookup(name)
+	if fl == nil || value == "" || !isSliceFlag(fl) {
+		return f.setFlag(name, value)
+	}
+	for _, elem := range strings.Split(value, ",") {
+		if err := f.setFlag(name, elem); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// configFileOf returns the name of the bound config file that provides the value of the flag.
+func (f *FlagSet) configFileOf(name string) string {
+	return f.configFrom[name]
+}
+
+// unsetFlagNames returns the sorted names of the flags that are not set.
+func (f *FlagSet) unsetFlagNames() []string {
+	actual := make(map[string]bool, 8)
+	f.FlagSet.Visit(func(fl *Flag) {
+		actual[fl.Name] = true
+	})
+	var names []string
+	f.FlagSet.VisitAll(func(fl *Flag) {
+		if !actual[fl.Name] {
+			names = append(names, fl.Name)
+		}
+	})
+	sort.Strings(names)
+	return names
+}
+
+func lookupConfig(values map[string]string, name string, prefixes []string) (string, bool) {
+	for _, prefix := range prefixes {
+		if v, ok := values[prefix+name]; ok {
+			return v, true
+		}
+	}
+	v, ok := values[name]
+	return v, ok
+}
+
+// SetConfigFlag sets the name of the global flag that specifies the config file,
+// such as "config" for -config=app.json, and the decoder of the file.
+// NOTE:
+//  if @decoder is nil, it is chosen by the file extension, see ConfigDecoderByExt;
+//  the values of a command are looked up by the key prefixed with its path first,
+//  such as "sub.name" for -name of subcommand "sub", then by the flag name;
+//  the precedence is command line > config file > default.
+func (a *App) SetConfigFlag(name string, decoder ConfigDecoder) {
+	name = strings.TrimLeft(name, "-")
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.configFlag = name
+	a.configDecoder = decoder
+	a.resetUsageLocked()
+}
+
+// DefaultConfigSearchPaths the default directories to discover the config file,
+// "{name}" is replaced with the command name of the app.
+var DefaultConfigSearchPaths = []string{".", "$XDG_CONFIG_HOME/{name}", "/etc/{name}"}
+
+// DefaultConfigNames the default file name patterns to discover the config file,
+// "{name}" is replaced with the command name of the app.
+var DefaultConfigNames = []string{
+	"{name}.json", "{name}.toml", "{name}.ini",
+	"config.json", "config.toml", "config.ini",
+}
+
+// SetConfigSearchPaths sets the directories to discover the config file
+// when the config flag is not provided, so that the config flag becomes optional.
+// NOTE:
+//  if @paths is empty, DefaultConfigSearchPaths is used;
+//  "{name}" is replaced with the command name of the app, and the environment variables are expanded,
+//  $XDG_CONFIG_HOME defaults to $HOME/.config;
+//  the first existing file matched by the names (see SetConfigNames) in the first directory is used.
+func (a *App) SetConfigSearchPaths(paths ...string) {
+	if len(paths) == 0 {
+		paths = DefaultConfigSearchPaths
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.configSearchPaths = append([]string(nil), paths...)
+}
+
+// SetConfigNames sets the file name patterns to discover the config file.
+// NOTE:
+//  if @names is empty, DefaultConfigNames is used;
+//  "{name}" is replaced with the command name of the app.
+func (a *App) SetConfigNames(names ...string) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.configNames = append([]string(nil), names...)
+}
+
+// ConfigDecoderByExt returns the config decoder by the file extension, or nil if it is unknown.
+func ConfigDecoderByExt(ext string) ConfigDecoder {
+	switch strings.ToLower(strings.TrimPrefix(ext, ".")) {
+	case "json":
+		return JSONDecoder
+	case "toml":
+		return TOMLDecoder
+	case "ini", "properties", "conf", "cfg":
+		return INIDecoder
+	}
+	return nil
+}
+
+// configFlagObject returns the flag object of the config flag for the usage.
+func (a *App) configFlagObject() *Flag {
+	if a.configFlag == "" {
+		return nil
+	}
+	return &Flag{Name: a.configFlag, Usage: "load the flag values from the config `file`", Value: new(stringValue)}
+}
+
+// loadConfig extracts the config flag from the arguments and loads the config file.
+func (snap *execSnapshot) loadConfig(c *Command, arguments []string) []string {
+	if snap.configFlag == "" && len(snap.configSearchPaths) == 0 {
+		return arguments
+	}
+	filename, arguments := snap.extractConfigFlag(c, arguments)
+	if filename == "" {
+		filename = snap.discoverConfig()
+		if filename == "" {
+			return arguments
+		}
+	}
+	decoder := snap.configDecoder
+	if decoder == nil {
+		decoder = ConfigDecoderByExt(filepath.Ext(filename))
+		if decoder == nil {
+			snap.throwStatus(StatusConfigFailed, "", fmt.Sprintf("unknown config file format: %s", filename))
+		}
+	}
+	values, err := loadConfigFile(snap.fsys, filename, decoder)
+	snap.checkStatus(err, StatusConfigFailed, "")
+	snap.config = values
+	snap.configFile = filename
+	snap.configFileDecoder = decoder
+	return arguments
+}
+
+// extractConfigFlag returns the config file specified by the config flag and the rest arguments.
+// NOTE:
+//  only the leading flags of the app before the first non-flag are scanned, and the values of the flags
+//  of the command are skipped, so the config flag of a subcommand or an external command is left to it,
+//  such as `-name -config` or `sub -config x`.
+func (snap *execSnapshot) extractConfigFlag(c *Command, arguments []string) (string, []string) {
+	if snap.configFlag == "" {
+		return "", arguments
+	}
+	var filename string
+	var found bool
+	args := make([]string, 0, len(arguments))
+	for i := 0; i < len(arguments); i++ {
+		arg := arguments[i]
+		if arg == "--" || len(arg) < 2 || arg[0] != '-' {
+			args = append(args, arguments[i:]...)
+			break
+		}
+		name := strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+		name, value, hasValue := strings.Cut(name, "=")
+		if name != snap.configFlag {
+			args = append(args, arg)
+			if fl := c.lookupLeadingFlag(name); !hasValue && fl != nil && !isBoolFlag(fl) && i+1 < len(arguments) {
+				i++
+				args = append(args, arguments[i])
+			}
+			continue
+		}
+		if !hasValue {
+			if i+1 >= len(arguments) {
+				snap.throwStatus(StatusBadArgs, "", fmt.Sprintf("flag needs an argument: -%s", snap.configFlag))
+			}
+			i++
+			value = arguments[i]
+		}
+		filename, found = value, true
+	}
+	if !found {
+		return "", arguments
+	}
+	return filename, args
+}
+
+// lookupLeadingFlag returns the flag of the app global flags, the filters or the action of the command,
+// which may lead the arguments, or nil.
+func (c *Command) lookupLeadingFlag(name string) *Flag {
+	if c.parent == nil {
+		c.app.lock.RLock()
+		globals := c.app.globals
+		c.app.lock.RUnlock()
+		if globals != nil {
+			if fl := globals.Lookup(name); fl != nil {
+				return fl
+			}
+		}
+	}
+	t := c.routing()
+	for _, filter := range t.filters {
+		if filter.flagSet == nil {
+			continue
+		}
+		if fl := filter.flagSet.Lookup(name); fl != nil {
+			return fl
+		}
+	}
+	if t.action != nil && t.action.flagSet != nil {
+		return t.action.flagSet.Lookup(name)
+	}
+	return nil
+}
+
+// discoverConfig returns the first existing config file in the search paths, or "" if not found.
+func (snap *execSnapshot) discoverConfig() string {
+	names := snap.configNames
+	if len(names) == 0 {
+		names = DefaultConfigNames
+	}
+	replacer := strings.NewReplacer("{name}", snap.cmdName)
+	for _, dir := range snap.configSearchPaths {
+		dir = os.Expand(replacer.Replace(dir), func(key string) string {
+			v := os.Getenv(key)
+			if v == "" && key == "XDG_CONFIG_HOME" {
+				if home, err := os.UserHomeDir(); err == nil {
+					v = filepath.Join(home, ".config")
+				}
+			}
+			return v
+		})
+		for _, name := range names {
+			filename := filepath.Join(dir, replacer.Replace(name))
+			if info, err := statFile(snap.fsys, filename); err == nil && !info.IsDir() {
+				return filename
+			}
+		}
+	}
+	return ""
+}
+
+// applyConfig sets the flags of the command that are not set on the command line by the config values.
+func (snap *execSnapshot) applyConfig(c *Command, flagSet *FlagSet) error {
+	if len(snap.config) == 0 {
+		return nil
+	}
+	var prefix string
+	if p := c.Path(); len(p) > 1 {
+		prefix = strings.Join(p[1:], ".") + "."
+	}
+	return flagSet.applyConfig(snap.config, func(string) string {
+		return snap.configFile
+	}, prefix)
+}
@@ -0,0 +1,296 @@
+package flagx
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type (
+	// configEntry a flag rendered in the config file
+	configEntry struct {
+		section []string    // The command path without the app name
+		name    string      // The flag name
+		value   interface{} // The typed value
+		usage   string      // The usage of the flag
+	}
+	// configInitAction the action of the built-in "config init" command
+	configInitAction struct {
+		app    *App
+		Format string `flag:"format;usage=the config file format: json, toml or ini, defaults to the extension of the output file or toml"`
+		Output string `flag:"o;usage=the output file, defaults to the standard output"`
+		Force  bool   `flag:"force;usage=overwrite the output file if it exists"`
+	}
+)
+
+// AddConfigCommand adds the "config" subcommand with the "init" subcommand, which writes
+// the config file skeleton of all the flags, see WriteConfigSkeleton.
+// NOTE:
+//  if the "config" subcommand exists, "init" is added to it;
+//  panic when something goes wrong.
+func (a *App) AddConfigCommand() *Command {
+	cmd := a.LookupSubcommand("config")
+	if cmd == nil {
+		cmd = a.AddSubcommand("config", "manage the config file")
+	}
+	cmd.AddSubaction("init", "write the config file skeleton generated from the flags", &configInitAction{app: a})
+	return cmd
+}
+
+// DeepCopy implements ActionCopier interface.
+func (c *configInitAction) DeepCopy() Action {
+	return &configInitAction{app: c.app}
+}
+
+// Execute implements Action interface.
+func (c *configInitAction) Execute(ctx *Context) {
+	format := ConfigFormat(c.Format)
+	if format == "" {
+		format = ConfigFormat(strings.TrimPrefix(filepath.Ext(c.Output), "."))
+		if format == "" {
+			format = ConfigTOML
+		}
+	}
+	if c.Output == "" {
+		ctx.CheckStatus(c.app.WriteConfigSkeleton(os.Stdout, format), StatusConfigFailed, "")
+		return
+	}
+	var buf bytes.Buffer
+	ctx.CheckStatus(c.app.WriteConfigSkeleton(&buf, format), StatusConfigFailed, "")
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if c.Force {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	file, err := os.OpenFile(c.Output, flags, 0644)
+	ctx.CheckStatus(err, StatusConfigFailed, "")
+	defer file.Close()
+	_, err = buf.WriteTo(file)
+	ctx.CheckStatus(err, StatusConfigFailed, "")
+}
+
+// ConfigFormat the format of the config file
+type ConfigFormat string
+
+// Config file formats
+const (
+	ConfigJSON ConfigFormat = "json"
+	ConfigTOML ConfigFormat = "toml"
+	ConfigINI  ConfigFormat = "ini"
+)
+
+// WriteConfigSkeleton writes the config file skeleton of all the flags in the format,
+// with their default values and usage as comments.
+// NOTE:
+//  the flags of the subcommands are keyed by the command path, such as "sub.name";
+//  JSON does not support comments, so only the values are written;
+//  the non-flags and the lazy commands that are not loaded are skipped.
+func (a *App) WriteConfigSkeleton(w io.Writer, format ConfigFormat) error {
+	header := fmt.Sprintf("The config file of %s, generated by `%s config init`.", a.Name(), a.CmdName())
+	return writeConfig(w, format, header, a.configEntries())
+}
+
+// WriteConfig writes the current values of the flags in the format with their usage as comments,
+// which can be loaded by BindConfigFile.
+// NOTE:
+//  if @onlyChanged is true, only the flags that have been set are written, such as on the command line;
+//  JSON does not support comments, so only the values are written;
+//  the non-flags are skipped.
+func (f *FlagSet) WriteConfig(w io.Writer, format ConfigFormat, onlyChanged bool) error {
+	var entries []*configEntry
+	visit := func(fl *Flag) {
+		_, usage := UnquoteUsage(fl)
+		entries = append(entries, &configEntry{
+			name:  fl.Name,
+			value: configValue(fl, fl.Value.String()),
+			usage: usage,
+		})
+	}
+	if onlyChanged {
+		f.FlagSet.Visit(visit)
+	} else {
+		f.FlagSet.VisitAll(visit)
+	}
+	return writeConfig(w, format, "", entries)
+}
+
+// writeConfig writes the entries in the format, the header is written as a comment if it is not empty.
+func writeConfig(w io.Writer, format ConfigFormat, header string, entries []*configEntry) error {
+	var buf bytes.Buffer
+	normalized := ConfigFormat(strings.ToLower(strings.TrimPrefix(string(format), ".")))
+	if header != "" && normalized != ConfigJSON {
+		buf.WriteString("# " + header + "\n")
+	}
+	switch normalized {
+	case ConfigJSON:
+		m := make(map[string]interface{}, len(entries))
+		for _, e := range entries {
+			keys := append(append([]string(nil), e.section...), strings.Split(e.name, ".")...)
+			t := m
+			for _, key := range keys[:len(keys)-1] {
+				sub, ok := t[key].(map[string]interface{})
+				if !ok {
+					sub = make(map[string]interface{}, 8)
+					t[key] = sub
+				}
+				t = sub
+			}
+			t[keys[len(keys)-1]] = e.value
+		}
+		b, err := json.MarshalIndent(m, "", "  ")
+		if err != nil {
+			return err
+		}
+		buf.Write(b)
+		buf.WriteByte('\n')
+	case ConfigTOML:
+		writeConfigSections(&buf, entries, func(section []string) string {
+			for i, key := range section {
+				section[i] = tomlKey(key)
+			}
+			return "[" + strings.Join(section, ".") + "]"
+		}, func(e *configEntry) string {
+			keys := strings.Split(e.name, ".")
+			for i, key := range keys {
+				keys[i] = tomlKey(key)
+			}
+			return strings.Join(keys, ".") + " = " + tomlValue(e.value)
+		})
+	case ConfigINI, "properties", "conf", "cfg":
+		writeConfigSections(&buf, entries, func(section []string) string {
+			return "[" + strings.Join(section, ".") + "]"
+		}, func(e *configEntry) string {
+			return e.name + " = " + fmt.Sprint(e.value)
+		})
+	default:
+		return fmt.Errorf("unknown config file format: %s", format)
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// writeConfigSections writes the entries grouped by the sections with their usage as comments.
+func writeConfigSections(buf *bytes.Buffer, entries []*configEntry, sectionLine func([]string) string, entryLine func(*configEntry) string) {
+	var section string
+	for i, e := range entries {
+		if s := strings.Join(e.section, " "); i == 0 || s != section {
+			section = s
+			if len(e.section) > 0 {
+				buf.WriteString("\n" + sectionLine(append([]string(nil), e.section...)) + "\n")
+			}
+		}
+		if buf.Len() > 0 {
+			buf.WriteByte('\n')
+		}
+		if e.usage != "" {
+			buf.WriteString("# " + strings.Replace(e.usage, "\n", "\n# ", -1) + "\n")
+		}
+		buf.WriteString(entryLine(e) + "\n")
+	}
+}
+
+// configEntries returns the flags of all the commands, the global ones are the first.
+func (a *App) configEntries() []*configEntry {
+	a.lock.RLock()
+	configFlag := a.configFlag
+	a.lock.RUnlock()
+	var entries []*configEntry
+	a.Walk(func(c *Command) bool {
+		c.lock.RLock()
+		defer c.lock.RUnlock()
+		if c.action != nil {
+			if _, ok := c.action.actionFactory.(*configInitAction); ok {
+				return true
+			}
+		}
+		var section []string
+		if p := c.Path(); len(p) > 1 {
+			section = p[1:]
+		}
+		seen := make(map[string]bool, 8)
+		visit := func(f *Flag) {
+			if seen[f.Name] || (c.parent == nil && f.Name == configFlag) {
+				return
+			}
+			seen[f.Name] = true
+			_, usage := UnquoteUsage(f)
+			entries = append(entries, &configEntry{
+				section: section,
+				name:    f.Name,
+				value:   configValue(f, f.DefValue),
+				usage:   usage,
+			})
+		}
+		for _, filter := range c.filters {
+			filter.flagSet.VisitAll(visit)
+		}
+		if c.action != nil {
+			c.action.flagSet.VisitAll(visit)
+		}
+		return true
+	})
+	sort.SliceStable(entries, func(i, j int) bool {
+		return strings.Join(entries[i].section, " ") < strings.Join(entries[j].section, " ")
+	})
+	return entries
+}
+
+// configValue returns the typed value of the flag from the string.
+func configValue(f *Flag, s string) interface{} {
+	getter, ok := f.Value.(Getter)
+	if !ok {
+		return s
+	}
+	switch getter.Get().(type) {
+	case bool:
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b
+		}
+	case int, int64:
+		if n, err := strconv.ParseInt(s, 0, 64); err == nil {
+			return n
+		}
+	case uint, uint64:
+		if n, err := strconv.ParseUint(s, 0, 64); err == nil {
+			return n
+		}
+	case float64:
+		if n, err := strconv.ParseFloat(s, 64); err == nil {
+			return n
+		}
+	}
+	return s
+}
+
+// tomlKey returns the bare key, or the quoted one if it has special characters.
+func tomlKey(key string) string {
+	for _, r := range key {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return strconv.Quote(key)
+		}
+	}
+	if key == "" {
+		return `""`
+	}
+	return key
+}
+
+func tomlValue(v interface{}) string {
+	switch x := v.(type) {
+	case string:
+		return strconv.Quote(x)
+	case float64:
+		s := strconv.FormatFloat(x, 'f', -1, 64)
+		if !strings.ContainsAny(s, ".eE") {
+			s += ".0"
+		}
+		return s
+	default:
+		return fmt.Sprint(x)
+	}
+}
@@ -0,0 +1,63 @@
+package flagx
+
+import (
+	"context"
+	"io"
+)
+
+// ContextSpec the specification of the detached Context created by NewContext
+type ContextSpec struct {
+	Context   context.Context             // The embedded context, defaults to context.Background()
+	CmdPath   []string                    // The command path including the app command name
+	Args      []string                    // The command arguments
+	CmdScope  Scope                       // The scope of the command
+	ExecScope Scope                       // The executor scope
+	Meta      map[interface{}]interface{} // The command meta
+	Output    io.Writer                   // The output of Context.Output, defaults to os.Stdout
+	Env       map[string]string           // The environment of Context.Env, defaults to the process one
+	Format    OutputFormat                // The output format of Context.Print, defaults to OutputText
+}
+
+// NewContext returns a Context of a detached command tree built from the spec,
+// so that the actions and filters can be unit-tested without building and executing an app.
+// NOTE:
+//  the flags are not parsed, set the fields of the action or filter object directly;
+//  see flagxtest.NewContext for the options builder.
+func NewContext(spec ContextSpec) *Context {
+	ctx := spec.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	cmdPath := spec.CmdPath
+	if len(cmdPath) == 0 {
+		cmdPath = []string{""}
+	}
+	app := NewApp()
+	app.SetCmdName(cmdPath[0])
+	cmdPath = append([]string{app.CmdName()}, cmdPath[1:]...)
+	cmd := app.Command
+	for _, name := range cmdPath[1:] {
+		cmd = cmd.AddSubcommand(name, "")
+	}
+	cmd.scope = spec.CmdScope
+	for k, v := range spec.Meta {
+		cmd.SetMeta(k, v)
+	}
+	app.SetOutput(spec.Output)
+	if spec.Env != nil {
+		ctx = WithEnv(ctx, spec.Env)
+	}
+	snap := app.snapshot()
+	snap.ctx = ctx
+	snap.env, _ = ctx.Value(envKey{}).(map[string]string)
+	return &Context{
+		Context:      ctx,
+		args:         spec.Args,
+		rawArgs:      spec.Args,
+		cmdPath:      cmdPath,
+		cmd:          cmd,
+		execScope:    spec.ExecScope,
+		snap:         snap,
+		outputFormat: spec.Format,
+	}
+}
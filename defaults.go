@@ -0,0 +1,74 @@
+package flagx
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultsProvider provides the default value of the flag that is unset, and reports whether it exists,
+// such as the worker count derived from GOMAXPROCS or the detected region.
+type DefaultsProvider func(name string) (string, bool)
+
+// AddDefaultsProvider adds the providers of the default values, which are consulted in the order of addition
+// when a flag is unset.
+// NOTE:
+//  the precedence is command line > config file > defaults provider > default.
+func (f *FlagSet) AddDefaultsProvider(fns ...DefaultsProvider) {
+	f.defaultsProviders = append(f.defaultsProviders, fns...)
+}
+
+// AddDefaultsProvider adds the providers of the default values of the flags of all the commands,
+// which are consulted in the order of addition when a flag is unset.
+// NOTE:
+//  a flag of a command is looked up by the name prefixed with its path first, such as "sub.name",
+//  then by the flag name;
+//  the precedence is command line > environment variable > remote source > config file > defaults provider > default.
+func (a *App) AddDefaultsProvider(fns ...DefaultsProvider) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.defaultsProviders = append(a.defaultsProviders, fns...)
+}
+
+// applyDefaults sets the flags that are unset by the defaults providers.
+func (f *FlagSet) applyDefaults(fns []DefaultsProvider, prefixes ...string) error {
+	if len(fns) == 0 {
+		return nil
+	}
+	for _, name := range f.unsetFlagNames() {
+		value, ok := lookupDefaults(fns, name, prefixes)
+		if !ok {
+			continue
+		}
+		if err := f.setJoinedFlag(name, value); err != nil {
+			return fmt.Errorf("invalid value %q for flag -%s from defaults provider: %v", value, name, err)
+		}
+		f.setOrigin(name, SourceProvider, value, "")
+	}
+	return nil
+}
+
+func lookupDefaults(fns []DefaultsProvider, name string, prefixes []string) (string, bool) {
+	for _, fn := range fns {
+		for _, prefix := range prefixes {
+			if v, ok := fn(prefix + name); ok {
+				return v, true
+			}
+		}
+		if v, ok := fn(name); ok {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// applyDefaults sets the flags of the command that are unset by the defaults providers.
+func (snap *execSnapshot) applyDefaults(c *Command, flagSet *FlagSet) error {
+	if len(snap.defaultsProviders) == 0 {
+		return nil
+	}
+	var prefix string
+	if p := c.Path(); len(p) > 1 {
+		prefix = strings.Join(p[1:], ".") + "."
+	}
+	return flagSet.applyDefaults(snap.defaultsProviders, prefix)
+}
@@ -0,0 +1,86 @@
+package flagx
+
+import (
+	"context"
+	"log/slog"
+)
+
+// SetDiagnostics sets the logger reporting the non-fatal events, such as the undefined flags ignored
+// under ContinueOnUndefined (at warn level), and the values applied from the environment variables,
+// the remote source, the config files and the defaults providers (at debug level).
+// NOTE:
+//  defaults to nil, which disables the reporting.
+func (f *FlagSet) SetDiagnostics(logger *slog.Logger) {
+	f.diagnostics = logger
+}
+
+// SetDiagnostics sets the logger reporting the non-fatal events of the executions, see FlagSet.SetDiagnostics.
+// NOTE:
+//  the undefined flags are reported only by the actions, since the filters ignore the flags of
+//  the subcommands by design;
+//  defaults to nil, which disables the reporting.
+func (a *App) SetDiagnostics(logger *slog.Logger) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.diagnostics = logger
+}
+
+// IgnoredFlagFunc is called with the name of each undefined flag ignored under ContinueOnUndefined,
+// such as to warn "unknown flag -verbse ignored".
+type IgnoredFlagFunc func(f *FlagSet, name string)
+
+// SetIgnoredFlagFunc sets the function called with each undefined flag ignored under ContinueOnUndefined.
+// NOTE:
+//  defaults to nil;
+//  the ignored flags of the last Parse can also be got by IgnoredFlags.
+func (f *FlagSet) SetIgnoredFlagFunc(fn IgnoredFlagFunc) {
+	f.ignoredFlagFunc = fn
+}
+
+// IgnoredFlags returns the names of the undefined flags ignored by the last Parse under ContinueOnUndefined.
+func (f *FlagSet) IgnoredFlags() []string {
+	return f.ignoredFlags
+}
+
+// SetIgnoredFlagFunc sets the function called with each undefined flag ignored by the actions,
+// see FlagSet.SetIgnoredFlagFunc.
+// NOTE:
+//  only the actions report the undefined flags, since the filters ignore the flags of
+//  the subcommands by design;
+//  defaults to nil.
+func (a *App) SetIgnoredFlagFunc(fn IgnoredFlagFunc) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.ignoredFlagFunc = fn
+}
+
+// ignoreFlag records the undefined flag ignored under ContinueOnUndefined.
+func (f *FlagSet) ignoreFlag(name string) {
+	f.ignoredFlags = append(f.ignoredFlags, name)
+	if !f.quietUndefined {
+		f.diagnose(slog.LevelWarn, "flagx: undefined flag ignored", slog.String("flag", name))
+	}
+	if f.ignoredFlagFunc != nil {
+		f.ignoredFlagFunc(f, name)
+	}
+}
+
+// diagnose reports the non-fatal event if the diagnostics logger is set.
+func (f *FlagSet) diagnose(level slog.Level, msg string, attrs ...slog.Attr) {
+	if f.diagnostics == nil {
+		return
+	}
+	f.diagnostics.LogAttrs(context.Background(), level, msg, append(attrs, slog.String("flagset", f.Name()))...)
+}
+
+// setDiagnostics sets the diagnostics logger and the output of the flag set of the command.
+func (snap *execSnapshot) setDiagnostics(c *Command, flagSet *FlagSet, isAction bool) {
+	if snap.output != nil {
+		flagSet.SetOutput(snap.output)
+	}
+	if snap.diagnostics == nil {
+		return
+	}
+	flagSet.diagnostics = snap.diagnostics.With(slog.String("cmd", c.PathString()))
+	flagSet.quietUndefined = !isAction
+}
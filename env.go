@@ -0,0 +1,144 @@
+package flagx
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// envKey the context key of the environment injected by WithEnv
+type envKey struct{}
+
+// SetEnvPrefix sets the prefix of the environment variables, which are the fallback
+// of all the flags of all the commands, such as MYAPP_SUB_NAME for -name of subcommand "sub".
+// NOTE:
+//  the variable name is upper-cased and '-' and '.' are replaced by '_';
+//  the precedence is command line > environment variable > config file > default;
+//  set empty string to disable it.
+func (a *App) SetEnvPrefix(prefix string) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.envPrefix = strings.TrimRight(prefix, "_")
+	a.resetUsageLocked()
+}
+
+// EnvPrefix returns the prefix of the environment variables.
+func (a *App) EnvPrefix() string {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	return a.envPrefix
+}
+
+var envNameReplacer = strings.NewReplacer("-", "_", ".", "_")
+
+// envName returns the environment variable name of the flag of the command.
+func envName(prefix string, cmdPath []string, name string) string {
+	parts := make([]string, 0, len(cmdPath)+1)
+	parts = append(parts, prefix)
+	if len(cmdPath) > 1 {
+		parts = append(parts, cmdPath[1:]...)
+	}
+	parts = append(parts, name)
+	return strings.ToUpper(envNameReplacer.Replace(strings.Join(parts, "_")))
+}
+
+// applyEnv sets the flags that are not set on the command line by the environment variables.
+// NOTE:
+//  @lookup defaults to os.LookupEnv.
+func (f *FlagSet) applyEnv(prefix string, cmdPath []string, lookup ...func(string) (string, bool)) error {
+	lookupEnv := os.LookupEnv
+	if len(lookup) > 0 && lookup[0] != nil {
+		lookupEnv = lookup[0]
+	}
+	for _, name := range f.unsetFlagNames() {
+		key := envName(prefix, cmdPath, name)
+		value, ok := lookupEnv(key)
+		if !ok {
+			continue
+		}
+		if err := f.setFlag(name, value); err != nil {
+			return fmt.Errorf("invalid value %q for flag -%s from environment variable %s: %v", value, name, key, err)
+		}
+		f.setOrigin(name, SourceEnv, value, key)
+	}
+	return nil
+}
+
+// applyEnv sets the flags of the command that are not set on the command line by the environment variables.
+func (snap *execSnapshot) applyEnv(c *Command, flagSet *FlagSet) error {
+	if snap.envPrefix == "" {
+		return nil
+	}
+	return flagSet.applyEnv(snap.envPrefix, c.Path(), snap.lookupEnv)
+}
+
+// WithEnv returns the context carrying the environment, which replaces the process environment
+// in the execution of Exec with the context, such as the environment variables of the flags,
+// Context.Env and the external commands, so that the actions reading them are testable and
+// the remote execution can supply a synthetic environment.
+// NOTE:
+//  the environment is copied.
+// Example:
+//  app.Exec(flagx.WithEnv(ctx, map[string]string{"HOME": "/tmp"}), args)
+func WithEnv(ctx context.Context, env map[string]string) context.Context {
+	m := make(map[string]string, len(env))
+	for k, v := range env {
+		m[k] = v
+	}
+	return context.WithValue(ctx, envKey{}, m)
+}
+
+// Env returns a copy of the environment of the execution, see WithEnv.
+func (c *Context) Env() map[string]string {
+	if c.snap == nil || c.snap.env == nil {
+		return environ()
+	}
+	m := make(map[string]string, len(c.snap.env))
+	for k, v := range c.snap.env {
+		m[k] = v
+	}
+	return m
+}
+
+// LookupEnv returns the value of the environment variable of the execution, see WithEnv.
+func (c *Context) LookupEnv(key string) (string, bool) {
+	if c.snap == nil {
+		return os.LookupEnv(key)
+	}
+	return c.snap.lookupEnv(key)
+}
+
+// lookupEnv returns the value of the environment variable injected by WithEnv,
+// or the one of the process environment.
+func (snap *execSnapshot) lookupEnv(key string) (string, bool) {
+	if snap.env == nil {
+		return os.LookupEnv(key)
+	}
+	v, ok := snap.env[key]
+	return v, ok
+}
+
+// environList returns the environment of the execution in the form "key=value".
+func (snap *execSnapshot) environList() []string {
+	if snap.env == nil {
+		return os.Environ()
+	}
+	list := make([]string, 0, len(snap.env))
+	for k, v := range snap.env {
+		list = append(list, k+"="+v)
+	}
+	return list
+}
+
+// environ returns the process environment as a map.
+func environ() map[string]string {
+	list := os.Environ()
+	m := make(map[string]string, len(list))
+	for _, kv := range list {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			m[k] = v
+		}
+	}
+	return m
+}
@@ -0,0 +1,220 @@
+package flagx
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrHelp is the error returned if the -help or -h flag is invoked but no such flag is defined.
+var ErrHelp = flag.ErrHelp
+
+type (
+	// ErrUndefinedFlag is returned by Parse and Set if the flag or non-flag is provided but not defined.
+	ErrUndefinedFlag struct {
+		Name        string   // The flag name, or the non-flag name such as ?0
+		Suggestions []string // The similar defined flag names, see FlagSet.SuggestFlags
+	}
+	// ErrBadSyntax is returned by Parse if the argument is not a valid flag, such as ---x and -=x.
+	ErrBadSyntax struct {
+		Arg string
+	}
+	// ErrNeedsArgument is returned by Parse if the flag is the last argument without a value.
+	ErrNeedsArgument struct {
+		Name string
+	}
+	// ErrInvalidValue is returned by Parse and Set if the value can not be set to the flag or non-flag.
+	ErrInvalidValue struct {
+		Name  string // The flag name, or the non-flag name such as ?0
+		Value string
+		Err   error // The error returned by Value.Set
+	}
+)
+
+// Error implements error interface.
+func (e *ErrUndefinedFlag) Error() string {
+	if strings.HasPrefix(e.Name, tagKeyNonFlag) {
+		return "non-flag provided but not defined: " + e.Name
+	}
+	if len(e.Suggestions) > 0 {
+		return "flag provided but not defined: -" + e.Name + "; " + translate(MsgDidYouMean, suggestionList(e.Suggestions))
+	}
+	return "flag provided but not defined: -" + e.Name
+}
+
+// suggestionList returns the list of the suggested flag names, such as "-verbose or -version".
+func suggestionList(names []string) string {
+	a := make([]string, len(names))
+	for i, name := range names {
+		a[i] = "-" + name
+	}
+	if len(a) == 1 {
+		return a[0]
+	}
+	return strings.Join(a[:len(a)-1], ", ") + " or " + a[len(a)-1]
+}
+
+// Error implements error interface.
+func (e *ErrBadSyntax) Error() string {
+	return "bad flag syntax: " + e.Arg
+}
+
+// Error implements error interface.
+func (e *ErrNeedsArgument) Error() string {
+	return "flag needs an argument: -" + e.Name
+}
+
+// Error implements error interface.
+func (e *ErrInvalidValue) Error() string {
+	if idx, isNon, _ := getNonFlagIndex(e.Name); isNon {
+		return fmt.Sprintf("invalid value %q for non-flag %d: %v", e.Value, idx, e.Err)
+	}
+	return fmt.Sprintf("invalid value %q for flag -%s: %v", e.Value, e.Name, e.Err)
+}
+
+// Unwrap returns the error returned by Value.Set.
+func (e *ErrInvalidValue) Unwrap() error {
+	return e.Err
+}
+
+// toParseError converts the error returned by the standard flag set to the typed one.
+func toParseError(err error) error {
+	if err == nil || err == ErrHelp {
+		return err
+	}
+	text := err.Error()
+	if name, ok := strings.CutPrefix(text, "flag provided but not defined: -"); ok {
+		return &ErrUndefinedFlag{Name: name}
+	}
+	if arg, ok := strings.CutPrefix(text, "bad flag syntax: "); ok {
+		return &ErrBadSyntax{Arg: arg}
+	}
+	if name, ok := strings.CutPrefix(text, "flag needs an argument: -"); ok {
+		return &ErrNeedsArgument{Name: name}
+	}
+	for _, prefix := range [...]string{"invalid value ", "invalid boolean value "} {
+		rest, ok := strings.CutPrefix(text, prefix)
+		if !ok {
+			continue
+		}
+		quoted, err2 := strconv.QuotedPrefix(rest)
+		if err2 != nil {
+			break
+		}
+		value, _ := strconv.Unquote(quoted)
+		rest = strings.TrimPrefix(strings.TrimPrefix(rest[len(quoted):], " for flag -"), " for -")
+		name, cause, ok := strings.Cut(rest, ": ")
+		if !ok {
+			break
+		}
+		return &ErrInvalidValue{Name: name, Value: value, Err: toValueError(cause)}
+	}
+	return err
+}
+
+// toValueError returns the error of Value.Set from its text.
+func toValueError(text string) error {
+	switch text {
+	case errParse.Error():
+		return errParse
+	case errRange.Error():
+		return errRange
+	}
+	return errors.New(text)
+}
+
+// setErrorValue records the error returned by the Set of the value while parsing,
+// which the standard flag set only keeps as the text.
+type setErrorValue struct {
+	Value
+	err *error
+}
+
+func (v *setErrorValue) Set(s string) error {
+	err := v.Value.Set(s)
+	if err != nil {
+		*v.err = err
+	}
+	return err
+}
+
+func (v *setErrorValue) String() string {
+	if v.Value == nil { // the zero value made by the usage
+		return ""
+	}
+	return v.Value.String()
+}
+
+func (v *setErrorValue) IsBoolFlag() bool {
+	b, ok := v.Value.(boolFlag)
+	return ok && b.IsBoolFlag()
+}
+
+// captureSetErrors wraps the values of the flags provided in the arguments to record the error of Set,
+// and returns the function restoring them, which returns the recorded error.
+func (f *FlagSet) captureSetErrors(arguments []string) (restore func() error) {
+	var setErr error
+	var wrapped []*Flag
+	for _, arg := range arguments {
+		if arg == "--" {
+			break
+		}
+		if len(arg) < 2 || arg[0] != '-' {
+			continue
+		}
+		name, _, _ := strings.Cut(strings.TrimPrefix(arg[1:], "-"), "=")
+		fl := f.FlagSet.Lookup(name)
+		if fl == nil {
+			continue
+		}
+		if _, ok := fl.Value.(*setErrorValue); ok {
+			continue
+		}
+		fl.Value = &setErrorValue{Value: fl.Value, err: &setErr}
+		wrapped = append(wrapped, fl)
+	}
+	if len(wrapped) == 0 {
+		return func() error { return nil }
+	}
+	var restored bool
+	restoreValues := func() {
+		if !restored {
+			restored = true
+			for _, fl := range wrapped {
+				fl.Value = fl.Value.(*setErrorValue).Value
+			}
+		}
+	}
+	// the usage printed on the error sees the original values
+	if usage := f.FlagSet.Usage; usage != nil {
+		f.FlagSet.Usage = func() {
+			restoreValues()
+			usage()
+		}
+		return func() error {
+			f.FlagSet.Usage = usage
+			restoreValues()
+			return setErr
+		}
+	}
+	return func() error {
+		restoreValues()
+		return setErr
+	}
+}
+
+// withSetError replaces the cause of the invalid value error with the error returned by Set,
+// so that errors.Is and errors.As work on it.
+func withSetError(err, setErr error) error {
+	invalid, ok := err.(*ErrInvalidValue)
+	if !ok || setErr == nil {
+		return err
+	}
+	// the errors of the standard values are the same as the ones of this package
+	if text := setErr.Error(); text != errParse.Error() && text != errRange.Error() {
+		invalid.Err = setErr
+	}
+	return err
+}
@@ -0,0 +1,67 @@
+package flagx
+
+import (
+	"fmt"
+	"os"
+)
+
+// ExpandFunc resolves the variable name in the string flag values to its value,
+// such as os.Getenv for the environment variables.
+type ExpandFunc func(name string) string
+
+// SetExpander sets the resolver of the ${VAR} and $VAR references in the string values of
+// the flags and non-flags, which are expanded after parsing.
+// NOTE:
+//  it is disabled by default, set nil to disable it;
+//  the values from the config file are also expanded.
+// Example:
+//  fs.SetExpander(os.Getenv)
+func (f *FlagSet) SetExpander(fn ExpandFunc) {
+	f.expander = fn
+}
+
+// SetExpander sets the resolver of the ${VAR} and $VAR references in the string values of
+// the flags and non-flags of all the commands, which are expanded after parsing.
+// NOTE:
+//  it is disabled by default, set nil to disable it;
+//  the values from the environment variables and the config file are also expanded.
+// Example:
+//  app.SetExpander(os.Getenv)
+func (a *App) SetExpander(fn ExpandFunc) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.expander = fn
+}
+
+// expandValues expands the string values of the flags and non-flags that have been set.
+func (f *FlagSet) expandValues(fn ExpandFunc) error {
+	if fn == nil {
+		return nil
+	}
+	var err error
+	f.Range(func(fl *Flag) {
+		if err != nil {
+			return
+		}
+		if !isStringFlag(fl) {
+			return
+		}
+		s := fl.Value.String()
+		if v := os.Expand(s, fn); v != s {
+			if e := f.setValue(fl, v); e != nil {
+				err = fmt.Errorf("invalid value %q for flag -%s after expansion: %v", v, fl.Name, e)
+			}
+		}
+	})
+	return err
+}
+
+// isStringFlag reports whether the value of the flag is a string.
+func isStringFlag(f *Flag) bool {
+	getter, ok := f.Value.(Getter)
+	if !ok {
+		return false
+	}
+	_, ok = getter.Get().(string)
+	return ok
+}
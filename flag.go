@@ -5,11 +5,13 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"io/fs"
+	"log/slog"
 	"os"
 	"reflect"
-	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/henrylee2cn/ameda"
@@ -26,8 +28,40 @@ type (
 		errorHandling         ErrorHandling
 		isContinueOnUndefined bool
 		terminated            bool
-		nonActual             map[int]*Flag
-		nonFormal             map[int]*Flag
+		nonActual             []*Flag // The non-flags that have been set, by index
+		nonFormal             []*Flag // The defined non-flags by index, nil if not defined
+		config                map[string]string
+		configFiles           []configFile
+		configFrom            map[string]string
+		expander              ExpandFunc
+		origins               map[string]valueOrigin
+		secrets               map[string]bool
+		secretResolver        SecretResolver
+		defaultsProviders     []DefaultsProvider
+		fsys                  fs.FS
+		diagnostics           *slog.Logger
+		quietUndefined        bool
+		parseMode             ParseMode
+		implicitTerminator    bool              // The "--" is inserted by the parse modes
+		keyValues             map[string]string // The key=value non-flags under ParseKeyValue
+		ignoredFlags          []string          // The undefined flags ignored under ContinueOnUndefined
+		ignoredFlagFunc       IgnoredFlagFunc
+		positionalMode        PositionalMode
+		passthroughArgs       []string // The arguments after the terminator "--"
+		observers             map[string][]func(old, new string)
+		quiet                 bool
+		collecting            bool           // The errors are collected by ParseAllErrors
+		definitions           map[string]int // The definition sequences of the flags and non-flags
+		requiredNonFlags      map[int]bool   // The indexes of the required non-flags
+		nonVariadic           *Flag          // The variadic non-flag taking the rest non-flags
+		nonVariadicIndex      int
+		lock                  sync.RWMutex // Guards the config, origins and values applied by WatchConfig
+	}
+
+	// FlagDefiner an optional interface of the struct passed to StructVars,
+	// which defines the extra flags that are not known until runtime
+	FlagDefiner interface {
+		DefineFlags(*FlagSet) error
 	}
 
 	// A Flag represents the state of a flag.
@@ -50,6 +84,15 @@ type (
 	// The flag package may call the String method with a zero-valued receiver,
 	// such as a nil pointer.
 	Value = flag.Value
+
+	// SliceValue is the optional interface of the Value which accepts multiple values,
+	// whose Set appends the value rather than replacing it.
+	// If IsSliceFlag returns true, the flag consumes the consecutive non-flag arguments
+	// under ParseMultiValue mode, such as -files a.txt b.txt.
+	SliceValue interface {
+		Value
+		IsSliceFlag() bool
+	}
 )
 
 // These constants cause FlagSet.Parse to behave as described if the parse fails.
@@ -90,6 +133,9 @@ func (f *FlagSet) ErrorHandling() ErrorHandling {
 
 // NextArgs returns arguments of the next subcommand.
 func (f *FlagSet) NextArgs() []string {
+	if f.nonVariadic != nil {
+		return nil
+	}
 	n := f.NFormalNonFlag()
 	args := f.Args()
 	if n < len(args) {
@@ -98,33 +144,116 @@ func (f *FlagSet) NextArgs() []string {
 	return nil
 }
 
-// NFormalNonFlag returns the number of non-flag required in the definition.
-func (f *FlagSet) NFormalNonFlag() int {
-	var max int
-	for i := range f.nonFormal {
-		n := i + 1
-		if max < n {
-			max = n
+// PassthroughArgs returns exactly the arguments after the terminator "--", which can be
+// forwarded verbatim, such as by the wrapper commands like "app run -- docker ps -a".
+// It returns nil if there is no terminator, and an empty slice if nothing follows it.
+// NOTE:
+//  unlike NextArgs, the non-flags before the terminator are not included;
+//  a "--" taken as the value of a flag is not a terminator.
+func (f *FlagSet) PassthroughArgs() []string {
+	return f.passthroughArgs
+}
+
+// passthroughArgs returns the arguments after the first "--" which is not the value of a flag.
+func passthroughArgs(arguments []string, lookup func(name string) *Flag) []string {
+	lastArgs, terminated, _ := filterArgs(arguments, lookup, func(string, *string, []string) bool { return true })
+	if terminated {
+		return lastArgs
+	}
+	for i, arg := range lastArgs {
+		if arg == "--" {
+			return lastArgs[i+1:]
 		}
 	}
-	return max
+	return nil
+}
+
+// NFormalNonFlag returns the number of non-flag required in the definition.
+func (f *FlagSet) NFormalNonFlag() int {
+	return len(f.nonFormal)
 }
 
 // StructVars defines flags based on struct tags and binds to fields.
 // NOTE:
-//  Not support nested fields
+//  Not support nested fields;
+//  the parsed struct tags, including the default values and the non-flag indexes, are cached per struct type,
+//  so binding another struct of the same type does not parse the tags again, but still defines the flags;
+//  if @p implements FlagDefiner, DefineFlags is called after the fields are bound.
 func (f *FlagSet) StructVars(p interface{}) error {
 	v := reflect.ValueOf(p)
 	if v.Kind() == reflect.Ptr {
 		v = ameda.DereferenceValue(v)
 		if v.Kind() == reflect.Struct {
-			structTypeIDs := make(map[uintptr]struct{}, 4)
-			return f.varFromStruct(v, structTypeIDs)
+			err := f.varFromStruct(v)
+			if err != nil {
+				return err
+			}
+			if definer, ok := p.(FlagDefiner); ok {
+				err = definer.DefineFlags(f)
+				f.recordDefinitions()
+				return err
+			}
+			return nil
 		}
 	}
 	return fmt.Errorf("flagx: want struct pointer parameter, but got %T", p)
 }
 
+// StringSliceVar defines a []string flag with specified name, default value, and usage string.
+// The argument p points to a []string variable in which to store the values of the flag.
+// The flag can be repeated, each occurrence appends a value, and the first one replaces the default value.
+func (f *FlagSet) StringSliceVar(p *[]string, name string, value []string, usage string) {
+	f.Var(newStringSliceValue(value, p), name, usage)
+	f.recordDefinition(name)
+}
+
+// StringSlice defines a []string flag with specified name, default value, and usage string.
+// The return value is the address of a []string variable that stores the values of the flag.
+// The flag can be repeated, each occurrence appends a value, and the first one replaces the default value.
+func (f *FlagSet) StringSlice(name string, value []string, usage string) *[]string {
+	p := new([]string)
+	f.StringSliceVar(p, name, value, usage)
+	return p
+}
+
+// IntSliceVar defines a []int flag with specified name, default value, and usage string.
+// The argument p points to a []int variable in which to store the values of the flag.
+// The flag accepts the comma-separated values and can be repeated, each occurrence appends the values,
+// and the first one replaces the default value.
+func (f *FlagSet) IntSliceVar(p *[]int, name string, value []int, usage string) {
+	f.Var(newIntSliceValue(value, p), name, usage)
+	f.recordDefinition(name)
+}
+
+// IntSlice defines a []int flag with specified name, default value, and usage string.
+// The return value is the address of a []int variable that stores the values of the flag.
+// The flag accepts the comma-separated values and can be repeated, each occurrence appends the values,
+// and the first one replaces the default value.
+func (f *FlagSet) IntSlice(name string, value []int, usage string) *[]int {
+	p := new([]int)
+	f.IntSliceVar(p, name, value, usage)
+	return p
+}
+
+// Int64SliceVar defines a []int64 flag with specified name, default value, and usage string.
+// The argument p points to a []int64 variable in which to store the values of the flag.
+// The flag accepts the comma-separated values and can be repeated, each occurrence appends the values,
+// and the first one replaces the default value.
+func (f *FlagSet) Int64SliceVar(p *[]int64, name string, value []int64, usage string) {
+	f.Var(newInt64SliceValue(value, p), name, usage)
+	f.recordDefinition(name)
+}
+
+// Int64Slice defines a []int64 flag with specified name, default value, and usage string.
+// The return value is the address of a []int64 variable that stores the values of the flag.
+// The flag accepts the comma-separated values and can be repeated, each occurrence appends the values,
+// and the first one replaces the default value.
+func (f *FlagSet) Int64Slice(name string, value []int64, usage string) *[]int64 {
+	p := new([]int64)
+	f.Int64SliceVar(p, name, value, usage)
+	return p
+}
+
 // NonBoolVar defines a bool non-flag with specified index, default value, and usage string.
 // The argument p points to a bool variable in which to store the value of the non-flag.
 func (f *FlagSet) NonBoolVar(p *bool, index int, value bool, usage string) {
@@ -244,11 +373,13 @@ func (f *FlagSet) NonVar(value Value, index int, usage string) {
 	if index < 0 {
 		panic("@index is not a valid slice index")
 	}
+	if f.nonVariadic != nil && index > f.nonVariadicIndex {
+		panic(fmt.Sprintf("non-flag %s is defined after the variadic one", getNonFlagName(index)))
+	}
 	name := getNonFlagName(index)
 	// Remember the default value as a string; it won't change.
-	flag := &Flag{name, usage, value, value.String()}
-	_, alreadythere := f.nonFormal[index]
-	if alreadythere {
+	flag := &Flag{Name: name, Usage: usage, Value: value, DefValue: value.String()}
+	if lookupNonFlag(f.nonFormal, index) != nil {
 		var msg string
 		if f.Name() == "" {
 			msg = fmt.Sprintf("flag redefined: %s", name)
@@ -258,20 +389,97 @@ func (f *FlagSet) NonVar(value Value, index int, usage string) {
 		fmt.Fprintln(f.Output(), msg)
 		panic(msg) // Happens only if flags are declared with identical names
 	}
-	if f.nonFormal == nil {
-		f.nonFormal = make(map[int]*Flag)
+	f.nonFormal = setNonFlag(f.nonFormal, index, flag)
+	f.recordDefinition(name)
+}
+
+// lookupNonFlag returns the non-flag with the index, or nil if it does not exist.
+func lookupNonFlag(flags []*Flag, index int) *Flag {
+	if index < 0 || index >= len(flags) {
+		return nil
 	}
-	f.nonFormal[index] = flag
+	return flags[index]
+}
+
+// setNonFlag sets the non-flag with the index, growing the slice if needed.
+func setNonFlag(flags []*Flag, index int, flag *Flag) []*Flag {
+	if index >= len(flags) {
+		flags = append(flags, make([]*Flag, index+1-len(flags))...)
+	}
+	flags[index] = flag
+	return flags
 }
 
 // Parse parses flag definitions from the argument list, which should not
 // include the command name. Must be called after all flags in the FlagSet
 // are defined and before flags are accessed by the program.
 // The return value will be ErrHelp if -help or -h were set but not defined.
+// The parse failures are typed, such as *ErrUndefinedFlag, *ErrBadSyntax, *ErrNeedsArgument
+// and *ErrInvalidValue, which can be checked by errors.As.
 func (f *FlagSet) Parse(arguments []string) error {
-	if f.isContinueOnUndefined {
-		flagArgs, nonFlagArgs, terminated, err := tidyArgs(arguments, func(name string) (want, next bool) {
-			return f.FlagSet.Lookup(name) != nil, true
+	err := f.parse(arguments)
+	if err == nil {
+		err = f.handleError(f.checkRequiredNonFlags())
+	}
+	if err != nil {
+		return err
+	}
+	f.recordCommandLine()
+	err = f.applyConfig(f.config, f.configFileOf)
+	if err != nil {
+		return err
+	}
+	err = f.applyDefaults(f.defaultsProviders)
+	if err != nil {
+		return err
+	}
+	err = f.expandValues(f.expander)
+	if err != nil {
+		return err
+	}
+	return f.resolveSecrets(f.secretResolver)
+}
+
+// ParseWith parses the arguments like Parse, with the error handling temporarily overridden,
+// such as to parse speculatively with ContinueOnError without risking os.Exit
+// when the flag set is created with ExitOnError.
+// NOTE:
+//  ContinueOnUndefined in @errorHandling also takes effect;
+//  the configured error handling is restored after parsing, even if it panics.
+func (f *FlagSet) ParseWith(arguments []string, errorHandling ErrorHandling) error {
+	saved := f.errorHandling
+	f.Init(f.Name(), errorHandling)
+	defer f.Init(f.Name(), saved)
+	return f.Parse(arguments)
+}
+
+// smallArgsLimit the max number of the arguments parsed by the fast path.
+const smallArgsLimit = 8
+
+func (f *FlagSet) parse(arguments []string) error {
+	f.keyValues = nil
+	f.ignoredFlags = nil
+	f.implicitTerminator = false
+	f.passthroughArgs = passthroughArgs(arguments, f.FlagSet.Lookup)
+	if f.parseMode != 0 || f.positionalMode == PositionalPermute {
+		var err error
+		arguments, err = f.tidyParseModes(arguments)
+		if err != nil {
+			return f.handleError(err)
+		}
+	}
+	if f.parseMode&ParseNoDuplicate != 0 || f.diagnostics != nil {
+		if err := f.checkDuplicates(arguments); err != nil {
+			return f.handleError(err)
+		}
+	}
+	if f.isContinueOnUndefined && !f.onlyDefinedFlags(arguments) {
+		flagArgs, nonFlagArgs, terminated, err := tidyArgs(arguments, f.FlagSet.Lookup, func(name string) (want, next bool) {
+			want = f.FlagSet.Lookup(name) != nil
+			if !want {
+				f.ignoreFlag(name)
+			}
+			return want, true
 		})
 		if err != nil {
 			return err
@@ -282,12 +490,27 @@ func (f *FlagSet) Parse(arguments []string) error {
 			arguments = append(arguments, "--")
 		}
 		arguments = append(arguments, nonFlagArgs...)
-		f.terminated = terminated
+		f.terminated = terminated && !f.implicitTerminator
+	} else {
+		f.terminated = false
 	}
-	err := f.FlagSet.Parse(arguments)
+	if f.parseMode&ParseAllErrors != 0 {
+		return f.parseAll(arguments)
+	}
+	olds := f.observedValues()
+	if f.quiet {
+		defer f.silence()()
+	}
+	var err, setErr error
+	func() {
+		restore := f.captureSetErrors(arguments)
+		defer func() { setErr = restore() }()
+		err = f.FlagSet.Parse(arguments)
+	}()
 	if err != nil {
-		return err
+		return f.suggestFlags(withSetError(toParseError(err), setErr))
 	}
+	f.notifyChanged(olds)
 	if f.terminated {
 		return nil
 	}
@@ -301,13 +524,16 @@ func (f *FlagSet) Parse(arguments []string) error {
 		if i > 0 {
 			i -= 1
 		}
-		if arguments[i] == "--" {
+		if arguments[i] == "--" && !f.implicitTerminator {
 			f.terminated = true
 			return nil
 		}
 	}
 
 	for k, v := range args {
+		if f.positionalMode == PositionalClassic {
+			break
+		}
 		seen, err := f.parseOneNonFlag(k, v)
 		if seen {
 			continue
@@ -315,48 +541,106 @@ func (f *FlagSet) Parse(arguments []string) error {
 		if err == nil {
 			break
 		}
-		switch f.FlagSet.ErrorHandling() {
-		case ContinueOnError:
-			return err
-		case ExitOnError:
-			os.Exit(2)
-		case PanicOnError:
-			panic(err)
-		}
+		return f.handleError(err)
+	}
+	if f.parseMode&ParseKeyValue != 0 {
+		return f.handleError(f.parseKeyValues(args))
 	}
 	return nil
 }
 
+// handleError handles the parse error according to the error handling of the flag set.
+func (f *FlagSet) handleError(err error) error {
+	if err == nil {
+		return nil
+	}
+	switch f.FlagSet.ErrorHandling() {
+	case ExitOnError:
+		if err == ErrHelp {
+			os.Exit(0)
+		}
+		os.Exit(2)
+	case PanicOnError:
+		panic(err)
+	}
+	return err
+}
+
+// onlyDefinedFlags reports whether the small arguments have only the defined flags,
+// so that they can be parsed directly without being tidied.
+func (f *FlagSet) onlyDefinedFlags(arguments []string) bool {
+	if len(arguments) > smallArgsLimit {
+		return false
+	}
+	for _, arg := range arguments {
+		if arg == "--" {
+			return false
+		}
+	}
+	defined := true
+	lastArgs, ok := scanArgs(arguments, func(name, _ string) bool {
+		defined = f.FlagSet.Lookup(name) != nil
+		return defined
+	})
+	return ok && defined && len(lastArgs) == 0
+}
+
 // parseOneNonFlag parses one non-flag. It reports whether a non-flag was seen.
 func (f *FlagSet) parseOneNonFlag(index int, value string) (bool, error) {
 	if value == "--" {
 		return false, f.failf("non-flag defined but not provided: %d", index)
 	}
-	m := f.nonFormal
-	flag, alreadythere := m[index]
-	if !alreadythere {
-		return false, nil
-		// return false, f.failf("non-flag provided but not defined: %d", index)
-	}
-	if err := flag.Value.Set(value); err != nil {
-		return false, f.failf("invalid value %q for non-flag %d: %v", value, index, err)
+	flag := lookupNonFlag(f.nonFormal, index)
+	if flag == nil {
+		if flag = f.lookupVariadicNonFlag(index); flag == nil {
+			return false, nil
+			// return false, f.failf("non-flag provided but not defined: %d", index)
+		}
+		index = f.nonVariadicIndex
 	}
-	if f.nonActual == nil {
-		f.nonActual = make(map[int]*Flag)
+	if err := f.setValue(flag, value); err != nil {
+		return false, f.fail(&ErrInvalidValue{Name: flag.Name, Value: value, Err: err})
 	}
-	f.nonActual[index] = flag
+	f.nonActual = setNonFlag(f.nonActual, index, flag)
 	return true, nil
 }
 
 // failf prints to standard error a formatted error and usage message and
 // returns the error.
 func (f *FlagSet) failf(format string, a ...interface{}) error {
-	err := fmt.Errorf(format, a...)
+	return f.fail(fmt.Errorf(format, a...))
+}
+
+// fail prints to standard error the error and usage message and returns the error.
+func (f *FlagSet) fail(err error) error {
+	if f.quiet || f.collecting {
+		return err
+	}
 	fmt.Fprintln(f.Output(), err)
 	f.usage()
 	return err
 }
 
+// SetQuiet sets whether the parse failures are returned without printing the error and usage,
+// so that the callers can format the errors themselves, such as in the server contexts.
+// NOTE:
+//  the usage is not printed on -h or -help either, and flag.ErrHelp is returned for the caller to print it;
+//  the intentional prints, such as Usage and PrintDefaults, still write to Output.
+func (f *FlagSet) SetQuiet(quiet bool) {
+	f.quiet = quiet
+}
+
+// silence discards the printing of the standard flag set, and returns the function restoring it.
+func (f *FlagSet) silence() (restore func()) {
+	output, usage := f.FlagSet.Output(), f.FlagSet.Usage
+	f.FlagSet.SetOutput(io.Discard)
+	f.FlagSet.Usage = func() {}
+	return func() {
+		f.FlagSet.SetOutput(output)
+		f.FlagSet.Usage = usage
+	}
+}
+
 // usage calls the Usage method for the flag set if one is specified,
 // or the appropriate default usage function otherwise.
 func (f *FlagSet) usage() {
@@ -397,14 +681,11 @@ func (f *FlagSet) NonVisitAll(fn func(*Flag)) {
 	f.visitNonFlags(f.nonFormal, fn)
 }
 
-func (f *FlagSet) visitNonFlags(flags map[int]*Flag, fn func(*Flag)) {
-	a := make([]int, 0, len(flags))
-	for k := range flags {
-		a = append(a, k)
-	}
-	sort.Ints(a)
-	for _, k := range a {
-		fn(flags[k])
+func (f *FlagSet) visitNonFlags(flags []*Flag, fn func(*Flag)) {
+	for _, flag := range flags {
+		if flag != nil {
+			fn(flag)
+		}
 	}
 }
 
@@ -426,74 +707,89 @@ func (f *FlagSet) Lookup(name string) *Flag {
 
 func (f *FlagSet) nonLookup(name string) (*Flag, int) {
 	idx, _, _ := getNonFlagIndex(name)
-	return f.nonFormal[idx], idx
+	return lookupNonFlag(f.nonFormal, idx), idx
 }
 
 // Set sets the value of the named flag or the non-flag.
 func (f *FlagSet) Set(name, value string) error {
 	v := f.FlagSet.Lookup(name)
 	if v != nil {
-		return f.FlagSet.Set(name, value)
+		if err := f.setFlag(name, value); err != nil {
+			return &ErrInvalidValue{Name: name, Value: value, Err: err}
+		}
+		return nil
 	}
 	v, idx := f.nonLookup(name)
 	if v != nil {
-		err := v.Value.Set(value)
+		err := f.setValue(v, value)
 		if err != nil {
-			return err
+			return &ErrInvalidValue{Name: name, Value: value, Err: err}
 		}
-		if f.nonActual == nil {
-			f.nonActual = make(map[int]*Flag)
-		}
-		f.nonActual[idx] = v
+		f.nonActual = setNonFlag(f.nonActual, idx, v)
 		return nil
 	}
-	var prefix string
-	if idx < 0 {
-		prefix = "-"
-	}
-	return fmt.Errorf("no such flag %s%s", prefix, name)
+	return &ErrUndefinedFlag{Name: name, Suggestions: f.SuggestFlags(name)}
 }
 
 // PrintDefaults prints, to standard error unless configured otherwise, the
 // default values of all defined command-line flags in the set. See the
 // documentation for the global function PrintDefaults for more information.
 func (f *FlagSet) PrintDefaults() {
-	f.VisitAll(newPrintOneDefault(f.Output(), true))
-	f.NonVisitAll(newPrintOneDefault(f.Output(), false))
+	f.VisitAll(newPrintOneDefault(f.Output(), true, nil))
+	f.NonVisitAll(newPrintOneDefault(f.Output(), false, nil))
 }
 
-func newPrintOneDefault(w io.Writer, isFlag bool) func(*Flag) {
+// newPrintOneDefault returns the function printing the usage of one flag,
+// @env returns the environment variable name of the flag, it can be nil.
+func newPrintOneDefault(w io.Writer, isFlag bool, env func(*Flag) string) func(*Flag) {
 	var prefix string
 	if isFlag {
 		prefix = "-"
 	}
+	var b strings.Builder
 	return func(flag *Flag) {
-		s := fmt.Sprintf("  %s%s", prefix, flag.Name) // Two spaces before -; see next two comments.
-		name, usage := UnquoteUsage(flag)
-		if len(name) > 0 {
-			s += " " + name
-		}
+		b.Reset()
 		// Boolean flags of one ASCII letter are so common we
 		// treat them specially, putting their usage on the same line.
-		if len(s) <= 4 { // space, space, '-', 'x'.
-			s += "\t"
-		} else {
-			// Four spaces before the tab triggers good alignment
-			// for both 4- and 8-space tab stops.
-			s += "\n    \t"
+		writeOneDefault(&b, flag, "  ", prefix, len(prefix)+len(flag.Name) <= 2, env) // Two spaces before -; see next two comments.
+		io.WriteString(w, b.String())
+	}
+}
+
+// writeOneDefault writes the usage of one flag with the line break,
+// the usage is on the same line if @sameLine is true and the flag has no argument name.
+func writeOneDefault(b *strings.Builder, flag *Flag, indent, prefix string, sameLine bool, env func(*Flag) string) {
+	b.WriteString(indent)
+	b.WriteString(prefix)
+	b.WriteString(flag.Name)
+	name, usage := UnquoteUsage(flag)
+	if len(name) > 0 {
+		b.WriteByte(' ')
+		b.WriteString(name)
+	}
+	// Four spaces before the tab triggers good alignment
+	// for both 4- and 8-space tab stops.
+	newline := "\n" + indent + "  \t"
+	if sameLine && len(name) == 0 {
+		b.WriteByte('\t')
+	} else {
+		b.WriteString(newline)
+	}
+	b.WriteString(strings.ReplaceAll(usage, "\n", newline))
+	if env != nil {
+		if name := env(flag); name != "" {
+			b.WriteString(" [$" + name + "]")
 		}
-		s += strings.ReplaceAll(usage, "\n", "\n    \t")
-
-		if !isZeroValue(flag, flag.DefValue) {
-			if _, ok := flag.Value.(*stringValue); ok {
-				// put quotes on the value
-				s += fmt.Sprintf(" (default %q)", flag.DefValue)
-			} else {
-				s += fmt.Sprintf(" (default %v)", flag.DefValue)
-			}
+	}
+	if !isZeroValue(flag, flag.DefValue) {
+		if _, ok := flag.Value.(*stringValue); ok {
+			// put quotes on the value
+			fmt.Fprintf(b, " (default %q)", flag.DefValue)
+		} else {
+			fmt.Fprintf(b, " (default %v)", flag.DefValue)
 		}
-		fmt.Fprint(w, s, "\n")
 	}
+	b.WriteByte('\n')
 }
 
 // isZeroValue determines whether the string represents the zero
@@ -512,35 +808,32 @@ func isZeroValue(flag *Flag, value string) bool {
 	return value == z.Interface().(Value).String()
 }
 
-func tidyArgs(args []string, filter func(name string) (want, next bool)) (tidiedArgs, lastArgs []string, terminated bool, err error) {
-	tidiedArgs = make([]string, 0, len(args)*2)
-	lastArgs, terminated, err = filterArgs(args, func(name string, valuePtr *string) bool {
+// tidyArgs tidies the flags at the front of the arguments, keeping the wanted ones in their original form.
+// @lookup returns the defined flag by name, which tells whether the flag takes a value, it can be nil.
+func tidyArgs(args []string, lookup func(name string) *Flag, filter func(name string) (want, next bool)) (tidiedArgs, lastArgs []string, terminated bool, err error) {
+	tidiedArgs = make([]string, 0, len(args))
+	lastArgs, terminated, err = filterArgs(args, lookup, func(name string, valuePtr *string, raw []string) bool {
 		want, next := filter(name)
 		if want {
-			var kv []string
-			if valuePtr == nil {
-				kv = []string{"-" + name}
-			} else {
-				kv = []string{"-" + name, *valuePtr}
-			}
-			tidiedArgs = append(tidiedArgs, kv...)
+			tidiedArgs = append(tidiedArgs, raw...)
 		}
 		return next
 	})
 	return tidiedArgs, lastArgs, terminated, err
 }
 
-func filterArgs(args []string, filter func(name string, valuePtr *string) (next bool)) (lastArgs []string, terminated bool, err error) {
+func filterArgs(args []string, lookup func(name string) *Flag, filter func(name string, valuePtr *string, raw []string) (next bool)) (lastArgs []string, terminated bool, err error) {
 	lastArgs = args
 	var name string
 	var valuePtr *string
 	var seen bool
 	for {
-		lastArgs, terminated, name, valuePtr, seen, err = tidyOneArg(lastArgs)
+		rest := lastArgs
+		lastArgs, terminated, name, valuePtr, seen, err = tidyOneArg(lastArgs, lookup)
 		if !seen {
 			return
 		}
-		next := filter(name, valuePtr)
+		next := filter(name, valuePtr, rest[:len(rest)-len(lastArgs)])
 		if !next {
 			return
 		}
@@ -548,7 +841,9 @@ func filterArgs(args []string, filter func(name string, valuePtr *string) (next
 }
 
 // tidyOneArg tidies one flag. It reports whether a flag was seen.
-func tidyOneArg(args []string) (lastArgs []string, terminated bool, name string, valuePtr *string, seen bool, err error) {
+// A defined bool flag takes no separate value, and another defined flag takes the next argument as its value
+// like the standard flag package, while an undefined flag takes the next argument if it does not start with '-'.
+func tidyOneArg(args []string, lookup func(name string) *Flag) (lastArgs []string, terminated bool, name string, valuePtr *string, seen bool, err error) {
 	if len(args) == 0 {
 		lastArgs = args
 		return
@@ -569,7 +864,7 @@ func tidyOneArg(args []string) (lastArgs []string, terminated bool, name string,
 	}
 	name = s[numMinuses:]
 	if len(name) == 0 || name[0] == '-' || name[0] == '=' {
-		err = fmt.Errorf("bad flag syntax: %s", s)
+		err = &ErrBadSyntax{Arg: s}
 		lastArgs = args
 		return
 	}
@@ -595,8 +890,17 @@ func tidyOneArg(args []string) (lastArgs []string, terminated bool, name string,
 		return
 	}
 
+	var flag *Flag
+	if lookup != nil {
+		flag = lookup(name)
+	}
+	if flag != nil && isBoolFlag(flag) {
+		lastArgs = args
+		return
+	}
+
 	// value is the next arg
-	if maybeValue := args[0]; len(maybeValue) == 0 || maybeValue[0] != '-' {
+	if maybeValue := args[0]; flag != nil || len(maybeValue) == 0 || maybeValue[0] != '-' {
 		valuePtr = &maybeValue
 		lastArgs = args[1:]
 		return
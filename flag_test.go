@@ -1,10 +1,24 @@
 package flagx
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"log/slog"
+	"net"
 	"os"
+	"reflect"
+	"runtime"
+	"runtime/debug"
 	"strconv"
+	"strings"
 	"testing"
+	"testing/fstest"
+	"text/template"
 	"time"
 
 	"github.com/stretchr/testify/assert"
@@ -52,7 +66,7 @@ func ExampleStructVars() {
 	// {Run:^(TestStructVars)$ Timeout:30s V:true X:10 Y:flag_test.go}
 }
 
-func ExampleMoreStructVars() {
+func ExampleFlagSet_StructVars() {
 	type Anonymous struct {
 		F    float64 `flag:"f"`
 		Non3 int     `flag:"?3"`
@@ -107,7 +121,7 @@ func TestTidyArgs(t *testing.T) {
 		{"-run", "", "-t", "0", "-x", "-N", "0", "-y", "z"},
 		{"-run", "", "m"},
 	} {
-		tidiedArgs, lastArgs, _, err := tidyArgs(a, func(string) (want bool, next bool) { return true, true })
+		tidiedArgs, lastArgs, _, err := tidyArgs(a, nil, func(string) (want bool, next bool) { return true, true })
 		assert.NoError(t, err)
 		switch i {
 		case 0, 1, 2, 3:
@@ -118,11 +132,11 @@ func TestTidyArgs(t *testing.T) {
 		t.Logf("i:%d, tidiedArgs:%#v", i, tidiedArgs)
 	}
 	args := []string{"-run", "abc", "--", "-c", "2"}
-	tidiedArgs, args, _, err := tidyArgs(args, func(string) (want bool, next bool) { return true, true })
+	tidiedArgs, args, _, err := tidyArgs(args, nil, func(string) (want bool, next bool) { return true, true })
 	assert.NoError(t, err)
 	assert.Equal(t, []string{"-run", "abc"}, tidiedArgs)
 	assert.Equal(t, []string{"-c", "2"}, args)
-	tidiedArgs, args, _, err = tidyArgs(args, func(string) (want bool, next bool) { return true, true })
+	tidiedArgs, args, _, err = tidyArgs(args, nil, func(string) (want bool, next bool) { return true, true })
 	assert.NoError(t, err)
 	assert.Equal(t, []string{"-c", "2"}, tidiedArgs)
 	assert.Equal(t, []string{}, args)
@@ -173,4 +187,912 @@ func TestNonVar(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "abc", *runVal)
 	fs.Usage()
+
+	fs = NewFlagSet("non-flag-test3", ContinueOnError)
+	lastVal := fs.NonString(2, "", "")
+	firstVal := fs.NonString(0, "", "")
+	assert.Equal(t, 3, fs.NFormalNonFlag())
+	assert.Panics(t, func() { fs.NonString(2, "", "") })
+	err = fs.Parse([]string{"a"})
+	assert.NoError(t, err)
+	assert.NoError(t, fs.Set("?2", "c"))
+	assert.Equal(t, "a", *firstVal)
+	assert.Equal(t, "c", *lastVal)
+	var names []string
+	fs.NonVisit(func(f *Flag) {
+		names = append(names, f.Name)
+	})
+	assert.Equal(t, []string{"?0", "?2"}, names)
+	assert.Nil(t, fs.Lookup("?1"))
+	assert.Nil(t, fs.Lookup("?3"))
+}
+
+func TestBindConfigFile(t *testing.T) {
+	filename := t.TempDir() + "/config.json"
+	err := os.WriteFile(filename, []byte(`{"x":"config","y":8080,"db":{"host":"localhost"},"tags":["a","b"]}`), 0644)
+	assert.NoError(t, err)
+
+	fs := NewFlagSet("test", ContinueOnError)
+	x := fs.String("x", "default", "")
+	y := fs.Int("y", 0, "")
+	host := fs.String("db.host", "", "")
+	tags := fs.String("tags", "", "")
+	z := fs.String("z", "default", "")
+	assert.NoError(t, fs.BindConfigFile(filename, JSONDecoder))
+	assert.NoError(t, fs.Parse([]string{"-x", "cli"}))
+	assert.Equal(t, "cli", *x)
+	assert.Equal(t, 8080, *y)
+	assert.Equal(t, "localhost", *host)
+	assert.Equal(t, "a,b", *tags)
+	assert.Equal(t, "default", *z)
+
+	fs = NewFlagSet("test", ContinueOnError)
+	y = fs.Int("y", 0, "")
+	assert.NoError(t, fs.Parse([]string{"-y", "1"}))
+	assert.NoError(t, fs.BindConfigFile(filename, JSONDecoder))
+	assert.Equal(t, 1, *y)
+}
+
+func TestTOMLDecoder(t *testing.T) {
+	m, err := TOMLDecoder([]byte(`
+# comment
+title = "app # 1" # trailing comment
+port = 8_080
+ratio = 0.5
+debug = true
+tags = ["a", 'b', 3]
+
+[db]
+host = "localhost"
+server.timeout = "3s"
+`))
+	assert.NoError(t, err)
+	values := make(map[string]string)
+	flattenConfig(values, "", m)
+	assert.Equal(t, map[string]string{
+		"title":             "app # 1",
+		"port":              "8080",
+		"ratio":             "0.5",
+		"debug":             "true",
+		"tags":              "a,b,3",
+		"db.host":           "localhost",
+		"db.server.timeout": "3s",
+	}, values)
+
+	_, err = TOMLDecoder([]byte("[[servers]]"))
+	assert.Error(t, err)
+
+	m, err = TOMLDecoder([]byte("a = 0x1F\nb = 0o17\nc = 0b101\nd = -0\ne = +10\nf = 1979-05-27\ng = 07:32:00"))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"a": int64(31), "b": int64(15), "c": int64(5), "d": int64(0), "e": int64(10), "f": "1979-05-27", "g": "07:32:00"}, m)
+	for _, s := range []string{"n = 010", "n = -01", "n = 01.5", "n = 0x1G"} {
+		_, err = TOMLDecoder([]byte(s))
+		assert.Error(t, err, s)
+	}
+}
+
+func TestINIDecoder(t *testing.T) {
+	m, err := INIDecoder([]byte(`
+; comment
+name = app
+# comment
+[db]
+host = "localhost"
+port: 3306
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"name": "app", "db.host": "localhost", "db.port": "3306"}, m)
+
+	fs := NewFlagSet("test", ContinueOnError)
+	host := fs.String("db.host", "", "")
+	filename := t.TempDir() + "/app.ini"
+	assert.NoError(t, os.WriteFile(filename, []byte("[db]\nhost=127.0.0.1\n"), 0644))
+	assert.NoError(t, fs.BindConfigFile(filename, INIDecoder))
+	assert.NoError(t, fs.Parse(nil))
+	assert.Equal(t, "127.0.0.1", *host)
+}
+
+func TestSetExpander(t *testing.T) {
+	t.Setenv("FLAGX_DIR", "/data")
+	fs := NewFlagSet("test", ContinueOnError)
+	dir := fs.String("dir", "", "")
+	n := fs.Int("n", 0, "")
+	path := fs.NonString(0, "", "")
+	assert.NoError(t, fs.Parse([]string{"-dir", "${FLAGX_DIR}/logs", "-n", "1", "$FLAGX_DIR"}))
+	assert.Equal(t, "${FLAGX_DIR}/logs", *dir)
+
+	fs = NewFlagSet("test", ContinueOnError)
+	dir = fs.String("dir", "", "")
+	n = fs.Int("n", 0, "")
+	path = fs.NonString(0, "", "")
+	fs.SetExpander(func(name string) string {
+		if name == "HOME" {
+			return "/home/flagx"
+		}
+		return os.Getenv(name)
+	})
+	assert.NoError(t, fs.Parse([]string{"-dir", "${FLAGX_DIR}/logs", "-n", "1", "$HOME/a"}))
+	assert.Equal(t, "/data/logs", *dir)
+	assert.Equal(t, 1, *n)
+	assert.Equal(t, "/home/flagx/a", *path)
+}
+
+func TestSnapshot(t *testing.T) {
+	filename := t.TempDir() + "/config.json"
+	err := os.WriteFile(filename, []byte(`{"x":"config","dir":"$FLAGX_DIR/logs"}`), 0644)
+	assert.NoError(t, err)
+	t.Setenv("FLAGX_DIR", "/data")
+
+	fs := NewFlagSet("test", ContinueOnError)
+	fs.String("x", "default", "")
+	fs.Int("y", 0, "")
+	fs.String("z", "default", "")
+	fs.String("dir", "", "")
+	fs.SetExpander(os.Getenv)
+	assert.NoError(t, fs.BindConfigFile(filename, JSONDecoder))
+	assert.NoError(t, fs.Parse([]string{"-y", "0x10"}))
+	assert.Equal(t, map[string]ResolvedValue{
+		"x":   {Value: "config", Source: SourceConfig, Raw: "config", From: filename},
+		"y":   {Value: "16", Source: SourceCommandLine, Raw: "16"},
+		"z":   {Value: "default", Source: SourceDefault, Raw: "default"},
+		"dir": {Value: "/data/logs", Source: SourceConfig, Raw: "$FLAGX_DIR/logs", From: filename},
+	}, fs.Snapshot())
+	assert.Equal(t, "cli", SourceCommandLine.String())
+}
+
+func TestPrintEffective(t *testing.T) {
+	filename := t.TempDir() + "/config.json"
+	err := os.WriteFile(filename, []byte(`{"x":"config","token":"abc"}`), 0644)
+	assert.NoError(t, err)
+
+	fs := NewFlagSet("test", ContinueOnError)
+	fs.String("x", "default", "")
+	fs.Int("y", 0, "")
+	fs.Int("z", 1, "")
+	fs.String("token", "", "")
+	assert.NoError(t, fs.MarkSecret("token"))
+	assert.NoError(t, fs.BindConfigFile(filename, JSONDecoder))
+	assert.NoError(t, fs.Parse([]string{"-y", "2"}))
+	var buf bytes.Buffer
+	fs.SetOutput(&buf)
+	fs.PrintEffective()
+	assert.Equal(t, `  -token=******	(from config `+filename+`)
+  -x="config"	(from config `+filename+`)
+  -y=2	(from command line)
+  -z=1	(default)
+`, buf.String())
+}
+
+func TestAddDefaultsProvider(t *testing.T) {
+	fs := NewFlagSet("test", ContinueOnError)
+	workers := fs.Int("workers", 1, "")
+	region := fs.String("region", "", "")
+	x := fs.String("x", "default", "")
+	fs.AddDefaultsProvider(func(name string) (string, bool) {
+		if name == "workers" {
+			return "8", true
+		}
+		return "", false
+	}, func(name string) (string, bool) {
+		if name == "workers" || name == "region" {
+			return "us-east-1", true
+		}
+		return "", false
+	})
+	assert.NoError(t, fs.Parse([]string{"-region", "cn-north-1"}))
+	assert.Equal(t, 8, *workers)
+	assert.Equal(t, "cn-north-1", *region)
+	assert.Equal(t, "default", *x)
+	assert.Equal(t, SourceProvider, fs.Snapshot()["workers"].Source)
+}
+
+func TestWriteConfig(t *testing.T) {
+	fs := NewFlagSet("test", ContinueOnError)
+	fs.String("x", "default", "the `name` of x")
+	fs.Int("db.port", 0, "")
+	fs.Bool("v", false, "")
+	assert.NoError(t, fs.Parse([]string{"-db.port", "3306", "-v"}))
+
+	var buf bytes.Buffer
+	assert.NoError(t, fs.WriteConfig(&buf, ConfigTOML, false))
+	assert.Equal(t, "db.port = 3306\n\nv = true\n\n# the name of x\nx = \"default\"\n", buf.String())
+	buf.Reset()
+	assert.NoError(t, fs.WriteConfig(&buf, ConfigINI, true))
+	assert.Equal(t, "db.port = 3306\n\nv = true\n", buf.String())
+	buf.Reset()
+	assert.NoError(t, fs.WriteConfig(&buf, ConfigJSON, true))
+	assert.Equal(t, "{\n  \"db\": {\n    \"port\": 3306\n  },\n  \"v\": true\n}\n", buf.String())
+
+	filename := t.TempDir() + "/config.json"
+	assert.NoError(t, os.WriteFile(filename, buf.Bytes(), 0644))
+	fs = NewFlagSet("test", ContinueOnError)
+	port := fs.Int("db.port", 0, "")
+	assert.NoError(t, fs.BindConfigFile(filename, JSONDecoder))
+	assert.NoError(t, fs.Parse(nil))
+	assert.Equal(t, 3306, *port)
+}
+
+func TestWatchConfig(t *testing.T) {
+	filename := t.TempDir() + "/config.json"
+	err := os.WriteFile(filename, []byte(`{"x":"v1","y":1}`), 0644)
+	assert.NoError(t, err)
+
+	fs := NewFlagSet("test", ContinueOnError)
+	x := fs.String("x", "default", "")
+	y := fs.Int("y", 0, "")
+	z := fs.Int("z", 0, "")
+	assert.NoError(t, fs.BindConfigFile(filename, JSONDecoder))
+	assert.NoError(t, fs.Parse([]string{"-z", "3"}))
+	assert.Equal(t, "v1", *x)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan string, 4)
+	err = fs.WatchConfig(ctx, 10*time.Millisecond, func(name string, oldValue, newValue interface{}) {
+		changes <- fmt.Sprintf("%s:%v->%v", name, oldValue, newValue)
+	})
+	assert.NoError(t, err)
+	go func() {
+		for ctx.Err() == nil {
+			fs.Snapshot()
+		}
+	}()
+	err = os.WriteFile(filename, []byte(`{"y":2,"z":4,"ignored":true}`), 0644)
+	assert.NoError(t, err)
+	assert.Equal(t, "x:v1->default", <-changes)
+	assert.Equal(t, "y:1->2", <-changes)
+	assert.Equal(t, 2, *y)
+	assert.Equal(t, 3, *z)
+
+	// the file replaced by renaming is followed
+	err = os.WriteFile(filename+".tmp", []byte(`{"y":5}`), 0644)
+	assert.NoError(t, err)
+	assert.NoError(t, os.Rename(filename+".tmp", filename))
+	assert.Equal(t, "y:2->5", <-changes)
+	assert.Equal(t, ResolvedValue{Value: "5", Source: SourceConfig, Raw: "5", From: filename}, fs.Snapshot()["y"])
+
+	// the slice flag is replaced
+	filename = t.TempDir() + "/tags.json"
+	err = os.WriteFile(filename, []byte(`{"tags":["a","b"]}`), 0644)
+	assert.NoError(t, err)
+	tagFS := NewFlagSet("test", ContinueOnError)
+	tags := tagFS.StringSlice("tags", nil, "")
+	assert.NoError(t, tagFS.BindConfigFile(filename, JSONDecoder))
+	assert.NoError(t, tagFS.Parse(nil))
+	assert.Equal(t, []string{"a", "b"}, *tags)
+	err = tagFS.WatchConfig(ctx, 10*time.Millisecond, func(name string, oldValue, newValue interface{}) {
+		changes <- fmt.Sprintf("%s:%v->%v", name, oldValue, newValue)
+	})
+	assert.NoError(t, err)
+	err = os.WriteFile(filename, []byte(`{"tags":["c"]}`), 0644)
+	assert.NoError(t, err)
+	assert.Equal(t, "tags:[a b]->[c]", <-changes)
+	assert.Equal(t, []string{"c"}, *tags)
+	assert.Error(t, NewFlagSet("test", ContinueOnError).WatchConfig(ctx, 0, nil))
+}
+
+type mapStore map[string]interface{}
+
+func (m mapStore) Get(key string) interface{} {
+	var v interface{} = map[string]interface{}(m)
+	for _, k := range strings.Split(key, ".") {
+		sub, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = sub[k]
+	}
+	return v
+}
+
+func TestConfigStoreBridge(t *testing.T) {
+	store := mapStore{"db": map[string]interface{}{"host": "127.0.0.1", "port": 3306}, "tags": []interface{}{"a", "b"}}
+	fs := NewFlagSet("test", ContinueOnError)
+	host := fs.String("db.host", "", "")
+	port := fs.Int("db.port", 0, "")
+	tags := fs.String("tags", "", "")
+	fs.Bool("v", false, "")
+	fs.AddDefaultsProvider(StoreDefaults(store))
+	assert.NoError(t, fs.Parse([]string{"-db.port", "3307", "-v"}))
+	assert.Equal(t, "127.0.0.1", *host)
+	assert.Equal(t, 3307, *port)
+	assert.Equal(t, "a,b", *tags)
+
+	m, err := NewFlagProvider(fs, false).Read()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"db":   map[string]interface{}{"host": "127.0.0.1", "port": 3307},
+		"tags": "a,b",
+		"v":    true,
+	}, m)
+	fs = NewFlagSet("test", ContinueOnError)
+	fs.Int("db.port", 0, "")
+	fs.Bool("v", false, "")
+	assert.NoError(t, fs.Parse([]string{"-db.port", "3307"}))
+	m, err = NewFlagProvider(fs, true).Read()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"db": map[string]interface{}{"port": 3307}}, m)
+	_, err = NewFlagProvider(fs, false).ReadBytes()
+	assert.Error(t, err)
+}
+
+type definerStruct struct {
+	Name  string `flag:"name"`
+	extra *int
+}
+
+func (d *definerStruct) DefineFlags(f *FlagSet) error {
+	d.extra = f.Int("extra", 1, "the flag defined at runtime")
+	return nil
+}
+
+func TestFlagDefiner(t *testing.T) {
+	var d definerStruct
+	fs := NewFlagSet("test", ContinueOnError)
+	assert.NoError(t, fs.StructVars(&d))
+	assert.NoError(t, fs.Parse([]string{"-name", "x", "-extra", "2"}))
+	assert.Equal(t, "x", d.Name)
+	assert.Equal(t, 2, *d.extra)
+}
+
+func TestSetDiagnostics(t *testing.T) {
+	t.Setenv("FLAGX_TEST_X", "env")
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+	fs := NewFlagSet("test", ContinueOnError|ContinueOnUndefined)
+	fs.String("x", "", "")
+	fs.Int("y", 0, "")
+	fs.SetDiagnostics(logger)
+	assert.NoError(t, fs.Parse([]string{"-y", "1", "-z", "2"}))
+	assert.NoError(t, fs.applyEnv("FLAGX_TEST", nil))
+	assert.Equal(t, `level=WARN msg="flagx: undefined flag ignored" flag=z flagset=test
+level=DEBUG msg="flagx: flag value applied" flag=x source=env from=FLAGX_TEST_X flagset=test
+`, buf.String())
+}
+
+type PlanEmbedded struct {
+	Token string `flag:"token;secret"`
+}
+
+type planStruct struct {
+	*PlanEmbedded
+	ID   int    `flag:"id;def=1"`
+	Name string `flag:"?0"`
+	Tags []int  `flag:"tags;def=1,2"`
+}
+
+func TestStructPlan(t *testing.T) {
+	for i := 0; i < 2; i++ {
+		var p planStruct
+		fs := NewFlagSet("test", ContinueOnError)
+		assert.NoError(t, fs.StructVars(&p))
+		assert.NoError(t, fs.Parse([]string{"-token", "t", "n"}))
+		assert.Equal(t, "t", p.Token)
+		assert.Equal(t, 1, p.ID)
+		assert.Equal(t, "n", p.Name)
+		assert.True(t, fs.IsSecret("token"))
+		assert.Equal(t, []int{1, 2}, p.Tags)
+		// the default values compiled in the plan are not shared by the bound structs
+		p.Tags[0] = 3
+	}
+	plan := structPlanOf(reflect.TypeOf(planStruct{}))
+	assert.Len(t, plan.fields, 4)
+	assert.Equal(t, []int{0, 0}, plan.fields[3].index)
+	assert.Equal(t, []int{0}, plan.fields[1].nonFlags)
+	assert.Equal(t, 1, plan.fields[2].def)
+
+	type badDefault struct {
+		N int `flag:"n;def=x"`
+	}
+	assert.EqualError(t, NewFlagSet("test", ContinueOnError).StructVars(new(badDefault)), `flagx: "x" cannot be converted to int`)
+}
+
+// BenchmarkParseSmallArgs measures the fast path of the small arguments with only the defined flags.
+// Before the fast path: ~1150 ns/op, 290 B/op, 8 allocs/op;
+// after: ~650-900 ns/op, 24 B/op, 1 allocs/op.
+func BenchmarkParseSmallArgs(b *testing.B) {
+	args := []string{"-name", "x", "-n=2", "-v"}
+	fs := NewFlagSet("bench", ContinueOnError|ContinueOnUndefined)
+	fs.String("name", "", "")
+	fs.Int("n", 0, "")
+	fs.Bool("v", false, "")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := fs.Parse(args); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestParseMultiValue(t *testing.T) {
+	type Args struct {
+		Files []string `flag:"files,f; def=x.txt"`
+		V     bool     `flag:"v"`
+		N     int      `flag:"n"`
+	}
+	var args Args
+	fs := NewFlagSet("test", ContinueOnError)
+	assert.NoError(t, fs.StructVars(&args))
+	assert.Equal(t, []string{"x.txt"}, args.Files)
+	assert.NoError(t, fs.Parse([]string{"--files", "a.txt", "b.txt"}))
+	assert.Equal(t, []string{"a.txt"}, args.Files)
+	assert.Equal(t, []string{"b.txt"}, fs.Args())
+
+	args = Args{}
+	fs = NewFlagSet("test", ContinueOnError)
+	fs.SetParseMode(ParseMultiValue)
+	assert.NoError(t, fs.StructVars(&args))
+	assert.NoError(t, fs.Parse([]string{"--files", "a.txt", "b.txt", "-v", "-n", "3", "-f", "c.txt", "--", "d.txt"}))
+	assert.Equal(t, []string{"a.txt", "b.txt", "c.txt"}, args.Files)
+	assert.True(t, args.V)
+	assert.Equal(t, 3, args.N)
+	assert.Equal(t, []string{"d.txt"}, fs.Args())
+
+	fs = NewFlagSet("test", ContinueOnError|ContinueOnUndefined)
+	fs.SetParseMode(ParseMultiValue)
+	files := fs.StringSlice("files", nil, "")
+	assert.NoError(t, fs.Parse([]string{"-x", "1", "-files", "a.txt", "b.txt", "-y", "-files=c.txt"}))
+	assert.Equal(t, []string{"a.txt", "b.txt", "c.txt"}, *files)
+
+	// the elements of the config array are separate values
+	fs = NewFlagSet("test", ContinueOnError)
+	fs.SetFS(fstest.MapFS{"config.json": {Data: []byte(`{"files":["a.txt","b.txt"]}`)}})
+	files = fs.StringSlice("files", []string{"x.txt"}, "")
+	assert.NoError(t, fs.BindConfigFile("config.json", JSONDecoder))
+	assert.NoError(t, fs.Parse(nil))
+	assert.Equal(t, []string{"a.txt", "b.txt"}, *files)
+}
+
+func TestParseAttachedValue(t *testing.T) {
+	fs := NewFlagSet("test", ContinueOnError)
+	fs.SetParseMode(ParseAttachedValue)
+	n := fs.Int("n", 0, "")
+	o := fs.String("o", "", "")
+	no := fs.Bool("no", false, "")
+	v := fs.Bool("v", false, "")
+	assert.NoError(t, fs.Parse([]string{"-n5", "-ofile", "-no", "--", "-n6"}))
+	assert.Equal(t, 5, *n)
+	assert.Equal(t, "file", *o)
+	assert.True(t, *no)
+	assert.Equal(t, []string{"-n6"}, fs.Args())
+
+	// the value of a flag is never split
+	assert.NoError(t, fs.Parse([]string{"-o", "-n7"}))
+	assert.Equal(t, "-n7", *o)
+	assert.Equal(t, 5, *n)
+
+	// the bool letters are not clustered
+	fs.SetOutput(io.Discard)
+	assert.EqualError(t, fs.Parse([]string{"-vo"}), "flag provided but not defined: -vo")
+	assert.False(t, *v)
+}
+
+func TestParseNegativeNumber(t *testing.T) {
+	fs := NewFlagSet("test", ContinueOnError)
+	fs.SetParseMode(ParseNegativeNumber)
+	delta := fs.Int("delta", 0, "")
+	one := fs.Bool("1", false, "")
+	offset := fs.NonFloat64(0, 0, "")
+	assert.NoError(t, fs.Parse([]string{"-delta", "-3", "-1", "-2.5", "x"}))
+	assert.Equal(t, -3, *delta)
+	assert.True(t, *one)
+	assert.Equal(t, -2.5, *offset)
+	assert.Equal(t, []string{"-2.5", "x"}, fs.Args())
+
+	fs = NewFlagSet("test", ContinueOnError|ContinueOnUndefined)
+	fs.SetParseMode(ParseNegativeNumber)
+	delta = fs.Int("delta", 0, "")
+	offset = fs.NonFloat64(0, 0, "")
+	assert.NoError(t, fs.Parse([]string{"-x", "-delta", "-0x10", "-7"}))
+	assert.Equal(t, -16, *delta)
+	assert.Equal(t, -7.0, *offset)
+}
+
+func TestParseKeyValue(t *testing.T) {
+	fs := NewFlagSet("test", ContinueOnError)
+	fs.SetParseMode(ParseKeyValue)
+	name := fs.String("name", "", "")
+	n := fs.Int("n", 0, "")
+	path := fs.NonString(0, "", "")
+	assert.NoError(t, fs.Parse([]string{"-n", "1", "~/a=b", "name=henry", "CC=gcc", "n=2"}))
+	assert.Equal(t, "henry", *name)
+	assert.Equal(t, 2, *n)
+	assert.Equal(t, "~/a=b", *path)
+	assert.Equal(t, map[string]string{"name": "henry", "CC": "gcc", "n": "2"}, fs.KeyValues())
+	assert.Equal(t, []string{"~/a=b", "name=henry", "CC=gcc", "n=2"}, fs.Args())
+
+	fs.SetOutput(io.Discard)
+	assert.EqualError(t, fs.Parse([]string{"n=x"}), `invalid value "x" for flag -n: parse error`)
+}
+
+func TestParseStrictBool(t *testing.T) {
+	fs := NewFlagSet("test", ContinueOnError)
+	fs.SetOutput(io.Discard)
+	v := fs.Bool("v", false, "")
+	assert.NoError(t, fs.Parse([]string{"-v", "false"}))
+	assert.True(t, *v)
+	assert.Equal(t, []string{"false"}, fs.Args())
+
+	fs.SetParseMode(ParseStrictBool)
+	assert.EqualError(t, fs.Parse([]string{"-v", "false"}), `bool flag -v does not take a separate value "false", use -v=false`)
+	assert.NoError(t, fs.Parse([]string{"-v=false", "file"}))
+	assert.False(t, *v)
+	assert.NoError(t, fs.Parse([]string{"--v", "file"}))
+	assert.True(t, *v)
+	assert.Equal(t, []string{"file"}, fs.Args())
+	assert.EqualError(t, fs.Parse([]string{"-v", "TRUE"}), `bool flag -v does not take a separate value "TRUE", use -v=TRUE`)
+	assert.NoError(t, fs.Parse([]string{"-v", "1", "t"}))
+	assert.Equal(t, []string{"1", "t"}, fs.Args())
+}
+
+func TestContinueOnUndefinedArgForm(t *testing.T) {
+	fs := NewFlagSet("test", ContinueOnError|ContinueOnUndefined)
+	v := fs.Bool("v", true, "")
+	name := fs.String("name", "x", "")
+	n := fs.Int("n", 0, "")
+	assert.NoError(t, fs.Parse([]string{"-u", "-v=false", "-name=", "-n", "-5", "-y", "z", "file", "-n", "6"}))
+	assert.False(t, *v)
+	assert.Equal(t, "", *name)
+	assert.Equal(t, -5, *n)
+	assert.Equal(t, []string{"file", "-n", "6"}, fs.Args())
+
+	// a bool flag does not take the next argument
+	assert.NoError(t, fs.Parse([]string{"-v", "file", "-n", "7"}))
+	assert.True(t, *v)
+	assert.Equal(t, -5, *n)
+	assert.Equal(t, []string{"file", "-n", "7"}, fs.Args())
+}
+
+func TestParseNoDuplicate(t *testing.T) {
+	var buf bytes.Buffer
+	fs := NewFlagSet("test", ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.SetDiagnostics(slog.New(slog.NewTextHandler(&buf, nil)))
+	n := fs.Int("n", 0, "")
+	fs.StringSlice("files", nil, "")
+	assert.NoError(t, fs.Parse([]string{"-n", "1", "-files", "a", "-n=2", "-files=b"}))
+	assert.Equal(t, 2, *n)
+	assert.Contains(t, buf.String(), "duplicate flag")
+	assert.Equal(t, 1, strings.Count(buf.String(), "duplicate flag"))
+
+	fs.SetParseMode(ParseNoDuplicate)
+	assert.EqualError(t, fs.Parse([]string{"-n", "3", "-files", "a", "-files=b", "--n", "4"}), "flag provided more than once: -n")
+}
+
+func TestIgnoredFlags(t *testing.T) {
+	fs := NewFlagSet("test", ContinueOnError|ContinueOnUndefined)
+	fs.Bool("verbose", false, "")
+	var warnings []string
+	fs.SetIgnoredFlagFunc(func(f *FlagSet, name string) {
+		warnings = append(warnings, fmt.Sprintf("unknown flag -%s ignored", name))
+	})
+	assert.NoError(t, fs.Parse([]string{"-verbse", "-x=1", "-verbose"}))
+	assert.Equal(t, []string{"verbse", "x"}, fs.IgnoredFlags())
+	assert.Equal(t, []string{"unknown flag -verbse ignored", "unknown flag -x ignored"}, warnings)
+	assert.NoError(t, fs.Parse([]string{"-verbose"}))
+	assert.Empty(t, fs.IgnoredFlags())
+}
+
+func TestSetPositionalMode(t *testing.T) {
+	args := []string{"-n", "1", "a.txt", "-v", "b.txt", "--", "-c"}
+	fs := NewFlagSet("test", ContinueOnError)
+	n := fs.Int("n", 0, "")
+	v := fs.Bool("v", false, "")
+	first := fs.NonString(0, "", "")
+	assert.NoError(t, fs.Parse(args))
+	assert.Equal(t, "a.txt", *first)
+	assert.False(t, *v)
+
+	*first = ""
+	fs.SetPositionalMode(PositionalClassic)
+	assert.NoError(t, fs.Parse(args))
+	assert.Equal(t, "", *first)
+	assert.Equal(t, []string{"a.txt", "-v", "b.txt", "--", "-c"}, fs.Args())
+
+	fs.SetPositionalMode(PositionalPermute)
+	assert.NoError(t, fs.Parse(args))
+	assert.Equal(t, 1, *n)
+	assert.True(t, *v)
+	assert.Equal(t, "a.txt", *first)
+	assert.Equal(t, []string{"a.txt", "b.txt", "-c"}, fs.Args())
+}
+
+func TestPassthroughArgs(t *testing.T) {
+	fs := NewFlagSet("test", ContinueOnError)
+	fs.String("image", "", "")
+	assert.NoError(t, fs.Parse([]string{"-image", "--", "run", "--", "docker", "ps", "-a"}))
+	assert.Equal(t, []string{"run", "--", "docker", "ps", "-a"}, fs.NextArgs())
+	assert.Equal(t, []string{"docker", "ps", "-a"}, fs.PassthroughArgs())
+	assert.NoError(t, fs.Parse([]string{"--"}))
+	assert.Equal(t, []string{}, fs.PassthroughArgs())
+	assert.NoError(t, fs.Parse([]string{"run"}))
+	assert.Nil(t, fs.PassthroughArgs())
+}
+
+func TestExpandAbbreviation(t *testing.T) {
+	fs := NewFlagSet("test", ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.SetParseMode(ParseAbbreviation)
+	timeout := fs.Duration("timeout", 0, "")
+	fs.Var(fs.Lookup("timeout").Value, "time", "")
+	fs.Bool("trace", false, "")
+	n := fs.Int("n", 0, "")
+	assert.NoError(t, fs.Parse([]string{"-tim", "5s", "-n", "1"}))
+	assert.Equal(t, 5*time.Second, *timeout)
+	assert.Equal(t, 1, *n)
+	assert.NoError(t, fs.Parse([]string{"--timeo=6s"}))
+	assert.Equal(t, 6*time.Second, *timeout)
+	assert.EqualError(t, fs.Parse([]string{"-t=1s"}), "ambiguous flag -t, candidates: -time, -trace")
+}
+
+func TestOnSet(t *testing.T) {
+	fs := NewFlagSet("test", ContinueOnError)
+	fs.Int("n", 1, "")
+	fs.NonString(0, "", "")
+	var events []string
+	observe := func(name string) func(old, new string) {
+		return func(old, new string) {
+			events = append(events, name+":"+old+"->"+new)
+		}
+	}
+	fs.OnSet("n", observe("n"))
+	fs.OnSet("?0", observe("?0"))
+	assert.NoError(t, fs.Parse([]string{"-n", "2", "-n", "3", "a"}))
+	assert.NoError(t, fs.Set("n", "3"))
+	assert.NoError(t, fs.Set("n", "4"))
+	assert.NoError(t, fs.Set("?0", "b"))
+	assert.Equal(t, []string{"n:1->3", "?0:->a", "n:3->4", "?0:a->b"}, events)
+}
+
+func TestParseWith(t *testing.T) {
+	fs := NewFlagSet("test", ExitOnError)
+	fs.SetOutput(io.Discard)
+	n := fs.Int("n", 0, "")
+	assert.EqualError(t, fs.ParseWith([]string{"-x"}, ContinueOnError), "flag provided but not defined: -x")
+	assert.NoError(t, fs.ParseWith([]string{"-x", "-n", "1"}, ContinueOnError|ContinueOnUndefined))
+	assert.Equal(t, 1, *n)
+	assert.Panics(t, func() { fs.ParseWith([]string{"-n", "x"}, PanicOnError) })
+	assert.Equal(t, ExitOnError, fs.ErrorHandling())
+	assert.Equal(t, ExitOnError, fs.FlagSet.ErrorHandling())
+}
+
+func TestSetQuiet(t *testing.T) {
+	var buf bytes.Buffer
+	fs := NewFlagSet("test", ContinueOnError)
+	fs.SetOutput(&buf)
+	fs.SetQuiet(true)
+	fs.Int("n", 0, "count")
+	fs.NonInt(0, 0, "")
+	assert.EqualError(t, fs.Parse([]string{"-x"}), "flag provided but not defined: -x")
+	assert.EqualError(t, fs.Parse([]string{"-n", "1", "y"}), `invalid value "y" for non-flag 0: parse error`)
+	assert.Equal(t, flag.ErrHelp, fs.Parse([]string{"-h"}))
+	assert.Empty(t, buf.String())
+	fs.Usage()
+	assert.Contains(t, buf.String(), "count")
+}
+
+func TestParseErrors(t *testing.T) {
+	fs := NewFlagSet("test", ContinueOnError)
+	fs.SetQuiet(true)
+	fs.Int("n", 0, "")
+	fs.Bool("v", false, "")
+	fs.NonInt(0, 0, "")
+	var undefined *ErrUndefinedFlag
+	assert.True(t, errors.As(fs.Parse([]string{"-x"}), &undefined))
+	assert.Equal(t, "x", undefined.Name)
+	var badSyntax *ErrBadSyntax
+	assert.True(t, errors.As(fs.Parse([]string{"---x"}), &badSyntax))
+	assert.Equal(t, "---x", badSyntax.Arg)
+	var needsArgument *ErrNeedsArgument
+	assert.True(t, errors.As(fs.Parse([]string{"-n"}), &needsArgument))
+	assert.Equal(t, "n", needsArgument.Name)
+	var invalid *ErrInvalidValue
+	err := fs.Parse([]string{"-n", "x"})
+	assert.True(t, errors.As(err, &invalid))
+	assert.Equal(t, ErrInvalidValue{Name: "n", Value: "x", Err: errParse}, *invalid)
+	assert.True(t, errors.Is(err, errParse))
+	assert.EqualError(t, err, `invalid value "x" for flag -n: parse error`)
+	assert.True(t, errors.As(fs.Parse([]string{"-v=2"}), &invalid))
+	assert.Equal(t, "v", invalid.Name)
+	assert.EqualError(t, fs.Parse([]string{"y"}), `invalid value "y" for non-flag 0: parse error`)
+	assert.True(t, errors.Is(fs.Parse([]string{"-h"}), ErrHelp))
+
+	assert.EqualError(t, fs.Set("x", "1"), "flag provided but not defined: -x")
+	assert.EqualError(t, fs.Set("?1", "1"), "non-flag provided but not defined: ?1")
+	assert.True(t, errors.As(fs.Set("?0", "z"), &invalid))
+	assert.Equal(t, "?0", invalid.Name)
+
+	// the error returned by Value.Set is kept by Parse
+	fs.Var(sentinelValue{}, "s", "")
+	err = fs.Parse([]string{"-n", "1", "-s", "x"})
+	assert.True(t, errors.Is(err, errSentinel))
+	assert.EqualError(t, err, `invalid value "x" for flag -s: sentinel`)
+	assert.True(t, errors.Is(fs.Set("s", "x"), errSentinel))
+	_, ok := fs.Lookup("s").Value.(sentinelValue)
+	assert.True(t, ok)
+}
+
+var errSentinel = errors.New("sentinel")
+
+type sentinelValue struct{}
+
+func (sentinelValue) Set(string) error { return errSentinel }
+func (sentinelValue) String() string   { return "" }
+
+func TestSuggestFlags(t *testing.T) {
+	fs := NewFlagSet("test", ContinueOnError)
+	fs.SetQuiet(true)
+	fs.Bool("verbose", false, "")
+	fs.Bool("version", false, "")
+	fs.String("output", "", "")
+	fs.Int("n", 0, "")
+	assert.Equal(t, []string{"verbose"}, fs.SuggestFlags("verbse"))
+	assert.Equal(t, []string{"verbose", "version"}, fs.SuggestFlags("ver"))
+	assert.Equal(t, []string{"output"}, fs.SuggestFlags("Output"))
+	assert.Empty(t, fs.SuggestFlags("x"))
+	assert.Empty(t, fs.SuggestFlags("?0"))
+	assert.EqualError(t, fs.Parse([]string{"-verbse"}), "flag provided but not defined: -verbse; did you mean -verbose?")
+	assert.EqualError(t, fs.Parse([]string{"-vers"}), "flag provided but not defined: -vers; did you mean -version?")
+	assert.EqualError(t, fs.Set("outptu", "x"), "flag provided but not defined: -outptu; did you mean -output?")
+	assert.EqualError(t, fs.Parse([]string{"-x"}), "flag provided but not defined: -x")
+}
+
+func TestParseAllErrors(t *testing.T) {
+	var buf bytes.Buffer
+	fs := NewFlagSet("test", ContinueOnError)
+	fs.SetOutput(&buf)
+	fs.SetParseMode(ParseAllErrors)
+	n := fs.Int("n", 0, "")
+	v := fs.Bool("v", false, "")
+	fs.Uint("u", 0, "")
+	fs.NonInt(0, 0, "")
+	err := fs.Parse([]string{"-x", "-n", "1", "-u=-1", "-v", "---y", "-m=2", "z"})
+	assert.EqualError(t, err, strings.Join([]string{
+		"flag provided but not defined: -x",
+		`invalid value "-1" for flag -u: parse error`,
+		"bad flag syntax: ---y",
+		"flag provided but not defined: -m",
+		`invalid value "z" for non-flag 0: parse error`,
+	}, "\n"))
+	assert.Equal(t, 1, *n)
+	assert.True(t, *v)
+	assert.Equal(t, 1, strings.Count(buf.String(), "Usage of test:"))
+	var undefined *ErrUndefinedFlag
+	assert.True(t, errors.As(err, &undefined))
+	assert.Equal(t, "x", undefined.Name)
+	assert.NoError(t, fs.Parse([]string{"-n", "2", "--", "3"}))
+	assert.Equal(t, []string{"3"}, fs.Args())
+}
+
+func TestVersionFlag(t *testing.T) {
+	defer func(fn func() (*debug.BuildInfo, bool)) { readBuildInfo = fn }(readBuildInfo)
+	readBuildInfo = func() (*debug.BuildInfo, bool) {
+		return &debug.BuildInfo{
+			GoVersion: "go1.21.0",
+			Main:      debug.Module{Path: "example.com/testapp", Version: "v1.2.3"},
+			Settings: []debug.BuildSetting{
+				{Key: "vcs.revision", Value: "abc123"},
+				{Key: "vcs.modified", Value: "true"},
+			},
+		}, true
+	}
+	var buf bytes.Buffer
+	app := NewApp()
+	app.SetCmdName("testapp")
+	app.SetVersion("1.2.3")
+	app.SetOutput(&buf)
+	app.SetVersionFlag("version")
+	app.AddSubaction("a", "subcommand a", ActionFunc(func(c *Context) {
+		fmt.Fprint(c.Output(), "a")
+	}))
+	assert.Contains(t, app.UsageText(), "  -version\n")
+	platform := runtime.GOOS + "/" + runtime.GOARCH
+
+	assert.True(t, app.Exec(context.TODO(), []string{"--version"}).OK())
+	assert.Equal(t, "testapp v1.2.3\nrevision: abc123 (dirty)\nmodule: example.com/testapp v1.2.3\ngo: go1.21.0 "+platform+"\n", buf.String())
+
+	buf.Reset()
+	assert.True(t, app.Exec(context.TODO(), []string{"--version", "--output", "json"}).OK())
+	var info VersionInfo
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &info))
+	assert.Equal(t, "abc123", info.Revision)
+	assert.True(t, info.Dirty)
+
+	buf.Reset()
+	assert.True(t, app.Exec(context.TODO(), []string{"-o", "json", "-version"}).OK())
+	info = VersionInfo{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &info))
+	assert.Equal(t, "abc123", info.Revision)
+
+	buf.Reset()
+	app.SetVersionTemplate(template.Must(template.New("").Parse("{{.Version}}-{{.Revision}}")))
+	assert.True(t, app.Exec(context.TODO(), []string{"-output=text", "-version"}).OK())
+	assert.Equal(t, "1.2.3-abc123", buf.String())
+
+	buf.Reset()
+	assert.True(t, app.Exec(context.TODO(), []string{"a", "-version"}).OK())
+	assert.Equal(t, "a", buf.String())
+	assert.Equal(t, StatusBadArgs, app.Exec(context.TODO(), []string{"-version", "-output", "xml"}).Code())
+}
+
+func TestNonFlagGeneric(t *testing.T) {
+	fs := NewFlagSet("generic", ContinueOnError)
+	fs.SetQuiet(true)
+	name := RequiredNonFlag[string](fs, 0, "the name")
+	ip := NonFlag(fs, 1, net.IPv4zero, "the address")
+	timeout := NonFlag(fs, 2, time.Second, "the timeout")
+	ports := NonFlags[int](fs, 3, "the ports")
+	assert.Equal(t, "0.0.0.0", fs.Lookup("?1").DefValue)
+	assert.NoError(t, fs.Parse([]string{"a", "127.0.0.1", "2s", "80", "443"}))
+	assert.Equal(t, "a", *name)
+	assert.Equal(t, "127.0.0.1", ip.String())
+	assert.Equal(t, 2*time.Second, *timeout)
+	assert.Equal(t, []int{80, 443}, *ports)
+	assert.Equal(t, "80,443", fs.Lookup("?3").Value.String())
+	assert.Nil(t, fs.NextArgs())
+	assert.EqualError(t, fs.Parse([]string{"a", "x"}), `invalid value "x" for non-flag 1: invalid IP address: x`)
+
+	fs = NewFlagSet("generic", ContinueOnError)
+	fs.SetQuiet(true)
+	RequiredNonFlag[int](fs, 0, "the id")
+	assert.EqualError(t, fs.Parse(nil), "non-flag defined but not provided: 0")
+	assert.Panics(t, func() { NonFlag(fs, 1, struct{}{}, "unsupported") })
+	NonFlags[string](fs, 2, "the rest")
+	assert.Panics(t, func() { NonFlag(fs, 3, "", "after the variadic one") })
+	assert.Panics(t, func() { NonFlags[string](fs, 1, "not the last one") })
+}
+
+func TestIntSlice(t *testing.T) {
+	fs := NewFlagSet("test", ContinueOnError)
+	fs.SetQuiet(true)
+	ids := fs.IntSlice("ids", []int{1, 2}, "the ids")
+	sizes := fs.Int64Slice("sizes", nil, "the sizes")
+	var b bytes.Buffer
+	fs.SetOutput(&b)
+	fs.PrintDefaults()
+	assert.Equal(t, "  -ids ints\n    \tthe ids (default 1,2)\n  -sizes ints\n    \tthe sizes\n", b.String())
+	assert.NoError(t, fs.Parse([]string{"-ids", "3, 4", "-ids=5", "-sizes", "0x10,8589934592"}))
+	assert.Equal(t, []int{3, 4, 5}, *ids)
+	assert.Equal(t, []int64{16, 8589934592}, *sizes)
+	assert.Equal(t, "3,4,5", fs.Lookup("ids").Value.String())
+	assert.EqualError(t, fs.Parse([]string{"-ids", "6,x"}), `invalid value "6,x" for flag -ids: parse error`)
+	assert.Equal(t, []int{3, 4, 5}, *ids)
+
+	var args struct {
+		IDs   []int   `flag:"ids;def=1,2"`
+		Sizes []int64 `flag:"sizes"`
+	}
+	fs = NewFlagSet("test", ContinueOnError)
+	assert.NoError(t, fs.StructVars(&args))
+	assert.Equal(t, []int{1, 2}, args.IDs)
+	assert.NoError(t, fs.Parse([]string{"-ids", "3", "-sizes", "4,5"}))
+	assert.Equal(t, []int{3}, args.IDs)
+	assert.Equal(t, []int64{4, 5}, args.Sizes)
+}
+
+func TestSetReloadedValue(t *testing.T) {
+	fs := NewFlagSet("test", ContinueOnError)
+	var tags []string
+	fs.StringSliceVar(&tags, "tags", []string{"x,y"}, "")
+	ids := fs.IntSlice("ids", nil, "")
+	assert.NoError(t, fs.Parse([]string{"-tags", "a,b", "-tags", "c", "-ids", "1,2"}))
+	assert.Equal(t, []string{"a,b", "c"}, tags)
+	assert.NoError(t, fs.setReloadedValue(fs.Lookup("tags"), "d"))
+	assert.Equal(t, []string{"d"}, tags)
+	resetValue(fs.Lookup("tags"))
+	assert.Equal(t, []string{"x,y"}, tags)
+
+	assert.Error(t, fs.setReloadedValue(fs.Lookup("ids"), "3,x"))
+	assert.Equal(t, []int{1, 2}, *ids)
+	assert.NoError(t, fs.setReloadedValue(fs.Lookup("ids"), "3"))
+	assert.Equal(t, []int{3}, *ids)
 }
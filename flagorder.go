@@ -0,0 +1,130 @@
+package flagx
+
+import (
+	"sort"
+)
+
+// FlagOrder the order of the flags listed in the command usage
+type FlagOrder int8
+
+// Flag orders of the command usage
+const (
+	// FlagOrderGrouped lists the flags grouped by the filters and the action,
+	// each group in lexicographical order with the non-flags appended (the default).
+	FlagOrderGrouped FlagOrder = iota
+	// FlagOrderDefinition lists the flags grouped by the filters and the action,
+	// each group in definition order, such as the declaration order of the struct fields.
+	FlagOrderDefinition
+	// FlagOrderLexical lists all the flags in lexicographical order with the non-flags appended.
+	FlagOrderLexical
+	// FlagOrderNonFlagFirst lists the required non-flags first by index,
+	// then the flags in lexicographical order.
+	FlagOrderNonFlagFirst
+)
+
+// SetFlagOrder sets the order of the flags listed in the usage of the command.
+// NOTE:
+//  the config flag of the app is always listed first;
+//  panic when the command tree is frozen
+func (c *Command) SetFlagOrder(order FlagOrder) {
+	c.checkMutable()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.flagOrder = order
+	c.updateUsageLocked()
+}
+
+// SetFlagLess sets the comparator of the flags listed in the usage of the command,
+// which stably sorts the flags ordered by the FlagOrder, such as putting a group of flags first.
+// NOTE:
+//  nil means no custom comparator;
+//  panic when the command tree is frozen
+func (c *Command) SetFlagLess(less func(a, b *Flag) bool) {
+	c.checkMutable()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.flagLess = less
+	c.updateUsageLocked()
+}
+
+// orderedFlagsLocked returns the flags of the filters and the action in the order of the usage.
+func (c *Command) orderedFlagsLocked() []*Flag {
+	flagSets := make([]*FlagSet, 0, len(c.filters)+1)
+	for _, filter := range c.filters {
+		flagSets = append(flagSets, filter.flagSet)
+	}
+	if c.action != nil {
+		flagSets = append(flagSets, c.action.flagSet)
+	}
+	flags := make([]*Flag, 0, len(flagSets)*4)
+	for _, flagSet := range flagSets {
+		if c.flagOrder == FlagOrderDefinition {
+			flags = append(flags, flagSet.definedFlags()...)
+		} else {
+			flagSet.RangeAll(func(f *Flag) {
+				flags = append(flags, f)
+			})
+		}
+	}
+	switch c.flagOrder {
+	case FlagOrderLexical:
+		sort.SliceStable(flags, func(i, j int) bool {
+			a, b := IsNonFlag(flags[i]), IsNonFlag(flags[j])
+			if a != b {
+				return b
+			}
+			return !a && flags[i].Name < flags[j].Name
+		})
+	case FlagOrderNonFlagFirst:
+		sort.SliceStable(flags, func(i, j int) bool {
+			a, b := IsNonFlag(flags[i]), IsNonFlag(flags[j])
+			if a != b {
+				return a
+			}
+			return !a && flags[i].Name < flags[j].Name
+		})
+	}
+	if c.flagLess != nil {
+		sort.SliceStable(flags, func(i, j int) bool {
+			return c.flagLess(flags[i], flags[j])
+		})
+	}
+	return flags
+}
+
+// recordDefinition records the definition sequence of the flag or non-flag if it is not recorded.
+func (f *FlagSet) recordDefinition(name string) {
+	if _, ok := f.definitions[name]; ok {
+		return
+	}
+	if f.definitions == nil {
+		f.definitions = make(map[string]int, 8)
+	}
+	f.definitions[name] = len(f.definitions)
+}
+
+// recordDefinitions records the flags that are not recorded in lexicographical order,
+// such as the ones defined by the methods of the standard flag set.
+func (f *FlagSet) recordDefinitions() {
+	f.FlagSet.VisitAll(func(fl *Flag) {
+		f.recordDefinition(fl.Name)
+	})
+}
+
+// definedFlags returns the flags and non-flags in definition order,
+// the unrecorded ones follow in lexicographical order.
+func (f *FlagSet) definedFlags() []*Flag {
+	var flags []*Flag
+	f.RangeAll(func(fl *Flag) {
+		flags = append(flags, fl)
+	})
+	sort.SliceStable(flags, func(i, j int) bool {
+		a, ok := f.definitions[flags[i].Name]
+		if !ok {
+			return false
+		}
+		b, ok := f.definitions[flags[j].Name]
+		return !ok || a < b
+	})
+	return flags
+}
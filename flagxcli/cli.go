@@ -0,0 +1,169 @@
+// Package flagxcli imports the urfave/cli app into the flagx app, which eases the migration.
+package flagxcli
+
+import (
+	"flag"
+
+	"github.com/henrylee2cn/flagx"
+	"github.com/urfave/cli/v2"
+)
+
+type (
+	// filter the flags and Before/After funcs of the cli app or the cli command with subcommands
+	filter struct {
+		app *cli.App
+		cmd *cli.Command
+		set *flag.FlagSet
+	}
+	// action the flags and Action func of the cli command without subcommands
+	action struct {
+		app *cli.App
+		cmd *cli.Command
+		set *flag.FlagSet
+	}
+	contextKey struct{}
+)
+
+// Import converts the urfave/cli app into the flagx app.
+// NOTE:
+//  the flags of the app and the commands with subcommands are mapped to the filters,
+//  and the commands without subcommands are mapped to the actions;
+//  the Before and After funcs are called around the next filter or action;
+//  the action of the command with subcommands is ignored, since flagx does not support it;
+//  the aliases and categories are ignored, and the hidden commands are invisible in the parent usage.
+func Import(app *cli.App) *flagx.App {
+	a := flagx.NewApp()
+	if app.Name != "" {
+		a.SetName(app.Name)
+		a.SetCmdName(app.Name)
+	}
+	if app.HelpName != "" {
+		a.SetCmdName(app.HelpName)
+	}
+	description := app.Description
+	if description == "" {
+		description = app.Usage
+	}
+	a.SetDescription(description)
+	a.SetVersion(app.Version)
+	if len(app.Flags) > 0 || app.Before != nil || app.After != nil {
+		a.AddFilter(&filter{app: app})
+	}
+	if len(app.Commands) == 0 {
+		if app.Action != nil {
+			a.SetAction(&action{app: app})
+		}
+		return a
+	}
+	for _, cmd := range app.Commands {
+		importCommand(app, a.Command, cmd)
+	}
+	return a
+}
+
+func importCommand(app *cli.App, parent *flagx.Command, cmd *cli.Command) {
+	if len(cmd.Subcommands) == 0 {
+		parent.AddSubaction(cmd.Name, cmd.Usage, &action{app: app, cmd: cmd})
+		if cmd.Hidden {
+			parent.LookupSubcommand(cmd.Name).SetParentVisible(false)
+		}
+		return
+	}
+	var filters []flagx.Filter
+	if len(cmd.Flags) > 0 || cmd.Before != nil || cmd.After != nil {
+		filters = append(filters, &filter{app: app, cmd: cmd})
+	}
+	sub := parent.AddSubcommand(cmd.Name, cmd.Usage, filters...)
+	if cmd.Hidden {
+		sub.SetParentVisible(false)
+	}
+	for _, subCmd := range cmd.Subcommands {
+		importCommand(app, sub, subCmd)
+	}
+}
+
+// DeepCopy implements flagx.FilterCopier interface.
+func (f *filter) DeepCopy() flagx.Filter {
+	return &filter{app: f.app, cmd: f.cmd}
+}
+
+// DefineFlags implements flagx.FlagDefiner interface.
+func (f *filter) DefineFlags(fs *flagx.FlagSet) error {
+	f.set = fs.FlagSet
+	return applyFlags(fs, f.flags())
+}
+
+// Filter implements flagx.Filter interface.
+func (f *filter) Filter(c *flagx.Context, next flagx.ActionFunc) {
+	ctx := newContext(c, f.app, f.cmd, f.set)
+	before, after := f.app.Before, f.app.After
+	if f.cmd != nil {
+		before, after = f.cmd.Before, f.cmd.After
+	}
+	if before != nil {
+		c.CheckStatus(before(ctx), flagx.StatusExecuteFailed, "")
+	}
+	next(c)
+	if after != nil {
+		c.CheckStatus(after(ctx), flagx.StatusExecuteFailed, "")
+	}
+}
+
+func (f *filter) flags() []cli.Flag {
+	if f.cmd != nil {
+		return f.cmd.Flags
+	}
+	return f.app.Flags
+}
+
+// DeepCopy implements flagx.ActionCopier interface.
+func (a *action) DeepCopy() flagx.Action {
+	return &action{app: a.app, cmd: a.cmd}
+}
+
+// DefineFlags implements flagx.FlagDefiner interface.
+func (a *action) DefineFlags(fs *flagx.FlagSet) error {
+	a.set = fs.FlagSet
+	if a.cmd == nil {
+		return nil
+	}
+	return applyFlags(fs, a.cmd.Flags)
+}
+
+// Execute implements flagx.Action interface.
+func (a *action) Execute(c *flagx.Context) {
+	ctx := newContext(c, a.app, a.cmd, a.set)
+	fn := a.app.Action
+	if a.cmd != nil {
+		fn = a.cmd.Action
+		if a.cmd.Before != nil {
+			c.CheckStatus(a.cmd.Before(ctx), flagx.StatusExecuteFailed, "")
+		}
+	}
+	if fn != nil {
+		c.CheckStatus(fn(ctx), flagx.StatusExecuteFailed, "")
+	}
+	if a.cmd != nil && a.cmd.After != nil {
+		c.CheckStatus(a.cmd.After(ctx), flagx.StatusExecuteFailed, "")
+	}
+}
+
+func applyFlags(fs *flagx.FlagSet, flags []cli.Flag) error {
+	for _, f := range flags {
+		if err := f.Apply(fs.FlagSet); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newContext creates the cli context whose parent is the one of the previous filter,
+// so that the flags of the parent commands can be looked up by it.
+func newContext(c *flagx.Context, app *cli.App, cmd *cli.Command, set *flag.FlagSet) *cli.Context {
+	parent, _ := c.Value(contextKey{}).(*cli.Context)
+	ctx := cli.NewContext(app, set, parent)
+	ctx.Context = c
+	ctx.Command = cmd
+	c.SetValue(contextKey{}, ctx)
+	return ctx
+}
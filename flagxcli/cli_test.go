@@ -0,0 +1,100 @@
+package flagxcli_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/henrylee2cn/flagx"
+	"github.com/henrylee2cn/flagx/flagxcli"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli/v2"
+)
+
+func TestImport(t *testing.T) {
+	var calls []string
+	app := &cli.App{
+		Name:    "testapp",
+		Usage:   "test app",
+		Version: "1.0",
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "g", Value: "flagx", Usage: "global"},
+		},
+		Before: func(*cli.Context) error {
+			calls = append(calls, "app before")
+			return nil
+		},
+		After: func(*cli.Context) error {
+			calls = append(calls, "app after")
+			return nil
+		},
+		Commands: []*cli.Command{
+			{
+				Name:  "sub",
+				Usage: "subcommand",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "v", Usage: "verbose"},
+				},
+				Subcommands: []*cli.Command{
+					{
+						Name:  "echo",
+						Usage: "echo the name",
+						Flags: []cli.Flag{
+							&cli.StringFlag{Name: "name", Usage: "the name"},
+							&cli.IntFlag{Name: "n", Value: 1},
+						},
+						Action: func(c *cli.Context) error {
+							calls = append(calls, fmt.Sprintf("echo g=%s v=%v name=%s n=%d args=%v",
+								c.String("g"), c.Bool("v"), c.String("name"), c.Int("n"), c.Args().Slice()))
+							return nil
+						},
+					},
+				},
+			},
+			{
+				Name:   "hidden",
+				Usage:  "hidden command",
+				Hidden: true,
+				Action: func(*cli.Context) error { return nil },
+			},
+			{
+				Name:  "fail",
+				Usage: "always fail",
+				Action: func(*cli.Context) error {
+					return fmt.Errorf("failed")
+				},
+			},
+		},
+	}
+	a := flagxcli.Import(app)
+	assert.Equal(t, "testapp", a.CmdName())
+	assert.Equal(t, "test app", a.Description())
+	assert.Equal(t, "1.0", a.Version())
+
+	sub := a.LookupSubcommand("sub")
+	assert.False(t, sub.HasAction())
+	assert.Equal(t, "subcommand", sub.Description())
+	assert.Contains(t, sub.FilterFlags(), "v")
+	echo := sub.LookupSubcommand("echo")
+	assert.True(t, echo.HasAction())
+	assert.Equal(t, "echo the name", echo.Description())
+	assert.Equal(t, "the name", echo.Flags()["name"].Usage)
+	assert.Equal(t, "1", echo.Flags()["n"].DefValue)
+	assert.Contains(t, a.FilterFlags(), "g")
+	assert.False(t, a.LookupSubcommand("hidden").ParentVisible())
+
+	var buf bytes.Buffer
+	a.SetOutput(&buf)
+	a.PrintUsage()
+	assert.NotContains(t, buf.String(), "hidden command")
+
+	stat := a.Exec(context.TODO(), strings.Fields("-g=henry sub -v echo -name=lee -n=2 x"))
+	assert.True(t, stat.OK(), stat.String())
+	assert.Equal(t, []string{"app before", "echo g=henry v=true name=lee n=2 args=[x]", "app after"}, calls)
+
+	stat = a.Exec(context.TODO(), []string{"fail"})
+	assert.Equal(t, flagx.StatusExecuteFailed, stat.Code())
+	assert.EqualError(t, stat.Cause(), "failed")
+}
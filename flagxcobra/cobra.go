@@ -0,0 +1,131 @@
+// Package flagxcobra exports the flagx app as a cobra command tree.
+package flagxcobra
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/henrylee2cn/flagx"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// Export exports the app as a cobra command tree, the flags of the filters are mapped to
+// the persistent flags, and the flags of the actions are mapped to the local flags.
+// NOTE:
+//  the cobra commands only parse and describe the flags, the executions are delegated to
+//  app.Exec with the rebuilt arguments, so that the filters and actions work as usual;
+//  the flags are used in the cobra form, such as --name=value;
+//  the failed status is returned as the error of RunE.
+func Export(app *flagx.App) *cobra.Command {
+	root := newCommand(app, app.Command, app.CmdName(), app.Description())
+	root.Version = app.Version()
+	return root
+}
+
+func newCommand(app *flagx.App, c *flagx.Command, use, short string) *cobra.Command {
+	cc := &cobra.Command{
+		Use:           use,
+		Short:         short,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	addFlags(cc.PersistentFlags(), c.FilterFlags())
+	addFlags(cc.Flags(), c.Flags())
+	if c.HasAction() {
+		cc.Args = cobra.ArbitraryArgs
+		cc.RunE = func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			stat := app.Exec(ctx, buildArgs(cmd, c, args))
+			if !stat.OK() {
+				return errors.New(stat.String())
+			}
+			return nil
+		}
+	}
+	for _, sub := range c.Subcommands() {
+		cc.AddCommand(newCommand(app, sub, sub.CmdName(), sub.Description()))
+	}
+	return cc
+}
+
+// buildArgs rebuilds the arguments of app.Exec from the parsed cobra command,
+// the flags of each command follow its name.
+func buildArgs(cmd *cobra.Command, c *flagx.Command, args []string) []string {
+	var chain []*flagx.Command
+	for r := c; r != nil; r = r.Parent() {
+		chain = append([]*flagx.Command{r}, chain...)
+	}
+	var arguments []string
+	for i, r := range chain {
+		if i > 0 {
+			arguments = append(arguments, r.CmdName())
+		}
+		arguments = appendChanged(arguments, cmd.Flags(), r.FilterFlags())
+	}
+	arguments = appendChanged(arguments, cmd.Flags(), c.Flags())
+	return append(arguments, args...)
+}
+
+func appendChanged(arguments []string, fs *pflag.FlagSet, flags map[string]*flagx.Flag) []string {
+	for _, name := range sortedNames(flags) {
+		if f := fs.Lookup(name); f != nil && f.Changed {
+			arguments = append(arguments, fmt.Sprintf("-%s=%s", name, f.Value.String()))
+		}
+	}
+	return arguments
+}
+
+func addFlags(fs *pflag.FlagSet, flags map[string]*flagx.Flag) {
+	for _, name := range sortedNames(flags) {
+		f := flags[name]
+		typ, usage := flagx.UnquoteUsage(f)
+		pf := &pflag.Flag{
+			Name:     f.Name,
+			Usage:    usage,
+			Value:    &value{s: f.DefValue, typ: typ},
+			DefValue: f.DefValue,
+		}
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+			pf.Value.(*value).typ = "bool"
+			pf.NoOptDefVal = "true"
+		}
+		fs.AddFlag(pf)
+	}
+}
+
+func sortedNames(flags map[string]*flagx.Flag) []string {
+	names := make([]string, 0, len(flags))
+	for name := range flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// value the raw string of the flag, which is parsed by flagx at the execution.
+type value struct {
+	s   string
+	typ string
+}
+
+func (v *value) String() string {
+	return v.s
+}
+
+func (v *value) Set(s string) error {
+	v.s = s
+	return nil
+}
+
+func (v *value) Type() string {
+	if v.typ == "" {
+		return "value"
+	}
+	return v.typ
+}
@@ -0,0 +1,75 @@
+package flagxcobra_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/henrylee2cn/flagx"
+	"github.com/henrylee2cn/flagx/flagxcobra"
+	"github.com/stretchr/testify/assert"
+)
+
+type PrefixFilter struct {
+	Prefix string `flag:"prefix;usage=the prefix"`
+}
+
+func (f *PrefixFilter) Filter(c *flagx.Context, next flagx.ActionFunc) {
+	c.SetValue("prefix", f.Prefix)
+	next(c)
+}
+
+type EchoAction struct {
+	Name    string `flag:"name;usage=the name"`
+	Verbose bool   `flag:"v;usage=verbose"`
+	Arg     string `flag:"?0"`
+}
+
+func (a *EchoAction) Execute(c *flagx.Context) {
+	fmt.Fprintf(c.Output(), "%s%s:%v:%s", c.Value("prefix"), a.Name, a.Verbose, a.Arg)
+}
+
+func TestExport(t *testing.T) {
+	var buf bytes.Buffer
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.SetDescription("test app")
+	app.SetVersion("1.0")
+	app.SetOutput(&buf)
+	sub := app.AddSubcommand("sub", "subcommand", new(PrefixFilter))
+	sub.AddSubaction("echo", "echo the name", new(EchoAction))
+	sub.AddSubaction("fail", "always fail", flagx.ActionFunc(func(c *flagx.Context) {
+		c.ThrowStatus(flagx.StatusExecuteFailed, "failed")
+	}))
+
+	root := flagxcobra.Export(app)
+	assert.Equal(t, "testapp", root.Use)
+	assert.Equal(t, "test app", root.Short)
+	assert.Equal(t, "1.0", root.Version)
+
+	subCmd, _, err := root.Find([]string{"sub"})
+	assert.NoError(t, err)
+	assert.Equal(t, "subcommand", subCmd.Short)
+	assert.NotNil(t, subCmd.PersistentFlags().Lookup("prefix"))
+	assert.Nil(t, subCmd.RunE)
+
+	echoCmd, _, err := root.Find([]string{"sub", "echo"})
+	assert.NoError(t, err)
+	assert.Equal(t, "echo the name", echoCmd.Short)
+	assert.NotNil(t, echoCmd.InheritedFlags().Lookup("prefix"))
+	assert.Equal(t, "the name", echoCmd.LocalFlags().Lookup("name").Usage)
+	assert.Equal(t, "true", echoCmd.LocalFlags().Lookup("v").NoOptDefVal)
+
+	root.SetArgs([]string{"sub", "--prefix=hi,", "echo", "--name", "henry", "--v", "x"})
+	assert.NoError(t, root.Execute())
+	assert.Equal(t, "hi,henry:true:x", buf.String())
+
+	buf.Reset()
+	root = flagxcobra.Export(app)
+	root.SetArgs([]string{"sub", "echo", "--prefix=hey,", "y"})
+	assert.NoError(t, root.Execute())
+	assert.Equal(t, "hey,:false:y", buf.String())
+
+	root.SetArgs([]string{"sub", "fail"})
+	assert.EqualError(t, root.Execute(), `{"code":8,"msg":"failed","cause":""}`)
+}
@@ -0,0 +1,139 @@
+// Package flagxhttp serves the flagx app as a minimal admin HTTP API.
+package flagxhttp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/henrylee2cn/flagx"
+)
+
+// ArgsKey the query or form key of the non-flag arguments, such as ?_=a&_=b
+const ArgsKey = "_"
+
+type (
+	// Result the JSON response of the execution
+	Result struct {
+		Code   int32  `json:"code"`
+		Msg    string `json:"msg,omitempty"`
+		Cause  string `json:"cause,omitempty"`
+		Output string `json:"output,omitempty"`
+	}
+	outputKey struct{}
+)
+
+// Handler returns the HTTP handler, which maps the URL path segments to the command path,
+// and the query and form parameters to the flags, such as /sub/action?name=x to `sub action -name=x`,
+// executes the app with the request context, and renders the status as JSON.
+// NOTE:
+//  the non-flags are passed by the ArgsKey parameters in order;
+//  the actions can write the output of the response by Output;
+//  only GET and POST are allowed.
+func Handler(app *flagx.App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodPost {
+			w.Header().Set("Allow", "GET, POST")
+			writeResult(w, http.StatusMethodNotAllowed, &Result{Code: flagx.StatusBadArgs, Msg: "method not allowed"})
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			writeResult(w, http.StatusBadRequest, &Result{Code: flagx.StatusBadArgs, Msg: err.Error()})
+			return
+		}
+		var output bytes.Buffer
+		ctx := context.WithValue(r.Context(), outputKey{}, &output)
+		stat := app.Exec(ctx, buildArgs(app, r))
+		result := &Result{Code: stat.Code(), Msg: stat.Msg(), Output: output.String()}
+		if cause := stat.Cause(); cause != nil && cause.Error() != result.Msg {
+			result.Cause = cause.Error()
+		}
+		writeResult(w, httpStatus(stat.Code()), result)
+	})
+}
+
+// Output returns the writer of the response output if the context is from the Handler,
+// otherwise returns os.Stdout.
+func Output(ctx context.Context) io.Writer {
+	if w, ok := ctx.Value(outputKey{}).(io.Writer); ok {
+		return w
+	}
+	return os.Stdout
+}
+
+// buildArgs builds the arguments from the request, the flags of each command follow its name.
+func buildArgs(app *flagx.App, r *http.Request) []string {
+	params := make(map[string][]string, len(r.Form))
+	for k, v := range r.Form {
+		if k != ArgsKey {
+			params[k] = v
+		}
+	}
+	var args []string
+	cmd := app.Command
+	args = appendFlags(args, params, cmd.FilterFlags())
+	for _, seg := range strings.Split(r.URL.Path, "/") {
+		if seg == "" {
+			continue
+		}
+		args = append(args, seg)
+		if cmd != nil {
+			if cmd = cmd.LookupSubcommand(seg); cmd != nil {
+				args = appendFlags(args, params, cmd.FilterFlags())
+			}
+		}
+	}
+	if cmd != nil {
+		args = appendFlags(args, params, cmd.Flags())
+	}
+	rest := make(map[string]*flagx.Flag, len(params))
+	for name := range params {
+		rest[name] = nil
+	}
+	args = appendFlags(args, params, rest)
+	return append(args, r.Form[ArgsKey]...)
+}
+
+// appendFlags appends the parameters of the flags and removes them.
+func appendFlags(args []string, params map[string][]string, flags map[string]*flagx.Flag) []string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		if _, ok := flags[name]; ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, v := range params[name] {
+			args = append(args, "-"+name+"="+v)
+		}
+		delete(params, name)
+	}
+	return args
+}
+
+func httpStatus(code int32) int {
+	switch code {
+	case 0:
+		return http.StatusOK
+	case flagx.StatusNotFound:
+		return http.StatusNotFound
+	case flagx.StatusBadArgs, flagx.StatusParseFailed, flagx.StatusValidateFailed:
+		return http.StatusBadRequest
+	case flagx.StatusMismatchScope, flagx.StatusForbidden:
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeResult(w http.ResponseWriter, status int, result *Result) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(result)
+}
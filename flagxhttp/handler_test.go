@@ -0,0 +1,66 @@
+package flagxhttp_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/henrylee2cn/flagx"
+	"github.com/henrylee2cn/flagx/flagxhttp"
+	"github.com/stretchr/testify/assert"
+)
+
+type EchoFilter struct {
+	Prefix string `flag:"prefix"`
+}
+
+func (f *EchoFilter) Filter(c *flagx.Context, next flagx.ActionFunc) {
+	c.SetValue("prefix", f.Prefix)
+	next(c)
+}
+
+type EchoAction struct {
+	Name string `flag:"name"`
+	Arg  string `flag:"?0"`
+}
+
+func (a *EchoAction) Execute(c *flagx.Context) {
+	fmt.Fprintf(flagxhttp.Output(c), "%s%s:%s", c.Value("prefix"), a.Name, a.Arg)
+}
+
+func TestHandler(t *testing.T) {
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	sub := app.AddSubcommand("sub", "subcommand", new(EchoFilter))
+	sub.AddSubaction("echo", "echo the name", new(EchoAction))
+	sub.AddSubaction("fail", "always fail", flagx.ActionFunc(func(c *flagx.Context) {
+		c.ThrowStatus(flagx.StatusExecuteFailed, "failed")
+	}))
+	h := flagxhttp.Handler(app)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/sub/echo?name=henry&prefix=hi,&_=x", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"code":0,"output":"hi,henry:x"}`+"\n", w.Body.String())
+
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/sub/echo", strings.NewReader("name=lee"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	h.ServeHTTP(w, r)
+	assert.Equal(t, `{"code":0,"output":"lee:"}`+"\n", w.Body.String())
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/sub/fail", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, `{"code":8,"msg":"failed"}`+"\n", w.Body.String())
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/nonexistent", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/sub/echo", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
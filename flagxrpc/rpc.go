@@ -0,0 +1,148 @@
+// Package flagxrpc executes the flagx app remotely over JSON-RPC.
+package flagxrpc
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+
+	"github.com/henrylee2cn/flagx"
+)
+
+// ServiceName the name of the JSON-RPC service, whose method is "Flagx.Exec"
+const ServiceName = "Flagx"
+
+type (
+	// Request the wire schema of the remote execution
+	Request struct {
+		CmdPath []string          `json:"cmd_path"`        // The command path without the app name
+		Args    []string          `json:"args"`            // The arguments following the command path
+		Token   string            `json:"token,omitempty"` // The credential of the caller, verified by the ScopeFunc
+		Env     map[string]string `json:"env,omitempty"`   // The environment variables of the execution, see flagx.WithEnv
+	}
+	// Response the wire schema of the execution result
+	Response struct {
+		Code   int32  `json:"code"`
+		Msg    string `json:"msg,omitempty"`
+		Cause  string `json:"cause,omitempty"`
+		Output string `json:"output,omitempty"`
+	}
+	// ScopeFunc authenticates the caller of the request and returns its executor scope,
+	// which is checked by the scope matcher of the app, see App.SetScopeMatcher.
+	ScopeFunc func(ctx context.Context, req *Request) (flagx.Scope, error)
+	// Service the JSON-RPC service executing the app
+	Service struct {
+		app   *flagx.App
+		scope ScopeFunc
+	}
+	contextKey int8
+)
+
+const outputKey contextKey = 0
+
+// NewService creates the service executing the app, the executor scope is derived from the request by @scope.
+// NOTE:
+//  the scope is never taken from the caller, so @scope must authenticate it, such as verifying the token;
+//  the request failing @scope is rejected with code StatusForbidden;
+//  panic if @scope is nil.
+func NewService(app *flagx.App, scope ScopeFunc) *Service {
+	if scope == nil {
+		panic("flagxrpc: nil ScopeFunc")
+	}
+	return &Service{app: app, scope: scope}
+}
+
+// Exec executes the app by the request, the failed execution is reported by the response code.
+// NOTE:
+//  the execution only sees the environment of the request, see flagx.WithEnv.
+func (s *Service) Exec(req *Request, resp *Response) error {
+	var output bytes.Buffer
+	ctx := context.WithValue(context.Background(), outputKey, &output)
+	ctx = flagx.WithEnv(ctx, req.Env)
+	scope, err := s.scope(ctx, req)
+	if err != nil {
+		*resp = Response{Code: flagx.StatusForbidden, Msg: err.Error()}
+		return nil
+	}
+	args := append(append(make([]string, 0, len(req.CmdPath)+len(req.Args)), req.CmdPath...), req.Args...)
+	stat := s.app.Exec(ctx, args, scope)
+	*resp = Response{Code: stat.Code(), Msg: stat.Msg(), Output: output.String()}
+	if cause := stat.Cause(); cause != nil && cause.Error() != resp.Msg {
+		resp.Cause = cause.Error()
+	}
+	return nil
+}
+
+// NewServer creates the RPC server registered with the service.
+func NewServer(service *Service) *rpc.Server {
+	server := rpc.NewServer()
+	if err := server.RegisterName(ServiceName, service); err != nil {
+		panic(err)
+	}
+	return server
+}
+
+// Serve accepts the connections on the listener and serves the service over JSON-RPC,
+// it blocks until the listener fails.
+func Serve(l net.Listener, service *Service) error {
+	server := NewServer(service)
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go server.ServeCodec(jsonrpc.NewServerCodec(conn))
+	}
+}
+
+// Output returns the writer of the response output if the context is from the service,
+// otherwise returns os.Stdout.
+func Output(ctx context.Context) io.Writer {
+	if w, ok := ctx.Value(outputKey).(io.Writer); ok {
+		return w
+	}
+	return os.Stdout
+}
+
+// Client the JSON-RPC client of the service
+type Client struct {
+	client *rpc.Client
+}
+
+// Dial connects to the service at the address.
+func Dial(network, address string) (*Client, error) {
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(conn), nil
+}
+
+// NewClient creates the client on the connection.
+func NewClient(conn io.ReadWriteCloser) *Client {
+	return &Client{client: jsonrpc.NewClient(conn)}
+}
+
+// Exec executes the command remotely, the error is reported only if the call fails,
+// the failed execution is reported by the response code.
+func (c *Client) Exec(ctx context.Context, req *Request) (*Response, error) {
+	call := c.client.Go(ServiceName+".Exec", req, new(Response), make(chan *rpc.Call, 1))
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-call.Done:
+		if call.Error != nil {
+			return nil, call.Error
+		}
+		return call.Reply.(*Response), nil
+	}
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	return c.client.Close()
+}
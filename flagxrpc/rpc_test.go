@@ -0,0 +1,70 @@
+package flagxrpc_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/rpc/jsonrpc"
+	"testing"
+
+	"github.com/henrylee2cn/flagx"
+	"github.com/henrylee2cn/flagx/flagxrpc"
+	"github.com/stretchr/testify/assert"
+)
+
+type EchoAction struct {
+	Name string `flag:"name"`
+}
+
+func (a *EchoAction) Execute(c *flagx.Context) {
+	fmt.Fprintf(flagxrpc.Output(c), "%s:%s", a.Name, c.Env()["GREETING"])
+}
+
+func TestService(t *testing.T) {
+	app := flagx.NewApp()
+	app.SetCmdName("testapp")
+	app.SetScopeMatcher(func(cmdScope, execScope flagx.Scope) error {
+		if cmdScope > execScope {
+			return errors.New("permission denied")
+		}
+		return nil
+	})
+	app.AddSubaction("echo", "echo the name", new(EchoAction))
+	app.AddSubaction("admin", "admin only", new(EchoAction), 2)
+	tokens := map[string]flagx.Scope{"user": 1, "admin": 2}
+	service := flagxrpc.NewService(app, func(ctx context.Context, req *flagxrpc.Request) (flagx.Scope, error) {
+		scope, ok := tokens[req.Token]
+		if !ok {
+			return 0, errors.New("invalid token")
+		}
+		return scope, nil
+	})
+	assert.Panics(t, func() { flagxrpc.NewService(app, nil) })
+	serverConn, clientConn := net.Pipe()
+	go flagxrpc.NewServer(service).ServeCodec(jsonrpc.NewServerCodec(serverConn))
+	client := flagxrpc.NewClient(clientConn)
+	defer client.Close()
+
+	resp, err := client.Exec(context.Background(), &flagxrpc.Request{
+		CmdPath: []string{"echo"},
+		Args:    []string{"-name", "henry"},
+		Token:   "user",
+		Env:     map[string]string{"GREETING": "hi"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, &flagxrpc.Response{Output: "henry:hi"}, resp)
+
+	resp, err = client.Exec(context.Background(), &flagxrpc.Request{CmdPath: []string{"admin"}, Token: "user"})
+	assert.NoError(t, err)
+	assert.Equal(t, flagx.StatusMismatchScope, resp.Code)
+	assert.Equal(t, "permission denied", resp.Msg)
+
+	resp, err = client.Exec(context.Background(), &flagxrpc.Request{CmdPath: []string{"admin"}, Token: "admin"})
+	assert.NoError(t, err)
+	assert.Equal(t, &flagxrpc.Response{Output: ":"}, resp)
+
+	resp, err = client.Exec(context.Background(), &flagxrpc.Request{CmdPath: []string{"echo"}, Token: "forged"})
+	assert.NoError(t, err)
+	assert.Equal(t, &flagxrpc.Response{Code: flagx.StatusForbidden, Msg: "invalid token"}, resp)
+}
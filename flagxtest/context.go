@@ -0,0 +1,87 @@
+package flagxtest
+
+import (
+	"context"
+	"io"
+
+	"github.com/henrylee2cn/flagx"
+)
+
+// ContextOption the option of the Context created by NewContext
+type ContextOption func(*flagx.ContextSpec)
+
+// NewContext returns a detached *flagx.Context configured by the options,
+// so that the actions and filters can be unit-tested without building an app.
+// NOTE:
+//  the command path defaults to "flagxtest".
+// Example:
+//  var out bytes.Buffer
+//  ctx := flagxtest.NewContext(flagxtest.WithCmdPath("app", "sub"), flagxtest.WithOutput(&out))
+//  (&MyAction{Name: "x"}).Execute(ctx)
+func NewContext(opts ...ContextOption) *flagx.Context {
+	spec := flagx.ContextSpec{CmdPath: []string{"flagxtest"}}
+	for _, opt := range opts {
+		opt(&spec)
+	}
+	return flagx.NewContext(spec)
+}
+
+// WithContext sets the embedded context.
+func WithContext(ctx context.Context) ContextOption {
+	return func(spec *flagx.ContextSpec) {
+		spec.Context = ctx
+	}
+}
+
+// WithCmdPath sets the command path including the app command name.
+func WithCmdPath(cmdPath ...string) ContextOption {
+	return func(spec *flagx.ContextSpec) {
+		spec.CmdPath = cmdPath
+	}
+}
+
+// WithArgs sets the command arguments.
+func WithArgs(args ...string) ContextOption {
+	return func(spec *flagx.ContextSpec) {
+		spec.Args = args
+	}
+}
+
+// WithScope sets the scope of the command and the executor scope.
+func WithScope(cmdScope, execScope flagx.Scope) ContextOption {
+	return func(spec *flagx.ContextSpec) {
+		spec.CmdScope = cmdScope
+		spec.ExecScope = execScope
+	}
+}
+
+// WithMeta sets the command meta.
+func WithMeta(key, val interface{}) ContextOption {
+	return func(spec *flagx.ContextSpec) {
+		if spec.Meta == nil {
+			spec.Meta = make(map[interface{}]interface{}, 4)
+		}
+		spec.Meta[key] = val
+	}
+}
+
+// WithOutput sets the output of Context.Output.
+func WithOutput(w io.Writer) ContextOption {
+	return func(spec *flagx.ContextSpec) {
+		spec.Output = w
+	}
+}
+
+// WithEnv sets the environment of Context.Env and Context.LookupEnv.
+func WithEnv(env map[string]string) ContextOption {
+	return func(spec *flagx.ContextSpec) {
+		spec.Env = env
+	}
+}
+
+// WithFormat sets the output format of Context.Print.
+func WithFormat(format flagx.OutputFormat) ContextOption {
+	return func(spec *flagx.ContextSpec) {
+		spec.Format = format
+	}
+}
@@ -0,0 +1,106 @@
+// Package flagxtest provides the helpers of testing the flagx app.
+package flagxtest
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/henrylee2cn/flagx"
+)
+
+// CmdNamePlaceholder the placeholder of the app command name in the golden files
+const CmdNamePlaceholder = "<cmd>"
+
+// UpdateEnv the environment variable that rewrites the golden files when it is true,
+// such as `FLAGXTEST_UPDATE=1 go test`.
+const UpdateEnv = "FLAGXTEST_UPDATE"
+
+// updateGolden reports whether to rewrite the golden files,
+// by the -update flag if the test binary defines it, or else by UpdateEnv.
+func updateGolden() bool {
+	if f := flag.Lookup("update"); f != nil {
+		if g, ok := f.Value.(flag.Getter); ok {
+			if b, ok := g.Get().(bool); ok {
+				return b
+			}
+		}
+		b, _ := strconv.ParseBool(f.Value.String())
+		return b
+	}
+	b, _ := strconv.ParseBool(os.Getenv(UpdateEnv))
+	return b
+}
+
+// AssertUsage asserts that the usage text of the app matches the golden file,
+// which guards the help output against accidental changes.
+// NOTE:
+//  the usage is normalized, see NormalizeUsage;
+//  run `FLAGXTEST_UPDATE=1 go test` to create or rewrite the golden file,
+//  or `go test -update` if the test package defines the bool flag "update";
+//  it does not define the flag itself, so it never conflicts with the flags of the test binary.
+// Example:
+//  flagxtest.AssertUsage(t, app, "testdata/usage.golden")
+func AssertUsage(t testing.TB, app *flagx.App, golden string) {
+	t.Helper()
+	got := NormalizeUsage(app.UsageText(), app.CmdName())
+	if updateGolden() {
+		if err := os.MkdirAll(filepath.Dir(golden), 0755); err != nil {
+			t.Fatalf("flagxtest: %v", err)
+		}
+		if err := os.WriteFile(golden, []byte(got), 0644); err != nil {
+			t.Fatalf("flagxtest: %v", err)
+		}
+		return
+	}
+	b, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("flagxtest: %v, run `FLAGXTEST_UPDATE=1 go test` to create it", err)
+	}
+	want := NormalizeUsage(string(b), "")
+	if got != want {
+		t.Errorf("flagxtest: usage does not match %s, run `FLAGXTEST_UPDATE=1 go test` if it is expected\n--- want:\n%s\n--- got:\n%s", golden, want, got)
+	}
+}
+
+// NormalizeUsage returns the usage text with the line endings, the trailing spaces of
+// each line and the trailing blank lines removed, and the command name replaced with
+// CmdNamePlaceholder, so that it does not depend on the platform and the test binary name.
+// NOTE:
+//  the command name is not replaced if @cmdName is empty.
+func NormalizeUsage(text, cmdName string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	text = strings.TrimRight(strings.Join(lines, "\n"), "\n") + "\n"
+	if cmdName == "" {
+		return text
+	}
+	var b strings.Builder
+	for start := 0; ; {
+		i := strings.Index(text[start:], cmdName)
+		if i < 0 {
+			b.WriteString(text[start:])
+			return b.String()
+		}
+		i += start
+		j := i + len(cmdName)
+		b.WriteString(text[start:i])
+		if (i == 0 || !isNameByte(text[i-1])) && (j == len(text) || !isNameByte(text[j])) {
+			b.WriteString(CmdNamePlaceholder)
+		} else {
+			b.WriteString(cmdName)
+		}
+		start = j
+	}
+}
+
+// isNameByte reports whether the byte can be a part of the command name.
+func isNameByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-' || c == '.'
+}
@@ -0,0 +1,69 @@
+package flagxtest_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/henrylee2cn/flagx"
+	"github.com/henrylee2cn/flagx/flagxtest"
+	"github.com/stretchr/testify/assert"
+)
+
+type EchoAction struct {
+	Name string `flag:"name;usage=the name to echo"`
+	Arg  string `flag:"?0;usage=the text to echo"`
+}
+
+func (a *EchoAction) Execute(c *flagx.Context) {}
+
+func TestAssertUsage(t *testing.T) {
+	app := flagx.NewApp()
+	app.SetCmdName("echoapp")
+	app.SetDescription("echoapp echoes the text")
+	app.AddSubaction("echo", "echo the text", new(EchoAction))
+	flagxtest.AssertUsage(t, app, "testdata/usage.golden")
+
+	golden := filepath.Join(t.TempDir(), "usage.golden")
+	t.Setenv(flagxtest.UpdateEnv, "1")
+	flagxtest.AssertUsage(t, app, golden)
+	b, err := os.ReadFile(golden)
+	assert.NoError(t, err)
+	assert.Equal(t, flagxtest.NormalizeUsage(app.UsageText(), app.CmdName()), string(b))
+}
+
+func TestNormalizeUsage(t *testing.T) {
+	assert.Equal(t, "<cmd> - v1\n  $<cmd> a <cmd>\n  $app.test app-x\n", flagxtest.NormalizeUsage("app - v1  \r\n  $app a app\n  $app.test app-x\n\n", "app"))
+	assert.Equal(t, "a\n", flagxtest.NormalizeUsage("a", ""))
+	assert.Equal(t, "appapp <cmd>\n", flagxtest.NormalizeUsage("appapp app", "app"))
+}
+
+func (a *EchoAction) echo(c *flagx.Context) {
+	fmt.Fprintf(c.Output(), "%s %s %s %v", c.CmdPathString(), a.Name, c.Args(), c.GetCmdMeta("k"))
+}
+
+func TestNewContext(t *testing.T) {
+	var out bytes.Buffer
+	ctx := flagxtest.NewContext(
+		flagxtest.WithCmdPath("echoapp", "echo"),
+		flagxtest.WithArgs("a", "b"),
+		flagxtest.WithScope(1, 2),
+		flagxtest.WithMeta("k", "v"),
+		flagxtest.WithOutput(&out),
+		flagxtest.WithEnv(map[string]string{"HOME": "/home/x"}),
+	)
+	(&EchoAction{Name: "x"}).echo(ctx)
+	assert.Equal(t, "echoapp echo x [a b] v", out.String())
+	assert.Equal(t, flagx.Scope(1), ctx.CmdScope())
+	assert.Equal(t, flagx.Scope(2), ctx.ExecScope())
+	assert.Equal(t, map[string]string{"HOME": "/home/x"}, ctx.Env())
+	assert.Equal(t, []string{"flagxtest"}, flagxtest.NewContext().CmdPath())
+	out.Reset()
+	flagxtest.NewContext(flagxtest.WithOutput(&out), flagxtest.WithFormat(flagx.OutputJSON)).Print([]int{1})
+	assert.Equal(t, "[\n  1\n]\n", out.String())
+	assert.Panics(t, func() {
+		ctx.ThrowStatus(flagx.StatusBadArgs, "bad")
+	})
+}
@@ -0,0 +1,47 @@
+package flagx
+
+// ForwardArgs rebuilds the selected flags of the execution and the passthrough arguments
+// into an argv suitable for exec.Command, such as ["-v", "-tag=1.0", "ps", "-a"],
+// for the wrapper commands delegating to the underlying tools.
+// NOTE:
+//  only the flags whose values are not the defaults are candidates, and nil @include selects all of them;
+//  the flags are in the order of the filters and the action, each sorted by name;
+//  the values of the secret flags are masked;
+//  the passthrough arguments are the ones after the terminator "--", see FlagSet.PassthroughArgs.
+// Example:
+//  args := c.ForwardArgs(func(f *flagx.Flag) bool { return f.Name != "dry-run" })
+//  cmd := exec.CommandContext(c, "docker", args...)
+func (c *Context) ForwardArgs(include func(*Flag) bool) []string {
+	args := make([]string, 0, 8)
+	if c.snap == nil {
+		return args
+	}
+	var passthrough []string
+	for _, b := range c.snap.bindings {
+		args = b.flagSet.appendForwardArgs(args, include)
+		if p := b.flagSet.PassthroughArgs(); p != nil {
+			passthrough = p
+		}
+	}
+	return append(args, passthrough...)
+}
+
+// appendForwardArgs appends the selected flags whose values are not the defaults.
+func (f *FlagSet) appendForwardArgs(args []string, include func(*Flag) bool) []string {
+	snapshot := f.Snapshot()
+	f.VisitAll(func(fl *Flag) {
+		r := snapshot[fl.Name]
+		if r.Source == SourceDefault || include != nil && !include(fl) {
+			return
+		}
+		value := r.Value
+		if f.IsSecret(fl.Name) {
+			value = SecretMask
+		} else if isBoolFlag(fl) && value == "true" {
+			args = append(args, "-"+fl.Name)
+			return
+		}
+		args = append(args, "-"+fl.Name+"="+value)
+	})
+	return args
+}
@@ -0,0 +1,46 @@
+package flagx
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SetFS sets the file system from which the config files are read, such as an embed.FS.
+// NOTE:
+//  the file names are converted to the slash-separated paths without the leading '/',
+//  such as "/etc/app.json" to "etc/app.json";
+//  set nil to read from the OS file system, which is the default.
+func (f *FlagSet) SetFS(fsys fs.FS) {
+	f.fsys = fsys
+}
+
+// SetFS sets the file system from which the config files are read and discovered, such as an embed.FS,
+// see FlagSet.SetFS.
+func (a *App) SetFS(fsys fs.FS) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.fsys = fsys
+}
+
+// readFile reads the file from the file system, or from the OS one if it is nil.
+func readFile(fsys fs.FS, name string) ([]byte, error) {
+	if fsys == nil {
+		return os.ReadFile(name)
+	}
+	return fs.ReadFile(fsys, fsPath(name))
+}
+
+// statFile returns the file info from the file system, or from the OS one if it is nil.
+func statFile(fsys fs.FS, name string) (fs.FileInfo, error) {
+	if fsys == nil {
+		return os.Stat(name)
+	}
+	return fs.Stat(fsys, fsPath(name))
+}
+
+// fsPath converts the OS file name to the path of fs.FS.
+func fsPath(name string) string {
+	return strings.TrimLeft(filepath.ToSlash(filepath.Clean(name)), "/")
+}
@@ -0,0 +1,188 @@
+package flagx
+
+import (
+	"encoding"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// NonFlag defines a non-flag of any supported type with specified index, default value, and usage string.
+// The return value is the address of a T variable that stores the value of the non-flag.
+// NOTE:
+//  the supported types are bool, int, int64, uint, uint64, string, float64, time.Duration
+//  and the types whose pointers implement encoding.TextUnmarshaler;
+//  panic if the type is not supported.
+// Example:
+//  src := flagx.NonFlag(fs, 0, "", "the source")
+//  ip := flagx.NonFlag(fs, 1, net.IPv4zero, "the address")
+func NonFlag[T any](fs *FlagSet, index int, value T, usage string) *T {
+	p := new(T)
+	NonFlagVar(fs, p, index, value, usage)
+	return p
+}
+
+// NonFlagVar defines a non-flag of any supported type with specified index, default value, and usage string,
+// see NonFlag.
+// The argument p points to a T variable in which to store the value of the non-flag.
+func NonFlagVar[T any](fs *FlagSet, p *T, index int, value T, usage string) {
+	fs.NonVar(newGenericValue(p, value), index, usage)
+}
+
+// RequiredNonFlag defines a non-flag of any supported type with specified index and usage string,
+// which fails the parsing if it is not provided, see NonFlag.
+// The return value is the address of a T variable that stores the value of the non-flag.
+func RequiredNonFlag[T any](fs *FlagSet, index int, usage string) *T {
+	var zero T
+	p := NonFlag(fs, index, zero, usage)
+	fs.MarkNonFlagRequired(index)
+	return p
+}
+
+// NonFlags defines the variadic non-flag of any supported type with specified index and usage string,
+// which takes the non-flags from the index to the end, see NonFlag.
+// The return value is the address of a []T variable that stores the values of the non-flags.
+// NOTE:
+//  it must be the last non-flag, and no subcommand follows it;
+//  panic if a non-flag is defined after it.
+func NonFlags[T any](fs *FlagSet, index int, usage string) *[]T {
+	if index < len(fs.nonFormal) {
+		panic(fmt.Sprintf("variadic non-flag %s is not the last one", getNonFlagName(index)))
+	}
+	p := new([]T)
+	fs.NonVar(&genericSliceValue[T]{p: p}, index, usage)
+	fs.nonVariadic, fs.nonVariadicIndex = fs.nonFormal[index], index
+	return p
+}
+
+// MarkNonFlagRequired marks the non-flag as required, which fails the parsing if it is not provided.
+// NOTE:
+//  panic if the non-flag is not defined.
+func (f *FlagSet) MarkNonFlagRequired(index int) {
+	if lookupNonFlag(f.nonFormal, index) == nil {
+		panic(fmt.Sprintf("no such non-flag %s", getNonFlagName(index)))
+	}
+	if f.requiredNonFlags == nil {
+		f.requiredNonFlags = make(map[int]bool, 4)
+	}
+	f.requiredNonFlags[index] = true
+}
+
+// checkRequiredNonFlags returns the error of the first required non-flag not provided.
+func (f *FlagSet) checkRequiredNonFlags() error {
+	for index := range f.nonFormal {
+		if f.requiredNonFlags[index] && lookupNonFlag(f.nonActual, index) == nil {
+			return f.failf("non-flag defined but not provided: %d", index)
+		}
+	}
+	return nil
+}
+
+// lookupVariadicNonFlag returns the variadic non-flag taking the index, or nil.
+func (f *FlagSet) lookupVariadicNonFlag(index int) *Flag {
+	if f.nonVariadic != nil && index > f.nonVariadicIndex {
+		return f.nonVariadic
+	}
+	return nil
+}
+
+// newGenericValue returns the Value of the supported type.
+func newGenericValue[T any](p *T, value T) Value {
+	switch v := any(value).(type) {
+	case bool:
+		return newBoolValue(v, any(p).(*bool))
+	case int:
+		return newIntValue(v, any(p).(*int))
+	case int64:
+		return newInt64Value(v, any(p).(*int64))
+	case uint:
+		return newUintValue(v, any(p).(*uint))
+	case uint64:
+		return newUint64Value(v, any(p).(*uint64))
+	case string:
+		return newStringValue(v, any(p).(*string))
+	case float64:
+		return newFloat64Value(v, any(p).(*float64))
+	case time.Duration:
+		return newDurationValue(v, any(p).(*time.Duration))
+	}
+	if u, ok := any(p).(encoding.TextUnmarshaler); ok {
+		*p = value
+		return &textValue{p: u, newValue: func() Value { return newGenericValue(new(T), value) }}
+	}
+	panic(fmt.Sprintf("unsupported non-flag type: %T", value))
+}
+
+// textValue the Value of encoding.TextUnmarshaler
+type textValue struct {
+	p        encoding.TextUnmarshaler
+	newValue func() Value // Creates the value holding the default
+}
+
+func (t *textValue) Set(s string) error {
+	return t.p.UnmarshalText([]byte(s))
+}
+
+func (t *textValue) Get() interface{} { return t.p }
+
+// clone returns a new value holding the default value.
+func (t *textValue) clone() Value { return t.newValue() }
+
+func (t *textValue) String() string {
+	if t.p == nil {
+		return ""
+	}
+	if m, ok := t.p.(encoding.TextMarshaler); ok {
+		if b, err := m.MarshalText(); err == nil {
+			return string(b)
+		}
+	}
+	return fmt.Sprint(t.p)
+}
+
+// genericSliceValue the Value of the variadic non-flag
+type genericSliceValue[T any] struct {
+	p       *[]T
+	changed bool
+}
+
+// Set appends the value, the first one replaces the default values.
+func (s *genericSliceValue[T]) Set(val string) error {
+	var elem T
+	if err := newGenericValue(&elem, elem).Set(val); err != nil {
+		return err
+	}
+	if !s.changed {
+		*s.p = nil
+		s.changed = true
+	}
+	*s.p = append(*s.p, elem)
+	return nil
+}
+
+func (s *genericSliceValue[T]) Get() interface{} { return *s.p }
+
+func (s *genericSliceValue[T]) String() string {
+	if s.p == nil {
+		return ""
+	}
+	elems := make([]string, len(*s.p))
+	for i := range *s.p {
+		elems[i] = newGenericValue(&(*s.p)[i], (*s.p)[i]).String()
+	}
+	return strings.Join(elems, ",")
+}
+
+func (s *genericSliceValue[T]) IsSliceFlag() bool { return true }
+
+// clone returns a new value holding the default values.
+func (s *genericSliceValue[T]) clone() Value {
+	p := append([]T(nil), *s.p...)
+	return &genericSliceValue[T]{p: &p}
+}
+
+// reset resets the values to empty.
+func (s *genericSliceValue[T]) reset() {
+	s.changed = false
+	*s.p = nil
+}
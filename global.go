@@ -3,8 +3,11 @@ package flagx
 import (
 	"flag"
 	"fmt"
+	"io/fs"
 	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -32,16 +35,12 @@ func LookupOptions(arguments []string, name string) []*Option {
 		return nil
 	}
 	r := make([]*Option, 0, 2)
-	var err error
+	var ok bool
 	var cmd string
 	for {
 		cmd, arguments = SplitArgs(arguments)
-		arguments, _, err = filterArgs(arguments, func(key string, valPtr *string) bool {
+		arguments, ok = scanArgs(arguments, func(key, val string) bool {
 			if key == name {
-				var val string
-				if valPtr != nil {
-					val = *valPtr
-				}
 				r = append(r, &Option{
 					Command: cmd,
 					Name:    name,
@@ -50,7 +49,7 @@ func LookupOptions(arguments []string, name string) []*Option {
 			}
 			return true
 		})
-		if err != nil || len(arguments) == 0 {
+		if !ok || len(arguments) == 0 {
 			return r
 		}
 	}
@@ -58,261 +57,343 @@ func LookupOptions(arguments []string, name string) []*Option {
 
 // LookupArgs lookups the value corresponding to the name
 // directly from the arguments.
+// NOTE:
+//  it scans the arguments without allocation and stops at the first match.
 func LookupArgs(arguments []string, name string) (value string, found bool) {
 	_, arguments = SplitArgs(arguments)
-	filteredArgs, _, _, _ := tidyArgs(arguments, func(key string) (want, next bool) {
+	scanArgs(arguments, func(key, val string) bool {
 		if key == name {
-			return true, false
+			value, found = val, true
+			return false
 		}
-		return false, true
+		return true
 	})
-	switch len(filteredArgs) {
-	case 0:
-		return "", false
-	case 1:
-		return "", true
-	default:
-		return filteredArgs[1], true
+	return value, found
+}
+
+// scanArgs scans the flags at the front of the arguments in the same way as tidyOneArg
+// without allocation, calling fn for each until it returns false.
+// It returns the rest arguments, and reports false if a bad flag syntax is met.
+func scanArgs(args []string, fn func(name, value string) (next bool)) (lastArgs []string, ok bool) {
+	for len(args) > 0 {
+		s := args[0]
+		if len(s) < 2 || s[0] != '-' {
+			break
+		}
+		name := s[1:]
+		if name[0] == '-' {
+			if len(name) == 1 { // "--" terminates the flags
+				return args[1:], true
+			}
+			name = name[1:]
+		}
+		if len(name) == 0 || name[0] == '-' || name[0] == '=' {
+			return args, false
+		}
+		args = args[1:]
+		var value string
+		if i := strings.IndexByte(name[1:], '='); i >= 0 { // equals cannot be first
+			name, value = name[:i+1], name[i+2:]
+		} else if len(args) > 0 && (len(args[0]) == 0 || args[0][0] != '-') { // value is the next arg
+			value = args[0]
+			args = args[1:]
+		}
+		if !fn(name, value) {
+			break
+		}
 	}
+	return args, true
 }
 
 // Lookup returns the Flag structure of the named command-line flag,
 // returning nil if none exists.
 func Lookup(name string) *Flag {
-	return CommandLine.Lookup(name)
+	return CommandLine().Lookup(name)
 }
 
-// CommandLine is the default set of command-line flags, parsed from os.Args.
+var (
+	commandLine     atomic.Pointer[FlagSet]
+	commandLineOnce sync.Once
+)
+
+// CommandLine returns the default set of command-line flags, parsed from os.Args.
 // The top-level functions such as BoolVar, Arg, and so on are wrappers for the
 // methods of CommandLine.
-var CommandLine = NewFlagSet(os.Args[0], ExitOnError|ContinueOnUndefined)
+// NOTE:
+//  it is created on the first use, so that os.Args can be modified before;
+//  it can be replaced by SetCommandLine.
+func CommandLine() *FlagSet {
+	if f := commandLine.Load(); f != nil {
+		return f
+	}
+	commandLineOnce.Do(func() {
+		f := NewFlagSet(os.Args[0], ExitOnError|ContinueOnUndefined)
+		// Override generic FlagSet default Usage with call to global Usage.
+		f.Usage = flag.CommandLine.Usage
+		commandLine.CompareAndSwap(nil, f)
+	})
+	return commandLine.Load()
+}
 
-func init() {
-	// Override generic FlagSet default Usage with call to global Usage.
-	// Note: This is not CommandLine.Usage = Usage,
-	// because we want any eventual call to use any updated value of Usage,
-	// not the value it has when this line is run.
-	CommandLine.Usage = flag.CommandLine.Usage
+// SetCommandLine replaces the default set of command-line flags used by the top-level functions.
+func SetCommandLine(f *FlagSet) {
+	commandLine.Store(f)
 }
 
 // Arg returns the i'th command-line argument. Arg(0) is the first remaining argument
 // after flags have been processed. Arg returns an empty string if the
 // requested element does not exist.
 func Arg(i int) string {
-	return CommandLine.Arg(i)
+	return CommandLine().Arg(i)
 }
 
 // Args returns the non-flag command-line arguments.
 func Args() []string {
-	return CommandLine.Args()
+	return CommandLine().Args()
 }
 
 // NextArgs returns arguments of the next subcommand.
-func NextArgs() []string { return CommandLine.NextArgs() }
+func NextArgs() []string { return CommandLine().NextArgs() }
 
 // Bool defines a bool flag with specified name, default value, and usage string.
 // The return value is the address of a bool variable that stores the value of the flag.
 func Bool(name string, value bool, usage string) *bool {
-	return CommandLine.Bool(name, value, usage)
+	return CommandLine().Bool(name, value, usage)
 }
 
 // BoolVar defines a bool flag with specified name, default value, and usage string.
 // The argument p points to a bool variable in which to store the value of the flag.
 func BoolVar(p *bool, name string, value bool, usage string) {
-	CommandLine.BoolVar(p, name, value, usage)
+	CommandLine().BoolVar(p, name, value, usage)
 }
 
 // Duration defines a time.Duration flag with specified name, default value, and usage string.
 // The return value is the address of a time.Duration variable that stores the value of the flag.
 // The flag accepts a value acceptable to time.ParseDuration.
 func Duration(name string, value time.Duration, usage string) *time.Duration {
-	return CommandLine.Duration(name, value, usage)
+	return CommandLine().Duration(name, value, usage)
 }
 
 // DurationVar defines a time.Duration flag with specified name, default value, and usage string.
 // The argument p points to a time.Duration variable in which to store the value of the flag.
 // The flag accepts a value acceptable to time.ParseDuration.
 func DurationVar(p *time.Duration, name string, value time.Duration, usage string) {
-	CommandLine.DurationVar(p, name, value, usage)
+	CommandLine().DurationVar(p, name, value, usage)
 }
 
 // Float64 defines a float64 flag with specified name, default value, and usage string.
 // The return value is the address of a float64 variable that stores the value of the flag.
 func Float64(name string, value float64, usage string) *float64 {
-	return CommandLine.Float64(name, value, usage)
+	return CommandLine().Float64(name, value, usage)
 }
 
 // Float64Var defines a float64 flag with specified name, default value, and usage string.
 // The argument p points to a float64 variable in which to store the value of the flag.
 func Float64Var(p *float64, name string, value float64, usage string) {
-	CommandLine.Float64Var(p, name, value, usage)
+	CommandLine().Float64Var(p, name, value, usage)
 }
 
 // Int defines an int flag with specified name, default value, and usage string.
 // The return value is the address of an int variable that stores the value of the flag.
 func Int(name string, value int, usage string) *int {
-	return CommandLine.Int(name, value, usage)
+	return CommandLine().Int(name, value, usage)
 }
 
 // Int64 defines an int64 flag with specified name, default value, and usage string.
 // The return value is the address of an int64 variable that stores the value of the flag.
 func Int64(name string, value int64, usage string) *int64 {
-	return CommandLine.Int64(name, value, usage)
+	return CommandLine().Int64(name, value, usage)
 }
 
 // Int64Var defines an int64 flag with specified name, default value, and usage string.
 // The argument p points to an int64 variable in which to store the value of the flag.
 func Int64Var(p *int64, name string, value int64, usage string) {
-	CommandLine.Int64Var(p, name, value, usage)
+	CommandLine().Int64Var(p, name, value, usage)
 }
 
 // IntVar defines an int flag with specified name, default value, and usage string.
 // The argument p points to an int variable in which to store the value of the flag.
 func IntVar(p *int, name string, value int, usage string) {
-	CommandLine.IntVar(p, name, value, usage)
+	CommandLine().IntVar(p, name, value, usage)
 }
 
 // NonBoolVar defines a bool non-flag with specified index, default value, and usage string.
 // The argument p points to a bool variable in which to store the value of the non-flag.
 func NonBoolVar(p *bool, index int, value bool, usage string) {
-	CommandLine.NonVar(newBoolValue(value, p), index, usage)
+	CommandLine().NonVar(newBoolValue(value, p), index, usage)
 }
 
 // NonBool defines a bool non-flag with specified index, default value, and usage string.
 // The return value is the address of a bool variable that stores the value of the non-flag.
 func NonBool(index int, value bool, usage string) *bool {
-	return CommandLine.NonBool(index, value, usage)
+	return CommandLine().NonBool(index, value, usage)
 }
 
 // NonIntVar defines an int non-flag with specified index, default value, and usage string.
 // The argument p points to an int variable in which to store the value of the non-flag.
 func NonIntVar(p *int, index int, value int, usage string) {
-	CommandLine.NonVar(newIntValue(value, p), index, usage)
+	CommandLine().NonVar(newIntValue(value, p), index, usage)
 }
 
 // NonInt defines an int non-flag with specified index, default value, and usage string.
 // The return value is the address of an int variable that stores the value of the non-flag.
 func NonInt(index int, value int, usage string) *int {
-	return CommandLine.NonInt(index, value, usage)
+	return CommandLine().NonInt(index, value, usage)
 }
 
 // NonInt64Var defines an int64 non-flag with specified index, default value, and usage string.
 // The argument p points to an int64 variable in which to store the value of the non-flag.
 func NonInt64Var(p *int64, index int, value int64, usage string) {
-	CommandLine.NonVar(newInt64Value(value, p), index, usage)
+	CommandLine().NonVar(newInt64Value(value, p), index, usage)
 }
 
 // NonInt64 defines an int64 non-flag with specified index, default value, and usage string.
 // The return value is the address of an int64 variable that stores the value of the non-flag.
 func NonInt64(index int, value int64, usage string) *int64 {
-	return CommandLine.NonInt64(index, value, usage)
+	return CommandLine().NonInt64(index, value, usage)
 }
 
 // NonUintVar defines a uint non-flag with specified index, default value, and usage string.
 // The argument p points to a uint variable in which to store the value of the non-flag.
 func NonUintVar(p *uint, index int, value uint, usage string) {
-	CommandLine.NonVar(newUintValue(value, p), index, usage)
+	CommandLine().NonVar(newUintValue(value, p), index, usage)
 }
 
 // NonUint defines a uint non-flag with specified index, default value, and usage string.
 // The return value is the address of a uint variable that stores the value of the non-flag.
 func NonUint(index int, value uint, usage string) *uint {
-	return CommandLine.NonUint(index, value, usage)
+	return CommandLine().NonUint(index, value, usage)
 }
 
 // NonUint64Var defines a uint64 non-flag with specified index, default value, and usage string.
 // The argument p points to a uint64 variable in which to store the value of the non-flag.
 func NonUint64Var(p *uint64, index int, value uint64, usage string) {
-	CommandLine.NonVar(newUint64Value(value, p), index, usage)
+	CommandLine().NonVar(newUint64Value(value, p), index, usage)
 }
 
 // NonUint64 defines a uint64 non-flag with specified index, default value, and usage string.
 // The return value is the address of a uint64 variable that stores the value of the non-flag.
 func NonUint64(index int, value uint64, usage string) *uint64 {
-	return CommandLine.NonUint64(index, value, usage)
+	return CommandLine().NonUint64(index, value, usage)
 }
 
 // NonStringVar defines a string non-flag with specified index, default value, and usage string.
 // The argument p points to a string variable in which to store the value of the non-flag.
 func NonStringVar(p *string, index int, value string, usage string) {
-	CommandLine.NonVar(newStringValue(value, p), index, usage)
+	CommandLine().NonVar(newStringValue(value, p), index, usage)
 }
 
 // NonString defines a string non-flag with specified index, default value, and usage string.
 // The return value is the address of a string variable that stores the value of the non-flag.
 func NonString(index int, value string, usage string) *string {
-	return CommandLine.NonString(index, value, usage)
+	return CommandLine().NonString(index, value, usage)
 }
 
 // NonFloat64Var defines a float64 non-flag with specified index, default value, and usage string.
 // The argument p points to a float64 variable in which to store the value of the non-flag.
 func NonFloat64Var(p *float64, index int, value float64, usage string) {
-	CommandLine.NonVar(newFloat64Value(value, p), index, usage)
+	CommandLine().NonVar(newFloat64Value(value, p), index, usage)
 }
 
 // NonFloat64 defines a float64 non-flag with specified index, default value, and usage string.
 // The return value is the address of a float64 variable that stores the value of the non-flag.
 func NonFloat64(index int, value float64, usage string) *float64 {
-	return CommandLine.NonFloat64(index, value, usage)
+	return CommandLine().NonFloat64(index, value, usage)
 }
 
 // NonDurationVar defines a time.Duration non-flag with specified index, default value, and usage string.
 // The argument p points to a time.Duration variable in which to store the value of the non-flag.
 // The non-flag accepts a value acceptable to time.ParseDuration.
 func NonDurationVar(p *time.Duration, index int, value time.Duration, usage string) {
-	CommandLine.NonVar(newDurationValue(value, p), index, usage)
+	CommandLine().NonVar(newDurationValue(value, p), index, usage)
 }
 
 // NonDuration defines a time.Duration non with specified index, default value, and usage string.
 // The return value is the address of a time.Duration variable that stores the value of the non-flag.
 // The non-flag accepts a value acceptable to time.ParseDuration.
 func NonDuration(index int, value time.Duration, usage string) *time.Duration {
-	return CommandLine.NonDuration(index, value, usage)
+	return CommandLine().NonDuration(index, value, usage)
 }
 
 // NonVar defines a non-flag with the specified index and usage string.
 func NonVar(value Value, index int, usage string) {
-	CommandLine.NonVar(value, index, usage)
+	CommandLine().NonVar(value, index, usage)
 }
 
 // NArg is the number of arguments remaining after flags have been processed.
 func NArg() int {
-	return CommandLine.NArg()
+	return CommandLine().NArg()
 }
 
 // NFlag returns the number of command-line flags that have been set.
 func NFlag() int {
-	return CommandLine.NFlag()
+	return CommandLine().NFlag()
 }
 
 // NFormalNonFlag returns the number of non-flag required in the definition.
 func NFormalNonFlag() int {
-	return CommandLine.NFormalNonFlag()
+	return CommandLine().NFormalNonFlag()
 }
 
 // Parse parses the command-line flags from os.Args[1:]. Must be called
 // after all flags are defined and before flags are accessed by the program.
 func Parse() {
 	// Ignore errors; CommandLine is set for ExitOnError.
-	CommandLine.Parse(os.Args[1:])
+	CommandLine().Parse(os.Args[1:])
+}
+
+// BindConfigFile loads the values of the command-line flags that are not set on the command line from the config file.
+func BindConfigFile(filename string, decoder ConfigDecoder) error {
+	return CommandLine().BindConfigFile(filename, decoder)
+}
+
+// SetExpander sets the resolver of the ${VAR} and $VAR references in the string values of
+// the command-line flags and non-flags, which are expanded after parsing.
+func SetExpander(fn ExpandFunc) {
+	CommandLine().SetExpander(fn)
+}
+
+// SetParseMode sets the opt-in behaviors of parsing the command-line arguments.
+func SetParseMode(mode ParseMode) {
+	CommandLine().SetParseMode(mode)
+}
+
+// SetFS sets the file system from which the config files of the command-line flags are read.
+func SetFS(fsys fs.FS) {
+	CommandLine().SetFS(fsys)
+}
+
+// MarkSecret marks the command-line flags or non-flags as secret, whose values are resolved by the secret resolver.
+func MarkSecret(names ...string) error {
+	return CommandLine().MarkSecret(names...)
+}
+
+// SetSecretResolver sets the resolver of the values of the secret command-line flags.
+func SetSecretResolver(r SecretResolver) {
+	CommandLine().SetSecretResolver(r)
+}
+
+// AddDefaultsProvider adds the providers of the default values of the command-line flags.
+func AddDefaultsProvider(fns ...DefaultsProvider) {
+	CommandLine().AddDefaultsProvider(fns...)
 }
 
 // Parsed reports whether the command-line flags have been parsed.
 func Parsed() bool {
-	return CommandLine.Parsed()
+	return CommandLine().Parsed()
 }
 
 // Usage prints the default usage message.
 func Usage() {
-	if CommandLine.Usage != nil {
-		CommandLine.Usage()
+	if CommandLine().Usage != nil {
+		CommandLine().Usage()
 	} else {
-		if CommandLine.Name() == "" {
-			fmt.Fprintf(CommandLine.Output(), "Usage:\n")
+		if CommandLine().Name() == "" {
+			fmt.Fprintf(CommandLine().Output(), "Usage:\n")
 		} else {
-			fmt.Fprintf(CommandLine.Output(), "Usage of %s:\n", CommandLine.Name())
+			fmt.Fprintf(CommandLine().Output(), "Usage of %s:\n", CommandLine().Name())
 		}
-		CommandLine.PrintDefaults()
+		CommandLine().PrintDefaults()
 	}
 }
 
@@ -336,57 +417,93 @@ func Usage() {
 //	-I directory
 //		search directory for include files.
 //
-// To change the destination for flag messages, call CommandLine.SetOutput.
+// To change the destination for flag messages, call CommandLine().SetOutput.
 func PrintDefaults() {
-	CommandLine.PrintDefaults()
+	CommandLine().PrintDefaults()
 }
 
 // Set sets the value of the named command-line flag.
 func Set(name, value string) error {
-	return CommandLine.Set(name, value)
+	return CommandLine().Set(name, value)
 }
 
 // String defines a string flag with specified name, default value, and usage string.
 // The return value is the address of a string variable that stores the value of the flag.
 func String(name string, value string, usage string) *string {
-	return CommandLine.String(name, value, usage)
+	return CommandLine().String(name, value, usage)
 }
 
 // StringVar defines a string flag with specified name, default value, and usage string.
 // The argument p points to a string variable in which to store the value of the flag.
 func StringVar(p *string, name string, value string, usage string) {
-	CommandLine.StringVar(p, name, value, usage)
+	CommandLine().StringVar(p, name, value, usage)
+}
+
+// StringSlice defines a []string flag with specified name, default value, and usage string.
+// The return value is the address of a []string variable that stores the values of the flag.
+func StringSlice(name string, value []string, usage string) *[]string {
+	return CommandLine().StringSlice(name, value, usage)
+}
+
+// StringSliceVar defines a []string flag with specified name, default value, and usage string.
+// The argument p points to a []string variable in which to store the values of the flag.
+func StringSliceVar(p *[]string, name string, value []string, usage string) {
+	CommandLine().StringSliceVar(p, name, value, usage)
+}
+
+// IntSlice defines a []int flag with specified name, default value, and usage string.
+// The return value is the address of a []int variable that stores the values of the flag.
+func IntSlice(name string, value []int, usage string) *[]int {
+	return CommandLine().IntSlice(name, value, usage)
+}
+
+// IntSliceVar defines a []int flag with specified name, default value, and usage string.
+// The argument p points to a []int variable in which to store the values of the flag.
+func IntSliceVar(p *[]int, name string, value []int, usage string) {
+	CommandLine().IntSliceVar(p, name, value, usage)
+}
+
+// Int64Slice defines a []int64 flag with specified name, default value, and usage string.
+// The return value is the address of a []int64 variable that stores the values of the flag.
+func Int64Slice(name string, value []int64, usage string) *[]int64 {
+	return CommandLine().Int64Slice(name, value, usage)
+}
+
+// Int64SliceVar defines a []int64 flag with specified name, default value, and usage string.
+// The argument p points to a []int64 variable in which to store the values of the flag.
+func Int64SliceVar(p *[]int64, name string, value []int64, usage string) {
+	CommandLine().Int64SliceVar(p, name, value, usage)
 }
 
 // StructVars defines flags based on struct tags and binds to fields.
 // NOTE:
 //  Not support nested fields
 func StructVars(p interface{}) error {
-	return CommandLine.StructVars(p)
+	return CommandLine().StructVars(p)
 }
 
 // Uint defines a uint flag with specified name, default value, and usage string.
 // The return value is the address of a uint variable that stores the value of the flag.
 func Uint(name string, value uint, usage string) *uint {
-	return CommandLine.Uint(name, value, usage)
+	return CommandLine().Uint(name, value, usage)
 }
 
 // Uint64 defines a uint64 flag with specified name, default value, and usage string.
 // The return value is the address of a uint64 variable that stores the value of the flag.
 func Uint64(name string, value uint64, usage string) *uint64 {
-	return CommandLine.Uint64(name, value, usage)
+	return CommandLine().Uint64(name, value, usage)
 }
 
 // Uint64Var defines a uint64 flag with specified name, default value, and usage string.
 // The argument p points to a uint64 variable in which to store the value of the flag.
 func Uint64Var(p *uint64, name string, value uint64, usage string) {
-	CommandLine.Uint64Var(p, name, value, usage)
+	CommandLine().Uint64Var(p, name, value, usage)
 }
 
 // UintVar defines a uint flag with specified name, default value, and usage string.
 // The argument p points to a uint variable in which to store the value of the flag.
 func UintVar(p *uint, name string, value uint, usage string) {
-	CommandLine.UintVar(p, name, value, usage)
+	CommandLine().UintVar(p, name, value, usage)
 }
 
 // UnquoteUsage extracts a back-quoted name from the usage
@@ -396,7 +513,14 @@ func UintVar(p *uint, name string, value uint, usage string) {
 // type of the flag's value, or the empty string if the flag is boolean.
 func UnquoteUsage(f *Flag) (name string, usage string) {
 	if !IsNonFlag(f) {
-		return flag.UnquoteUsage(f)
+		name, usage = flag.UnquoteUsage(f)
+		if name == "value" {
+			switch f.Value.(type) {
+			case *intSliceValue, *int64SliceValue:
+				name = "ints"
+			}
+		}
+		return name, usage
 	}
 	// Look for a back-quoted name, but avoid the strings package.
 	usage = f.Usage
@@ -438,43 +562,43 @@ func UnquoteUsage(f *Flag) (name string, usage string) {
 // of strings by giving the slice the methods of Value; in particular, Set would
 // decompose the comma-separated string into the slice.
 func Var(value Value, name string, usage string) {
-	CommandLine.Var(value, name, usage)
+	CommandLine().Var(value, name, usage)
 }
 
 // RangeAll visits the command-line flags and non-flags in lexicographical order, calling fn for each.
 // It visits all flags and non-flags, even those not set.
 func RangeAll(fn func(*Flag)) {
-	CommandLine.RangeAll(fn)
+	CommandLine().RangeAll(fn)
 }
 
 // Range visits the command-line flags and non-flags in lexicographical order, calling fn for each.
 // It visits only those flags and non-flags that have been set.
 func Range(fn func(*Flag)) {
-	CommandLine.Range(fn)
+	CommandLine().Range(fn)
 }
 
 // Visit visits the command-line flags in lexicographical order, calling fn
 // for each. It visits only those flags that have been set.
 func Visit(fn func(*Flag)) {
-	CommandLine.Visit(fn)
+	CommandLine().Visit(fn)
 }
 
 // VisitAll visits the command-line flags in lexicographical order, calling
 // fn for each. It visits all flags, even those not set.
 func VisitAll(fn func(*Flag)) {
-	CommandLine.VisitAll(fn)
+	CommandLine().VisitAll(fn)
 }
 
 // NonVisitAll visits the command-line non-flags in lexicographical order, calling
 // fn for each. It visits all non-flags, even those not set.
 func NonVisitAll(fn func(*Flag)) {
-	CommandLine.NonVisitAll(fn)
+	CommandLine().NonVisitAll(fn)
 }
 
 // NonVisit visits the command-line non-flags in lexicographical order, calling fn
 // for each. It visits only those non-flags that have been set.
 func NonVisit(fn func(*Flag)) {
-	CommandLine.NonVisit(fn)
+	CommandLine().NonVisit(fn)
 }
 
 // IsNonFlag determines if it is non-flag.
@@ -33,6 +33,19 @@ func TestLookupArgs(t *testing.T) {
 	v, ok = LookupArgs(args, "???")
 	assert.False(t, ok)
 	assert.Equal(t, "", v)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		LookupArgs(args, "x")
+	})
+	assert.Equal(t, float64(0), allocs)
+}
+
+func BenchmarkLookupArgs(b *testing.B) {
+	var args = []string{"sub", "-run", "abc", "-t", "5s", "-Cool", "-N=1", "-x"}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		LookupArgs(args, "x")
+	}
 }
 
 func TestUnquoteUsage(t *testing.T) {
@@ -76,3 +89,15 @@ func TestNextArgs(t *testing.T) {
 	assert.Equal(t, 1, fs.NFormalNonFlag())
 	assert.Equal(t, []string{"5s", "--", "-N=1", "-x", "y", "z"}, fs.NextArgs())
 }
+
+func TestSetCommandLine(t *testing.T) {
+	def := CommandLine()
+	assert.True(t, def == CommandLine())
+	fs := NewFlagSet("replaced", ContinueOnError)
+	SetCommandLine(fs)
+	defer SetCommandLine(def)
+	x := String("x", "", "")
+	assert.NoError(t, CommandLine().Parse([]string{"-x", "1"}))
+	assert.Equal(t, "1", *x)
+	assert.True(t, fs.Lookup("x") == Lookup("x"))
+}
@@ -0,0 +1,151 @@
+package flagx
+
+import (
+	"strings"
+	"time"
+)
+
+// GlobalFlags returns the flag set defining the app-level global flags and non-flags, such as
+// a root-level positional of the environment name, which are consumed before the subcommand routing
+// without declaring a filter.
+// NOTE:
+//  each execution parses them into its own values, which are read by Context.Global,
+//  so the variables returned by the definitions only hold the defaults;
+//  the values of the types not defined by flagx are parsed as strings;
+//  panic if the command tree is frozen, so define the flags before the first Exec.
+// Example:
+//  app.GlobalFlags().NonString(0, "dev", "the environment name")
+//  env := c.Global("?0").(string)
+func (a *App) GlobalFlags() *FlagSet {
+	a.Command.checkMutable()
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.globals == nil {
+		a.globals = NewFlagSet(a.cmdName, ContinueOnError)
+	}
+	a.Command.updateUsageLocked()
+	return a.globals
+}
+
+// NonString defines a string global non-flag with specified index, default value, and usage string,
+// see GlobalFlags.
+func (a *App) NonString(index int, value string, usage string) {
+	a.GlobalFlags().NonString(index, value, usage)
+}
+
+// Global returns the value of the global flag or non-flag of the execution, such as "region" or "?0",
+// which is got by flag.Getter if implemented, otherwise it is the string; see App.GlobalFlags.
+// NOTE:
+//  returns nil if it is not defined.
+func (c *Context) Global(name string) interface{} {
+	if c.snap == nil || c.snap.globals == nil {
+		return nil
+	}
+	f := c.snap.globals.Lookup(strings.TrimLeft(name, "-"))
+	if f == nil {
+		return nil
+	}
+	return flagValue(f)
+}
+
+// globalFlagObjects returns the global flags and non-flags for the usage.
+func (a *App) globalFlagObjects() []*Flag {
+	if a.globals == nil {
+		return nil
+	}
+	var flags []*Flag
+	a.globals.RangeAll(func(f *Flag) {
+		flags = append(flags, f)
+	})
+	return flags
+}
+
+// parseGlobals parses the global flags and non-flags into the values of the execution,
+// and returns the rest arguments.
+func (snap *execSnapshot) parseGlobals(c *Command, arguments []string) []string {
+	a := c.app
+	a.lock.RLock()
+	globals := a.globals
+	a.lock.RUnlock()
+	if globals == nil {
+		return arguments
+	}
+	flagSet := NewFlagSet(c.cmdName, ContinueOnError)
+	globals.VisitAll(func(f *Flag) {
+		flagSet.Var(cloneValue(f), f.Name, f.Usage)
+	})
+	globals.NonVisitAll(func(f *Flag) {
+		idx, _, _ := getNonFlagIndex(f.Name)
+		flagSet.NonVar(cloneValue(f), idx, f.Usage)
+	})
+	snap.globals = flagSet
+	snap.setDiagnostics(c, flagSet, false)
+	flagSet.parseMode |= snap.parseMode
+	snap.checkStatus(snap.translateError(flagSet.Parse(arguments)), StatusParseFailed, "")
+	snap.checkStatus(snap.applyEnv(c, flagSet), StatusParseFailed, "")
+	snap.checkStatus(snap.applyConfig(c, flagSet), StatusParseFailed, "")
+	snap.checkStatus(snap.applyDefaults(c, flagSet), StatusParseFailed, "")
+	return flagSet.NextArgs()
+}
+
+// cloneValue returns a new value of the same type as the defined one, which holds the default value.
+// NOTE:
+//  the values of the other types than the ones defined by FlagSet are parsed as strings.
+func cloneValue(f *Flag) Value {
+	if v, ok := f.Value.(interface{ clone() Value }); ok {
+		return v.clone()
+	}
+	if getter, ok := f.Value.(Getter); ok {
+		switch v := getter.Get().(type) {
+		case bool:
+			return newBoolValue(v, new(bool))
+		case int:
+			return newIntValue(v, new(int))
+		case int64:
+			return newInt64Value(v, new(int64))
+		case uint:
+			return newUintValue(v, new(uint))
+		case uint64:
+			return newUint64Value(v, new(uint64))
+		case string:
+			return newStringValue(v, new(string))
+		case float64:
+			return newFloat64Value(v, new(float64))
+		case time.Duration:
+			return newDurationValue(v, new(time.Duration))
+		case []string:
+			return newStringSliceValue(append([]string(nil), v...), new([]string))
+		case []int:
+			return newIntSliceValue(v, new([]int))
+		case []int64:
+			return newInt64SliceValue(v, new([]int64))
+		}
+	}
+	return &rawValue{s: f.DefValue, isBool: isBoolFlag(f)}
+}
+
+// rawValue the string value of the global flag whose type is not defined by flagx
+type rawValue struct {
+	s      string
+	isBool bool
+}
+
+func (r *rawValue) Set(s string) error {
+	r.s = s
+	return nil
+}
+
+func (r *rawValue) Get() interface{} { return r.s }
+
+func (r *rawValue) String() string { return r.s }
+
+func (r *rawValue) IsBoolFlag() bool { return r.isBool }
+
+// resetValue resets the value of the flag to the default value.
+func resetValue(f *Flag) {
+	if s, ok := f.Value.(interface{ reset() }); ok {
+		s.reset()
+		return
+	}
+	f.Value.Set(f.DefValue)
+}
@@ -1,10 +1,20 @@
 module github.com/henrylee2cn/flagx
 
-go 1.13
+go 1.21
 
 require (
 	github.com/bytedance/go-tagexpr/v2 v2.7.8
+	github.com/fsnotify/fsnotify v1.8.0
 	github.com/henrylee2cn/ameda v1.4.8
 	github.com/henrylee2cn/goutil v0.0.0-20210127050712-89660552f6f8
 	github.com/stretchr/testify v1.5.1
 )
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/golang/protobuf v1.3.2 // indirect
+	github.com/nyaruka/phonenumbers v1.0.55 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/sys v0.13.0 // indirect
+	gopkg.in/yaml.v2 v2.2.2 // indirect
+)
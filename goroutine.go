@@ -0,0 +1,119 @@
+package flagx
+
+import (
+	"context"
+	"sync"
+)
+
+// goGroup the goroutines spawned by Context.Go
+type goGroup struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	lock    sync.Mutex
+	failure interface{} // The first failure, *Status or *PanicError
+}
+
+// Go runs the function in a new goroutine managed by the execution, such as the workers of an action.
+// NOTE:
+//  the function gets a Context whose embedded context is cancelled when the Exec context is done
+//  or any of the managed goroutines fails;
+//  the returned error and the panic are converted into the status like the ones of the action,
+//  the first of which is thrown by Wait;
+//  the execution waits for the managed goroutines after the action returns.
+// Example:
+//  for _, url := range urls {
+//  	url := url
+//  	c.Go(func(c *flagx.Context) error { return fetch(c, url) })
+//  }
+//  c.Wait()
+func (c *Context) Go(fn func(*Context) error) {
+	g := c.goGroup()
+	child := &Context{
+		Context:      g.ctx,
+		args:         c.args,
+		rawArgs:      c.rawArgs,
+		cmdPath:      c.cmdPath,
+		cmd:          c.cmd,
+		execScope:    c.execScope,
+		snap:         c.snap,
+		requestID:    c.RequestID(),
+		filters:      c.filters,
+		outputFormat: c.outputFormat,
+		external:     c.external,
+	}
+	child.requestIDOnce.Do(func() {})
+	c.valuesLock.RLock()
+	for k, v := range c.values {
+		child.SetValue(k, v)
+	}
+	c.valuesLock.RUnlock()
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		defer func() {
+			if r := recover(); r != nil {
+				g.fail(child, r)
+			}
+		}()
+		child.CheckStatus(fn(child), StatusExecuteFailed, "")
+		child.Wait()
+	}()
+}
+
+// Wait waits for the goroutines started by Go, and throws the status of the first failure if any,
+// which is handled like the one of the action.
+func (c *Context) Wait() {
+	g := c.group
+	if g == nil {
+		return
+	}
+	g.wg.Wait()
+	g.lock.Lock()
+	failure := g.failure
+	g.failure = nil
+	g.lock.Unlock()
+	if failure != nil {
+		panic(failure)
+	}
+}
+
+// stopGoroutines cancels the goroutines started by Go and waits for them, ignoring their failures.
+func (c *Context) stopGoroutines() {
+	if g := c.group; g != nil {
+		g.cancel()
+		g.wg.Wait()
+	}
+}
+
+func (c *Context) goGroup() *goGroup {
+	c.groupOnce.Do(func() {
+		parent := c.Context
+		if parent == nil {
+			parent = context.Background()
+		}
+		g := &goGroup{}
+		g.ctx, g.cancel = context.WithCancel(parent)
+		c.group = g
+	})
+	return c.group
+}
+
+// fail records the first failure converted from the panic value and cancels the other goroutines.
+func (g *goGroup) fail(c *Context, r interface{}) {
+	defer g.cancel()
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.failure != nil {
+		return
+	}
+	defer func() {
+		// the panics are propagated by the waiting goroutine, see App.SetRecoverPanics
+		if p := recover(); p != nil {
+			g.failure = p
+		}
+	}()
+	if stat := c.snap.recoverStatus(c, r); !stat.OK() {
+		g.failure = stat
+	}
+}
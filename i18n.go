@@ -0,0 +1,102 @@
+package flagx
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TranslateFunc translates the message key with the arguments into a localized text.
+// NOTE:
+//  the key is also the default format of the text, see the Msg* constants.
+type TranslateFunc func(key string, args ...interface{}) string
+
+// Message keys of the generated text.
+const (
+	MsgUsage             = "USAGE"
+	MsgAuthor            = "AUTHOR"
+	MsgAuthors           = "AUTHORS"
+	MsgCopyright         = "COPYRIGHT"
+	MsgExamples          = "EXAMPLES"
+	MsgSeeAlso           = "SEE ALSO"
+	MsgNotFound          = "not found command action: %q"
+	MsgAmbiguousCommand  = "ambiguous command %q, candidates: %s"
+	MsgFlagNotDefined    = "flag provided but not defined: -%s"
+	MsgFlagNeedsArgument = "flag needs an argument: -%s"
+	MsgBadFlagSyntax     = "bad flag syntax: %s"
+	MsgDidYouMean        = "did you mean %s?"
+	MsgLocked            = "command %q is already running, locked by %s"
+)
+
+// parseErrorKeys the message keys of the parse errors, whose only argument is at the end.
+var parseErrorKeys = []string{
+	MsgFlagNotDefined,
+	MsgFlagNeedsArgument,
+	MsgBadFlagSyntax,
+}
+
+// SetTranslator sets the translator of the generated text,
+// such as usage, default error strings and not found text.
+func (a *App) SetTranslator(fn TranslateFunc) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.translator = fn
+	a.resetUsageLocked()
+}
+
+// translate translates the message key by the translator of the app.
+func (a *App) translate(key string, args ...interface{}) string {
+	return translateBy(a.translator, key, args...)
+}
+
+// translate translates the message key by the translator of the execution.
+func (snap *execSnapshot) translate(key string, args ...interface{}) string {
+	return translateBy(snap.translator, key, args...)
+}
+
+// translateError translates the parse error by the translator of the execution.
+func (snap *execSnapshot) translateError(err error) error {
+	if err == nil || snap.translator == nil {
+		return err
+	}
+	if undefined, ok := err.(*ErrUndefinedFlag); ok && len(undefined.Suggestions) > 0 {
+		return &translatedError{
+			msg: snap.translator(MsgFlagNotDefined, undefined.Name) + "; " +
+				snap.translator(MsgDidYouMean, suggestionList(undefined.Suggestions)),
+			err: err,
+		}
+	}
+	text := err.Error()
+	for _, key := range parseErrorKeys {
+		prefix := strings.TrimSuffix(key, "%s")
+		if strings.HasPrefix(text, prefix) {
+			return &translatedError{msg: snap.translator(key, text[len(prefix):]), err: err}
+		}
+	}
+	return err
+}
+
+// translatedError the parse error with the translated message,
+// which unwraps to the typed error, so that errors.Is and errors.As still work.
+type translatedError struct {
+	msg string
+	err error
+}
+
+func (e *translatedError) Error() string { return e.msg }
+
+func (e *translatedError) Unwrap() error { return e.err }
+
+func translateBy(fn TranslateFunc, key string, args ...interface{}) string {
+	if fn != nil {
+		return fn(key, args...)
+	}
+	return translate(key, args...)
+}
+
+// translate is the default translator that formats the key with the arguments.
+func translate(key string, args ...interface{}) string {
+	if len(args) == 0 {
+		return key
+	}
+	return fmt.Sprintf(key, args...)
+}
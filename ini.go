@@ -0,0 +1,49 @@
+package flagx
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// INIDecoder decodes the INI or properties config data,
+// the keys in a section are prefixed with the section name, such as "[db] host=" to -db.host.
+// NOTE:
+//  the lines starting with '#' or ';' are comments;
+//  both '=' and ':' are supported as the separator;
+//  the double or single quotes around the value are removed.
+func INIDecoder(data []byte) (map[string]interface{}, error) {
+	m := make(map[string]interface{}, 16)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	var prefix string
+	var lineNo int
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: invalid section: %s", lineNo, line)
+			}
+			prefix = strings.TrimSpace(line[1 : len(line)-1])
+			if prefix != "" {
+				prefix += "."
+			}
+			continue
+		}
+		i := strings.IndexAny(line, "=:")
+		if i <= 0 {
+			return nil, fmt.Errorf("line %d: missing '=': %s", lineNo, line)
+		}
+		key := strings.TrimSpace(line[:i])
+		value := strings.TrimSpace(line[i+1:])
+		if n := len(value); n >= 2 && (value[0] == '"' || value[0] == '\'') && value[n-1] == value[0] {
+			value = value[1 : n-1]
+		}
+		m[prefix+key] = value
+	}
+	return m, scanner.Err()
+}
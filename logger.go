@@ -0,0 +1,61 @@
+package flagx
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+)
+
+// WithRequestID returns a copy of ctx with the request ID, which is used by the
+// execution instead of a generated one.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// SetLogger sets the base structured logger of the executions.
+// NOTE:
+//  defaults to slog.Default()
+func (a *App) SetLogger(logger *slog.Logger) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.logger = logger
+}
+
+// RequestID returns the request ID of the execution.
+// NOTE:
+//  it is taken from the context set by WithRequestID, or generated randomly.
+func (c *Context) RequestID() string {
+	c.requestIDOnce.Do(func() {
+		if c.Context != nil {
+			if id, ok := c.Context.Value(requestIDKey).(string); ok && id != "" {
+				c.requestID = id
+				return
+			}
+		}
+		var b [8]byte
+		rand.Read(b[:])
+		c.requestID = hex.EncodeToString(b[:])
+	})
+	return c.requestID
+}
+
+// Logger returns the structured logger pre-populated with the command path,
+// executor scope and request ID.
+func (c *Context) Logger() *slog.Logger {
+	c.loggerOnce.Do(func() {
+		var logger *slog.Logger
+		if c.snap != nil {
+			logger = c.snap.logger
+		}
+		if logger == nil {
+			logger = slog.Default()
+		}
+		c.logger = logger.With(
+			slog.String("cmd", c.CmdPathString()),
+			slog.Int("scope", int(c.execScope)),
+			slog.String("request_id", c.RequestID()),
+		)
+	})
+	return c.logger
+}
@@ -0,0 +1,42 @@
+package flagx
+
+import (
+	"time"
+)
+
+type (
+	// Collector collects the metrics of the executions, such as the Prometheus counters and histograms
+	// of the command usage.
+	// NOTE:
+	//  the methods are called synchronously in the executing goroutine.
+	Collector interface {
+		// ObserveExec is called after each execution with the command path, the duration and the status code.
+		ObserveExec(cmdPath []string, duration time.Duration, code int32)
+	}
+	// CollectorFunc collector function
+	CollectorFunc func(cmdPath []string, duration time.Duration, code int32)
+)
+
+// ObserveExec implements Collector interface.
+func (fn CollectorFunc) ObserveExec(cmdPath []string, duration time.Duration, code int32) {
+	fn(cmdPath, duration, code)
+}
+
+// SetMetrics sets the collector of the metrics of the executions, which is called after the error handler.
+// NOTE:
+//  the command path is the one of the resolved command, excluding the unknown subcommand names,
+//  so that the cardinality of the labels is bounded;
+//  set nil to disable it.
+// Example:
+//  app.SetMetrics(flagx.CollectorFunc(func(cmdPath []string, d time.Duration, code int32) {
+//  	execSeconds.WithLabelValues(strings.Join(cmdPath, " "), strconv.Itoa(int(code))).Observe(d.Seconds())
+//  }))
+func (a *App) SetMetrics(collector Collector) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.metrics = collector
+}
+
+func (snap *execSnapshot) observe(ctxObj *Context, start time.Time, stat *Status) {
+	snap.metrics.ObserveExec(ctxObj.cmd.Path(), time.Since(start), stat.Code())
+}
@@ -0,0 +1,78 @@
+package flagx
+
+// OnSet adds the observer of the named flag or non-flag, which is called with the old and new values
+// whenever an applied value changes it, such as by Parse, Set, the config files, the environment variables
+// and the reloads, so that the dependent settings can be derived.
+// NOTE:
+//  the observer is not called if the value does not change;
+//  the observers are registered by name, so setting an alias of the flag does not call them;
+//  it is not safe for concurrent use with the parsing.
+func (f *FlagSet) OnSet(name string, fn func(old, new string)) {
+	if f.observers == nil {
+		f.observers = make(map[string][]func(old, new string), 4)
+	}
+	f.observers[name] = append(f.observers[name], fn)
+}
+
+// setFlag sets the value of the named flag like FlagSet.Set, and notifies the observers.
+func (f *FlagSet) setFlag(name, value string) error {
+	fns := f.observers[name]
+	if len(fns) == 0 {
+		return f.FlagSet.Set(name, value)
+	}
+	var old string
+	if fl := f.FlagSet.Lookup(name); fl != nil {
+		old = fl.Value.String()
+	}
+	if err := f.FlagSet.Set(name, value); err != nil {
+		return err
+	}
+	notifyObservers(fns, old, f.FlagSet.Lookup(name).Value.String())
+	return nil
+}
+
+// setValue sets the value of the flag or non-flag, and notifies the observers.
+func (f *FlagSet) setValue(fl *Flag, value string) error {
+	fns := f.observers[fl.Name]
+	if len(fns) == 0 {
+		return fl.Value.Set(value)
+	}
+	old := fl.Value.String()
+	if err := fl.Value.Set(value); err != nil {
+		return err
+	}
+	notifyObservers(fns, old, fl.Value.String())
+	return nil
+}
+
+// observedValues returns the values of the observed flags, which are compared after parsing.
+func (f *FlagSet) observedValues() map[string]string {
+	if len(f.observers) == 0 {
+		return nil
+	}
+	olds := make(map[string]string, len(f.observers))
+	for name := range f.observers {
+		if fl := f.FlagSet.Lookup(name); fl != nil {
+			olds[name] = fl.Value.String()
+		}
+	}
+	return olds
+}
+
+// notifyChanged notifies the observers of the flags whose values are changed.
+func (f *FlagSet) notifyChanged(olds map[string]string) {
+	for name, old := range olds {
+		if v := f.FlagSet.Lookup(name).Value.String(); v != old {
+			notifyObservers(f.observers[name], old, v)
+		}
+	}
+}
+
+func notifyObservers(fns []func(old, new string), old, new string) {
+	if old == new {
+		return
+	}
+	for _, fn := range fns {
+		fn(old, new)
+	}
+}
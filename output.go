@@ -0,0 +1,269 @@
+package flagx
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// OutputFormat the format of the structured output printed by Context.Print
+type OutputFormat string
+
+// Output formats
+const (
+	OutputText OutputFormat = "text"
+	OutputJSON OutputFormat = "json"
+	OutputYAML OutputFormat = "yaml"
+)
+
+// outputFlagName the name of the output format flag, whose short name is "o"
+const outputFlagName = "output"
+
+// SetOutputFormat enables the `-o/--output (text|json|yaml)` flag of the command with the default format,
+// which selects how Context.Print renders the values, so that the structured output is consistent across the CLI.
+// NOTE:
+//  the flag is taken from the arguments of the command before its action parses them,
+//  so the action should not define the flags "o" or "output";
+//  set empty string to disable it;
+//  panic when the format is unknown or the command tree is frozen
+// Example:
+//  app.AddSubaction("list", "list the items", new(ListAction))
+//  app.LookupSubcommand("list").SetOutputFormat(flagx.OutputText)
+func (c *Command) SetOutputFormat(def OutputFormat) {
+	if def != "" && !def.valid() {
+		panic(fmt.Errorf("unknown output format: %s", def))
+	}
+	c.checkMutable()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.outputFormat = def
+	c.updateUsageLocked()
+}
+
+// OutputFormat returns the output format of the command set by SetOutputFormat.
+func (c *Command) OutputFormat() OutputFormat {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.outputFormat
+}
+
+// OutputFormat returns the output format selected by the -o/--output flag,
+// or OutputText if the flag is not enabled for the command.
+func (c *Context) OutputFormat() OutputFormat {
+	if c.outputFormat == "" {
+		return OutputText
+	}
+	return c.outputFormat
+}
+
+// Print renders the value to the output of the execution according to the selected output format,
+// see SetOutputFormat.
+// NOTE:
+//  the text format prints the value by fmt.Println, so implement fmt.Stringer to customize it;
+//  the yaml format renders the value as its JSON form, so the json tags apply to both;
+//  if rendering fails, panic the status with code StatusExecuteFailed.
+func (c *Context) Print(v interface{}) {
+	c.checkExecuteError(WriteOutput(c.Output(), c.OutputFormat(), v))
+}
+
+// WriteOutput writes the value in the output format, see Context.Print.
+func WriteOutput(w io.Writer, format OutputFormat, v interface{}) error {
+	switch format {
+	case OutputText, "":
+		_, err := fmt.Fprintln(w, v)
+		return err
+	case OutputJSON:
+		b, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append(b, '\n'))
+		return err
+	case OutputYAML:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber()
+		node, err := decodeYAMLNode(dec)
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		for _, line := range node.lines() {
+			buf.WriteString(line + "\n")
+		}
+		_, err = w.Write(buf.Bytes())
+		return err
+	default:
+		return fmt.Errorf("unknown output format: %s", format)
+	}
+}
+
+func (f OutputFormat) valid() bool {
+	switch f {
+	case OutputText, OutputJSON, OutputYAML:
+		return true
+	}
+	return false
+}
+
+// outputFlagObject returns the flag object of the output format flag for the usage.
+func (c *Command) outputFlagObject() *Flag {
+	if c.outputFormat == "" || c.action == nil {
+		return nil
+	}
+	return &Flag{Name: outputFlagName, Usage: "the output `format` (short -o): text, json or yaml", Value: newStringValue(string(c.outputFormat), new(string)), DefValue: string(c.outputFormat)}
+}
+
+// isOutputFlag reports whether the name is the name or the short name of the output format flag.
+func isOutputFlag(name string) bool {
+	return name == outputFlagName || name == "o"
+}
+
+// extractOutputFlag returns the output format specified by the -o/--output flag and the rest arguments.
+// NOTE:
+//  only the flags before the first non-flag are scanned, and the values of the flags of @flagSet are skipped,
+//  such as `-name -o` or `host -o ProxyJump=x`, which are left to the action.
+func (snap *execSnapshot) extractOutputFlag(def OutputFormat, flagSet *FlagSet, arguments []string) (OutputFormat, []string) {
+	format := def
+	args := make([]string, 0, len(arguments))
+	for i := 0; i < len(arguments); i++ {
+		arg := arguments[i]
+		if arg == "--" || len(arg) < 2 || arg[0] != '-' {
+			args = append(args, arguments[i:]...)
+			break
+		}
+		name := strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+		name, value, hasValue := strings.Cut(name, "=")
+		if !isOutputFlag(name) {
+			args = append(args, arg)
+			if fl := flagSet.Lookup(name); !hasValue && fl != nil && !isBoolFlag(fl) && i+1 < len(arguments) {
+				i++
+				args = append(args, arguments[i])
+			}
+			continue
+		}
+		if !hasValue {
+			if i+1 >= len(arguments) {
+				snap.throwStatus(StatusBadArgs, "", fmt.Sprintf("flag needs an argument: -%s", name))
+			}
+			i++
+			value = arguments[i]
+		}
+		format = OutputFormat(value)
+		if !format.valid() {
+			snap.throwStatus(StatusBadArgs, "", fmt.Sprintf("unknown output format: %s", value))
+		}
+	}
+	return format, args
+}
+
+// yamlNode the ordered tree of the value rendered as YAML
+type yamlNode struct {
+	scalar string      // The rendered scalar, if it is not a collection
+	isMap  bool        // The mapping
+	isSeq  bool        // The sequence
+	keys   []string    // The rendered keys of the mapping
+	items  []*yamlNode // The values of the mapping or sequence
+}
+
+// decodeYAMLNode decodes the next JSON value keeping the order of the object keys.
+func decodeYAMLNode(dec *json.Decoder) (*yamlNode, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		n := &yamlNode{isMap: t == '{', isSeq: t == '['}
+		for dec.More() {
+			if n.isMap {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				n.keys = append(n.keys, yamlString(key.(string)))
+			}
+			item, err := decodeYAMLNode(dec)
+			if err != nil {
+				return nil, err
+			}
+			n.items = append(n.items, item)
+		}
+		// the closing delimiter
+		_, err = dec.Token()
+		return n, err
+	case string:
+		return &yamlNode{scalar: yamlString(t)}, nil
+	case json.Number:
+		return &yamlNode{scalar: t.String()}, nil
+	case bool:
+		return &yamlNode{scalar: strconv.FormatBool(t)}, nil
+	default:
+		return &yamlNode{scalar: "null"}, nil
+	}
+}
+
+// inline returns the node in a single line, which is a scalar or an empty collection.
+func (n *yamlNode) inline() string {
+	switch {
+	case n.isMap:
+		return "{}"
+	case n.isSeq:
+		return "[]"
+	}
+	return n.scalar
+}
+
+// lines returns the lines of the node in the block style.
+func (n *yamlNode) lines() []string {
+	if len(n.items) == 0 {
+		return []string{n.inline()}
+	}
+	var lines []string
+	for i, item := range n.items {
+		var prefix string
+		if n.isMap {
+			prefix = n.keys[i] + ":"
+		} else {
+			prefix = "-"
+		}
+		if len(item.items) == 0 {
+			lines = append(lines, prefix+" "+item.inline())
+			continue
+		}
+		sub := item.lines()
+		if n.isMap {
+			lines = append(lines, prefix)
+			for _, line := range sub {
+				lines = append(lines, "  "+line)
+			}
+			continue
+		}
+		lines = append(lines, prefix+" "+sub[0])
+		for _, line := range sub[1:] {
+			lines = append(lines, "  "+line)
+		}
+	}
+	return lines
+}
+
+// yamlString returns the plain string, or the double-quoted one if it would be ambiguous in YAML.
+func yamlString(s string) string {
+	switch strings.ToLower(s) {
+	case "", "~", "null", "true", "false", "yes", "no", "on", "off", "y", "n":
+		return strconv.Quote(s)
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil ||
+		strings.TrimSpace(s) != s ||
+		strings.ContainsAny(s, ":#{}[],&*!|>'\"%@`\\\n\t") ||
+		strings.HasPrefix(s, "-") || strings.HasPrefix(s, "?") {
+		return strconv.Quote(s)
+	}
+	return s
+}
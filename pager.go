@@ -0,0 +1,86 @@
+package flagx
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// SetUsePager sets whether to pipe the long usage printed by PrintUsage through the pager.
+// NOTE:
+//  the pager is $PAGER, defaults to less;
+//  it is only used if stdout is a terminal and the usage exceeds the terminal height.
+func (a *App) SetUsePager(use bool) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.usePager = use
+}
+
+// PrintUsage prints the usage text by the executor scope to the output, see SetOutput.
+// NOTE:
+//  if @scopes is empty, all command usage are printed.
+func (a *App) PrintUsage(execScope ...Scope) {
+	text := a.UsageText(execScope...)
+	a.lock.RLock()
+	usePager := a.usePager
+	output := a.output
+	a.lock.RUnlock()
+	if output == nil {
+		if usePager && needPager(os.Stdout, text) && runPager(text) == nil {
+			return
+		}
+		output = os.Stdout
+	}
+	fmt.Fprint(output, text)
+}
+
+// SetOutput sets the destination of the messages of the app, including the parse errors and
+// the usage printed by the flag sets of the filters and actions, and the usage printed by PrintUsage,
+// so that all of them can be captured or silenced uniformly.
+// NOTE:
+//  defaults to nil, where the flag sets print to the standard error,
+//  and PrintUsage prints to the standard output through the pager if enabled;
+//  the pager is not used if the output is set;
+//  in the ColorAuto mode, the usage is colored only if the output is a terminal.
+func (a *App) SetOutput(w io.Writer) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.output = w
+	if colored := a.colorMode.enabled(a.usageOutputLocked()); colored != a.colored {
+		a.colored = colored
+		a.resetUsageLocked()
+	}
+}
+
+// needPager reports whether the text exceeds the height of the terminal.
+func needPager(f *os.File, text string) bool {
+	if !isTerminal(f) {
+		return false
+	}
+	height := terminalHeight(f)
+	return height > 0 && strings.Count(text, "\n") >= height
+}
+
+// runPager pipes the text through the pager.
+// NOTE:
+//  returns error only if the pager cannot be started.
+func runPager(text string) error {
+	pager := strings.Fields(os.Getenv("PAGER"))
+	if len(pager) == 0 {
+		pager = []string{"less"}
+	}
+	cmd := exec.Command(pager[0], pager[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if _, ok := os.LookupEnv("LESS"); !ok {
+		cmd.Env = append(os.Environ(), "LESS=FRX")
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	cmd.Wait()
+	return nil
+}
@@ -0,0 +1,17 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package flagx
+
+import (
+	"os"
+	"strconv"
+)
+
+// terminalHeight returns the number of rows of the terminal, or 0 if unknown.
+func terminalHeight(f *os.File) int {
+	if n, err := strconv.Atoi(os.Getenv("LINES")); err == nil && n > 0 {
+		return n
+	}
+	return 0
+}
@@ -0,0 +1,433 @@
+package flagx
+
+import (
+	"errors"
+	"log/slog"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ParseMode the opt-in behaviors of parsing the command-line arguments, which can be combined
+type ParseMode uint32
+
+// PositionalMode how the arguments after the flags are parsed
+type PositionalMode uint8
+
+// Positional modes
+const (
+	// PositionalNonFlag the flags stop at the first positional argument, and the positional arguments
+	// are parsed as the non-flags, such as ?0 and ?1.
+	PositionalNonFlag PositionalMode = iota
+	// PositionalClassic the flags stop at the first positional argument like the standard flag package,
+	// and the non-flags are not parsed.
+	PositionalClassic
+	// PositionalPermute the flags can be mixed with the positional arguments until "--" like GNU getopt,
+	// and the positional arguments are parsed as the non-flags.
+	PositionalPermute
+)
+
+// SetPositionalMode sets how the arguments after the flags are parsed.
+// NOTE:
+//  defaults to PositionalNonFlag;
+//  the actions of an app can choose their own mode in FlagDefiner.
+func (f *FlagSet) SetPositionalMode(mode PositionalMode) {
+	f.positionalMode = mode
+}
+
+// PositionalMode returns how the arguments after the flags are parsed.
+func (f *FlagSet) PositionalMode() PositionalMode {
+	return f.positionalMode
+}
+
+// Parse modes
+const (
+	// ParseMultiValue a slice flag consumes the consecutive non-flag arguments until the next flag or "--",
+	// such as -files a.txt b.txt, see SliceValue.
+	ParseMultiValue ParseMode = 1 << iota
+	// ParseAttachedValue the value of a one-letter flag can be attached to it with no separator,
+	// such as -n5 and -ofile, which are parsed as -n=5 and -o=file.
+	// NOTE:
+	//  a defined flag with the whole name wins, so -no is the flag "no" if it exists;
+	//  the bool flags can not be clustered, so a bool letter is never split, such as -vfile.
+	ParseAttachedValue
+	// ParseNegativeNumber an argument like -5 or -1.5 is a non-flag or a flag value rather than a flag,
+	// unless a flag with the name is defined, such as the offsets and the deltas.
+	ParseNegativeNumber
+	// ParseKeyValue a key=value non-flag sets the flag with the key, like make VAR=value,
+	// and all of them can be got by KeyValues.
+	// NOTE:
+	//  the key=value non-flags are kept in Args, and they override the flags set before them;
+	//  the key consists of the letters, digits, '_', '-' and '.', so the paths like ./a=b are not matched;
+	//  the non-flags after "--" are not matched.
+	ParseKeyValue
+	// ParseStrictBool a bool flag can only be set as -flag, -flag=true or -flag=false,
+	// and -flag true or -flag false is rejected, which leaves "true" as a stray non-flag by default,
+	// while the other values like -flag 1 are left as the non-flags.
+	ParseStrictBool
+	// ParseNoDuplicate a flag that is not a slice flag can not be provided twice,
+	// which is reported by the diagnostics logger rather than rejected by default, and the last one wins.
+	ParseNoDuplicate
+	// ParseAbbreviation a unique prefix of a flag name resolves to the flag, such as -tim for -timeout,
+	// and an ambiguous prefix is an error listing the candidates.
+	// NOTE:
+	//  a defined flag with the name wins;
+	//  set by App.SetParseMode, a unique prefix of a subcommand name also resolves to the subcommand,
+	//  and a filter may take the flag of its subcommand which is a prefix of the filter flag.
+	ParseAbbreviation
+	// ParseAllErrors the parsing continues after a bad flag or non-flag, and all the errors are
+	// returned together, so that the whole command line can be fixed in one pass.
+	// NOTE:
+	//  the errors can be checked by errors.As, such as *ErrUndefinedFlag and *ErrInvalidValue;
+	//  set by App.SetParseMode, the error of the validator is also returned together;
+	//  the errors of the other parse modes, such as an ambiguous abbreviation, still stop the parsing.
+	ParseAllErrors
+)
+
+// SetParseMode sets the opt-in behaviors of parsing the arguments.
+// NOTE:
+//  defaults to 0, which parses the arguments like the standard flag package.
+// Example:
+//  fs.SetParseMode(flagx.ParseMultiValue)
+func (f *FlagSet) SetParseMode(mode ParseMode) {
+	f.parseMode = mode
+}
+
+// ParseMode returns the opt-in behaviors of parsing the arguments.
+func (f *FlagSet) ParseMode() ParseMode {
+	return f.parseMode
+}
+
+// SetParseMode sets the opt-in behaviors of parsing the arguments of all the commands,
+// see FlagSet.SetParseMode.
+// NOTE:
+//  the filters parse the arguments of the subcommands too, so a slice flag of a filter
+//  may consume the subcommand name, end its values with "--" or put it after the subcommand.
+func (a *App) SetParseMode(mode ParseMode) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.parseMode = mode
+}
+
+// tidyParseModes rewrites the arguments in the forms enabled by the parse modes to
+// the standard -name=value form, and moves the flags before the non-flags under PositionalPermute.
+func (f *FlagSet) tidyParseModes(arguments []string) ([]string, error) {
+	r := make([]string, 0, len(arguments)+4)
+	var positionals []string
+	permute := f.positionalMode == PositionalPermute
+	i := 0
+	for ; i < len(arguments); i++ {
+		s := arguments[i]
+		if !f.isFlagArg(s) {
+			if permute {
+				positionals = append(positionals, s)
+				continue
+			}
+			if isFlagArg(s) {
+				// the negative number is the first non-flag, which is not parsed as a flag after "--"
+				r = append(r, "--")
+				f.implicitTerminator = true
+			}
+			break
+		}
+		if s == "--" {
+			if permute {
+				i++
+			}
+			break
+		}
+		name := strings.TrimPrefix(s[1:], "-")
+		if f.parseMode&ParseAbbreviation != 0 {
+			full, err := f.expandAbbreviation(name)
+			if err != nil {
+				return nil, err
+			}
+			s, name = s[:len(s)-len(name)]+full, full
+		}
+		if strings.IndexByte(name, '=') > 0 {
+			r = append(r, s)
+			continue
+		}
+		fl := f.FlagSet.Lookup(name)
+		if fl == nil && f.parseMode&ParseAttachedValue != 0 && s[1] != '-' {
+			if letter, value, ok := f.splitAttachedValue(name); ok {
+				r = append(r, "-"+letter+"="+value)
+				continue
+			}
+		}
+		if fl != nil && isBoolFlag(fl) && f.parseMode&ParseStrictBool != 0 && i+1 < len(arguments) {
+			// only the literal spellings are rejected, "1" or "t" may be a non-flag like a file name
+			if v := strings.ToLower(arguments[i+1]); v == "true" || v == "false" {
+				return nil, f.failf("bool flag %s does not take a separate value %q, use %s=%s", s, arguments[i+1], s, arguments[i+1])
+			}
+		}
+		n := f.valueArgs(fl, arguments[i+1:])
+		if n > 0 && fl != nil && isSliceFlag(fl) && f.parseMode&ParseMultiValue != 0 {
+			for _, value := range arguments[i+1 : i+1+n] {
+				r = append(r, "-"+name+"="+value)
+			}
+		} else if n > 0 && isFlagArg(arguments[i+1]) {
+			// keep the value like -5 with the flag, which is not split when tidying
+			r = append(r, "-"+name+"="+arguments[i+1])
+		} else {
+			r = append(append(r, s), arguments[i+1:i+1+n]...)
+		}
+		i += n
+	}
+	if permute && len(positionals)+len(arguments)-i > 0 {
+		// the non-flags are parsed after the implicit "--"
+		r = append(append(r, "--"), positionals...)
+		f.implicitTerminator = true
+	}
+	return append(r, arguments[i:]...), nil
+}
+
+// expandAbbreviation returns the name with the flag name expanded from its unique prefix,
+// the name may have the value, such as tim=5s.
+func (f *FlagSet) expandAbbreviation(name string) (string, error) {
+	prefix, value, hasValue := strings.Cut(name, "=")
+	if prefix == "" || f.FlagSet.Lookup(prefix) != nil {
+		return name, nil
+	}
+	var candidates []*Flag
+	f.FlagSet.VisitAll(func(fl *Flag) {
+		if !strings.HasPrefix(fl.Name, prefix) {
+			return
+		}
+		for _, c := range candidates {
+			if c.Value == fl.Value { // the aliases of one flag
+				return
+			}
+		}
+		candidates = append(candidates, fl)
+	})
+	switch len(candidates) {
+	case 0:
+		return name, nil
+	case 1:
+		if hasValue {
+			return candidates[0].Name + "=" + value, nil
+		}
+		return candidates[0].Name, nil
+	}
+	names := make([]string, len(candidates))
+	for i, c := range candidates {
+		names[i] = "-" + c.Name
+	}
+	return "", f.failf("ambiguous flag -%s, candidates: %s", prefix, strings.Join(names, ", "))
+}
+
+// valueArgs returns the number of the following arguments taken as the values of the flag,
+// the flag is nil if it is not defined.
+func (f *FlagSet) valueArgs(fl *Flag, following []string) int {
+	if len(following) == 0 || fl != nil && isBoolFlag(fl) {
+		return 0
+	}
+	if fl == nil || !isSliceFlag(fl) || f.parseMode&ParseMultiValue == 0 {
+		// the value of the flag is the next argument
+		if fl != nil || !f.isFlagArg(following[0]) {
+			return 1
+		}
+		return 0
+	}
+	n := 0
+	for n < len(following) && !f.isFlagArg(following[n]) {
+		n++
+	}
+	return n
+}
+
+// checkDuplicates reports the flags that are provided more than once, except the slice flags.
+func (f *FlagSet) checkDuplicates(arguments []string) error {
+	var seen map[string]bool
+	var dup string
+	filterArgs(arguments, f.FlagSet.Lookup, func(name string, _ *string, _ []string) bool {
+		fl := f.FlagSet.Lookup(name)
+		if fl == nil || isSliceFlag(fl) {
+			return true
+		}
+		if seen == nil {
+			seen = make(map[string]bool, 8)
+		}
+		if !seen[name] {
+			seen[name] = true
+			return true
+		}
+		if f.parseMode&ParseNoDuplicate != 0 {
+			dup = name
+			return false
+		}
+		f.diagnose(slog.LevelWarn, "flagx: duplicate flag, the last one wins", slog.String("flag", name))
+		return true
+	})
+	if dup != "" {
+		return f.failf("flag provided more than once: -%s", dup)
+	}
+	return nil
+}
+
+// parseAll parses the arguments like the standard flag set, but collects the errors of all the
+// flags and non-flags rather than stopping at the first one.
+func (f *FlagSet) parseAll(arguments []string) error {
+	var errs []error
+	f.collecting = true
+	defer func() { f.collecting = false }()
+	lastArgs := arguments
+	var terminated bool
+	for {
+		var err error
+		lastArgs, terminated, err = filterArgs(lastArgs, f.FlagSet.Lookup, func(name string, valuePtr *string, _ []string) bool {
+			fl := f.FlagSet.Lookup(name)
+			switch {
+			case fl != nil && valuePtr != nil:
+				if err := f.setFlag(name, *valuePtr); err != nil {
+					errs = append(errs, &ErrInvalidValue{Name: name, Value: *valuePtr, Err: err})
+				}
+			case fl != nil && isBoolFlag(fl):
+				f.setFlag(name, "true")
+			case fl != nil:
+				errs = append(errs, &ErrNeedsArgument{Name: name})
+			case name == "help" || name == "h":
+				errs = append(errs, ErrHelp)
+			case f.isContinueOnUndefined:
+				f.ignoreFlag(name)
+			default:
+				errs = append(errs, &ErrUndefinedFlag{Name: name, Suggestions: f.SuggestFlags(name)})
+			}
+			return true
+		})
+		if err == nil {
+			break
+		}
+		// skip the bad flag syntax
+		errs = append(errs, err)
+		lastArgs = lastArgs[1:]
+	}
+	for _, err := range errs {
+		if err == ErrHelp {
+			f.collecting = false
+			if !f.quiet {
+				f.usage()
+			}
+			return f.handleError(ErrHelp)
+		}
+	}
+	// the standard flag set only records the arguments after the flags
+	f.FlagSet.Parse(append([]string{"--"}, lastArgs...))
+	f.terminated = terminated && !f.implicitTerminator
+	if !f.terminated {
+		for k, v := range f.Args() {
+			if f.positionalMode == PositionalClassic {
+				break
+			}
+			seen, err := f.parseOneNonFlag(k, v)
+			if err != nil {
+				errs = append(errs, err)
+			}
+			if !seen && err == nil {
+				break
+			}
+		}
+		if f.parseMode&ParseKeyValue != 0 {
+			if err := f.parseKeyValues(f.Args()); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	f.collecting = false
+	return f.handleError(f.fail(errors.Join(errs...)))
+}
+
+// KeyValues returns the key=value non-flags parsed under ParseKeyValue mode,
+// including the ones whose key is not a defined flag.
+func (f *FlagSet) KeyValues() map[string]string {
+	return f.keyValues
+}
+
+// parseKeyValues sets the flags from the key=value non-flags.
+func (f *FlagSet) parseKeyValues(args []string) error {
+	var errs []error
+	for _, arg := range args {
+		if arg == "--" {
+			break
+		}
+		key, value, ok := cutKeyValue(arg)
+		if !ok {
+			continue
+		}
+		if f.keyValues == nil {
+			f.keyValues = make(map[string]string, 4)
+		}
+		f.keyValues[key] = value
+		if f.FlagSet.Lookup(key) == nil {
+			continue
+		}
+		if err := f.setFlag(key, value); err != nil {
+			err = f.fail(&ErrInvalidValue{Name: key, Value: value, Err: err})
+			if !f.collecting {
+				return err
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// cutKeyValue splits the key=value argument.
+func cutKeyValue(arg string) (key, value string, ok bool) {
+	key, value, ok = strings.Cut(arg, "=")
+	if !ok || key == "" || key[0] == '-' {
+		return "", "", false
+	}
+	for _, r := range key {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-' || r == '.') {
+			return "", "", false
+		}
+	}
+	return key, value, true
+}
+
+// isFlagArg reports whether the argument looks like a flag or "--".
+func isFlagArg(s string) bool {
+	return len(s) >= 2 && s[0] == '-'
+}
+
+// isFlagArg reports whether the argument is a flag or "--" under the parse modes.
+func (f *FlagSet) isFlagArg(s string) bool {
+	if !isFlagArg(s) {
+		return false
+	}
+	if f.parseMode&ParseNegativeNumber == 0 || !isNumberArg(s[1:]) {
+		return true
+	}
+	return f.FlagSet.Lookup(s[1:]) != nil
+}
+
+// isNumberArg reports whether the argument is a decimal or hexadecimal number, such as 5, 1.5 and 0x10.
+func isNumberArg(s string) bool {
+	if s == "" || (s[0] < '0' || s[0] > '9') && s[0] != '.' {
+		return false
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return true
+	}
+	_, err := strconv.ParseInt(s, 0, 64)
+	return err == nil
+}
+
+// splitAttachedValue splits the name like "n5" into the defined one-letter flag and its value.
+func (f *FlagSet) splitAttachedValue(name string) (letter, value string, ok bool) {
+	_, size := utf8.DecodeRuneInString(name)
+	if size >= len(name) {
+		return "", "", false
+	}
+	letter = name[:size]
+	fl := f.FlagSet.Lookup(letter)
+	if fl == nil || isBoolFlag(fl) {
+		return "", "", false
+	}
+	return letter, name[size:], true
+}
@@ -0,0 +1,38 @@
+package flagx
+
+import (
+	"os"
+	"os/exec"
+)
+
+// SetExternalCommands sets whether to resolve the unknown top-level subcommands
+// to the executables named `<cmdname>-<subcommand>` on PATH (git-style plugins).
+// NOTE:
+//  the remaining arguments, standard input, standard error and environment are forwarded to the executable,
+//  and its standard output is written to the output of the execution, see Context.Output.
+func (a *App) SetExternalCommands(enable bool) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.externalCommands = enable
+}
+
+// lookupExternalAction returns the action running the external executable of the subcommand.
+// NOTE:
+//  returns nil if it does not exist.
+func (c *Command) lookupExternalAction(snap *execSnapshot, subCmdName string, arguments []string) ActionFunc {
+	if c.parent != nil || subCmdName == "" || !snap.externalCommands {
+		return nil
+	}
+	path, err := exec.LookPath(c.cmdName + "-" + subCmdName)
+	if err != nil {
+		return nil
+	}
+	return func(ctx *Context) {
+		cmd := exec.CommandContext(ctx, path, arguments...)
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = ctx.Output()
+		cmd.Stderr = os.Stderr
+		cmd.Env = snap.environList()
+		ctx.CheckStatus(cmd.Run(), StatusExternalFailed, "")
+	}
+}
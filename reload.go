@@ -0,0 +1,262 @@
+package flagx
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+type (
+	// ReloadFunc is notified with the names of the changed flags of a running execution after reloading
+	ReloadFunc func(c *Context, changed []string)
+	// flagBinding a parsed flag set of an execution
+	flagBinding struct {
+		cmd     *Command
+		flagSet *FlagSet
+		cmdline map[string]bool // The flags set on the command line, which are not reloaded
+	}
+	// reloadState the running executions that can be reloaded
+	reloadState struct {
+		running   map[*Context]struct{}
+		lock      sync.Mutex
+		reloading sync.Mutex
+	}
+)
+
+// EnableReload reloads the flag values of the running executions when the signals are received,
+// and returns the function to stop it.
+// NOTE:
+//  if @sig is empty, syscall.SIGHUP is used;
+//  see Reload.
+func (a *App) EnableReload(sig ...os.Signal) (stop func()) {
+	if len(sig) == 0 {
+		sig = []os.Signal{syscall.SIGHUP}
+	}
+	a.lock.Lock()
+	a.reloadEnabled = true
+	a.lock.Unlock()
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, sig...)
+	go func() {
+		for {
+			select {
+			case <-ch:
+				a.Reload()
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+			a.lock.Lock()
+			a.reloadEnabled = false
+			a.lock.Unlock()
+		})
+	}
+}
+
+// OnReload adds the function that is notified after reloading when any flag value changes.
+func (a *App) OnReload(fn ReloadFunc) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.reloadFuncs = append(a.reloadFuncs, fn)
+}
+
+// Reload re-reads the environment variables, remote source and config file, and applies the
+// changed values to the flags of the running executions through flag.Value.Set.
+// NOTE:
+//  only the executions started after EnableReload are reloaded;
+//  the flags set on the command line are not reloaded, and the unset ones are reset to the default;
+//  the values are set in the calling goroutine, the action should synchronize the access to them,
+//  such as in the function added by OnReload.
+func (a *App) Reload() {
+	a.reload.reloading.Lock()
+	defer a.reload.reloading.Unlock()
+	a.lock.RLock()
+	fns := a.reloadFuncs
+	a.lock.RUnlock()
+	a.reload.lock.Lock()
+	running := make([]*Context, 0, len(a.reload.running))
+	for c := range a.reload.running {
+		running = append(running, c)
+	}
+	a.reload.lock.Unlock()
+	for _, c := range running {
+		changed := c.snap.reloadBindings(c)
+		if len(changed) == 0 {
+			continue
+		}
+		for _, fn := range fns {
+			fn(c, changed)
+		}
+	}
+}
+
+func (a *App) trackReload(c *Context) {
+	a.reload.lock.Lock()
+	defer a.reload.lock.Unlock()
+	if a.reload.running == nil {
+		a.reload.running = make(map[*Context]struct{}, 16)
+	}
+	a.reload.running[c] = struct{}{}
+}
+
+func (a *App) untrackReload(c *Context) {
+	a.reload.lock.Lock()
+	defer a.reload.lock.Unlock()
+	delete(a.reload.running, c)
+}
+
+// bind records the flag set of the command that has been parsed from the command line,
+// which is used by the snapshot and reloading.
+func (snap *execSnapshot) bind(c *Command, flagSet *FlagSet) {
+	var cmdline map[string]bool
+	if snap.reloadEnabled {
+		cmdline = make(map[string]bool, 8)
+		flagSet.FlagSet.Visit(func(f *Flag) {
+			cmdline[f.Name] = true
+		})
+	}
+	snap.bindings = append(snap.bindings, &flagBinding{cmd: c, flagSet: flagSet, cmdline: cmdline})
+}
+
+// reloadBindings applies the reloaded values to the bound flag sets, and returns the names of the changed flags.
+func (snap *execSnapshot) reloadBindings(c *Context) []string {
+	config := snap.config
+	if snap.configFile != "" {
+		values, err := loadConfigFile(snap.fsys, snap.configFile, snap.configFileDecoder)
+		if err != nil {
+			c.Logger().Error("flagx: reload config file", "file", snap.configFile, "error", err)
+		} else {
+			config = values
+		}
+	}
+	var changed []string
+	seen := make(map[string]bool, 8)
+	for _, b := range snap.bindings {
+		var prefix string
+		if p := b.cmd.Path(); len(p) > 1 {
+			prefix = strings.Join(p[1:], ".") + "."
+		}
+		b.flagSet.FlagSet.VisitAll(func(f *Flag) {
+			if b.cmdline[f.Name] {
+				return
+			}
+			o, ok := snap.reloadValue(b.cmd, f, prefix, config)
+			if !ok {
+				return
+			}
+			raw := o.raw
+			value := raw
+			if snap.expander != nil && isStringFlag(f) {
+				value = os.Expand(raw, snap.expander)
+			}
+			if snap.secretResolver != nil && value != "" && b.flagSet.IsSecret(f.Name) {
+				var err error
+				if value, err = snap.secretResolver.Resolve(value); err != nil {
+					c.Logger().Error("flagx: reload secret flag", "flag", f.Name, "error", err)
+					return
+				}
+			}
+			if value == f.Value.String() {
+				return
+			}
+			if err := b.flagSet.setReloadedValue(f, value); err != nil {
+				c.Logger().Error("flagx: reload flag", "flag", f.Name, "value", value, "error", err)
+				return
+			}
+			b.flagSet.setOrigin(f.Name, o.source, raw, o.from)
+			if !seen[f.Name] {
+				seen[f.Name] = true
+				changed = append(changed, f.Name)
+			}
+		})
+	}
+	snap.config = config
+	return changed
+}
+
+// reloadValue returns the origin of the flag value by the precedence
+// environment variable > remote source > config file > defaults provider > default.
+// NOTE:
+//  reports false if the remote source fails.
+func (snap *execSnapshot) reloadValue(cmd *Command, f *Flag, prefix string, config map[string]string) (valueOrigin, bool) {
+	if snap.envPrefix != "" {
+		key := envName(snap.envPrefix, cmd.Path(), f.Name)
+		if value, ok := snap.lookupEnv(key); ok {
+			return valueOrigin{source: SourceEnv, raw: value, from: key}, true
+		}
+	}
+	if snap.remoteSource != nil {
+		key := prefix + f.Name
+		value, ok, err := snap.lookupRemote(key)
+		if err == nil && !ok && prefix != "" {
+			key = f.Name
+			value, ok, err = snap.lookupRemote(key)
+		}
+		if err != nil {
+			return valueOrigin{source: SourceRemote}, false
+		}
+		if ok {
+			return valueOrigin{source: SourceRemote, raw: value, from: key}, true
+		}
+	}
+	if value, ok := lookupConfig(config, f.Name, []string{prefix}); ok {
+		return valueOrigin{source: SourceConfig, raw: value, from: snap.configFile}, true
+	}
+	if value, ok := lookupDefaults(snap.defaultsProviders, f.Name, []string{prefix}); ok {
+		return valueOrigin{source: SourceProvider, raw: value}, true
+	}
+	return valueOrigin{source: SourceDefault, raw: f.DefValue}, true
+}
+
+// setReloadedValue replaces the value of the flag or non-flag by the reloaded one, and notifies the observers.
+// NOTE:
+//  the slice flag is reset before its elements are set one by one, so that they are not appended
+//  to the previous ones, see setJoinedFlag.
+func (f *FlagSet) setReloadedValue(fl *Flag, value string) error {
+	if !isSliceFlag(fl) {
+		return f.setValue(fl, value)
+	}
+	old, oldElems := fl.Value.String(), sliceElements(fl)
+	resetValue(fl)
+	if value != "" {
+		for _, elem := range strings.Split(value, ",") {
+			if err := fl.Value.Set(elem); err != nil {
+				resetValue(fl)
+				for _, elem := range oldElems {
+					fl.Value.Set(elem)
+				}
+				return err
+			}
+		}
+	}
+	notifyObservers(f.observers[fl.Name], old, fl.Value.String())
+	return nil
+}
+
+// sliceElements returns the elements of the slice flag, which are kept as they are even if they contain commas.
+func sliceElements(fl *Flag) []string {
+	if getter, ok := fl.Value.(Getter); ok {
+		if v := reflect.ValueOf(getter.Get()); v.Kind() == reflect.Slice {
+			elems := make([]string, v.Len())
+			for i := range elems {
+				elems[i] = fmt.Sprint(v.Index(i).Interface())
+			}
+			return elems
+		}
+	}
+	if s := fl.Value.String(); s != "" {
+		return strings.Split(s, ",")
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package flagx
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type (
+	// RemoteSource the remote provider of the flag values, such as etcd, Consul or parameter stores
+	RemoteSource interface {
+		// Get returns the value of the key, and reports whether it exists.
+		Get(key string) (string, bool)
+	}
+	// RemoteContextSource an optional interface of RemoteSource, if implemented, GetContext is called
+	// instead of Get with the context that is canceled on timeout, and the error is handled by the policy
+	RemoteContextSource interface {
+		GetContext(ctx context.Context, key string) (string, bool, error)
+	}
+	// RemoteErrorPolicy the policy when the remote source fails or times out
+	RemoteErrorPolicy int8
+)
+
+// Remote error policies
+const (
+	RemoteFallback RemoteErrorPolicy = iota // Fall back to the config file and default value (default)
+	RemoteFail                              // Fail the execution with code StatusConfigFailed
+)
+
+// SetRemoteSource sets the remote source of the flag values of all the commands.
+// NOTE:
+//  the keys are the same as the config file, a flag of a command is looked up by
+//  the key prefixed with its path first, such as "sub.name", then by the flag name;
+//  the precedence is command line > environment variable > remote source > config file > default;
+//  @timeout limits every lookup, 0 means no limit;
+//  set nil to disable it.
+func (a *App) SetRemoteSource(src RemoteSource, timeout time.Duration, policy RemoteErrorPolicy) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.remoteSource = src
+	a.remoteTimeout = timeout
+	a.remotePolicy = policy
+}
+
+// applyRemote sets the flags of the command that are not set on the command line by the remote source.
+func (snap *execSnapshot) applyRemote(c *Command, flagSet *FlagSet) error {
+	if snap.remoteSource == nil {
+		return nil
+	}
+	var prefix string
+	if p := c.Path(); len(p) > 1 {
+		prefix = strings.Join(p[1:], ".") + "."
+	}
+	for _, name := range flagSet.unsetFlagNames() {
+		key := prefix + name
+		value, ok, err := snap.lookupRemote(key)
+		if err == nil && !ok && prefix != "" {
+			key = name
+			value, ok, err = snap.lookupRemote(key)
+		}
+		if err != nil {
+			if snap.remotePolicy == RemoteFail {
+				return err
+			}
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if err = flagSet.setFlag(name, value); err != nil {
+			return fmt.Errorf("invalid value %q for flag -%s from remote source: %v", value, name, err)
+		}
+		flagSet.setOrigin(name, SourceRemote, value, key)
+	}
+	return nil
+}
+
+// lookupRemote gets the value of the key from the remote source within the timeout.
+func (snap *execSnapshot) lookupRemote(key string) (string, bool, error) {
+	ctx := snap.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if snap.remoteTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, snap.remoteTimeout)
+		defer cancel()
+	}
+	type result struct {
+		value string
+		ok    bool
+		err   error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		var r result
+		if src, ok := snap.remoteSource.(RemoteContextSource); ok {
+			r.value, r.ok, r.err = src.GetContext(ctx, key)
+		} else {
+			r.value, r.ok = snap.remoteSource.Get(key)
+		}
+		ch <- r
+	}()
+	select {
+	case r := <-ch:
+		if r.err != nil {
+			return "", false, fmt.Errorf("flagx: get %q from remote source: %v", key, r.err)
+		}
+		return r.value, r.ok, nil
+	case <-ctx.Done():
+		return "", false, fmt.Errorf("flagx: get %q from remote source: %v", key, ctx.Err())
+	}
+}
@@ -0,0 +1,48 @@
+package flagx
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+)
+
+// Result the structured result of the execution, see ExecResult
+type Result struct {
+	CmdPath []string    // The resolved command path, including the app command name
+	Action  interface{} // The parsed action object, such as *MyAction, or the ActionFunc; nil if not found
+	Output  string      // The output captured from the flag sets and Context.Output
+	Status  *Status
+}
+
+// ExecResult executes the command like Exec, and returns the structured result,
+// so that the tests and the embedders can check the parsed values instead of the printed text.
+// NOTE:
+//  the error is the cause of the status if it is not OK;
+//  the output set by SetOutput is replaced by the captured one during the execution;
+//  the pooled action objects are not recycled, so that they can be returned.
+func (a *App) ExecResult(ctx context.Context, arguments []string, execScope ...Scope) (*Result, error) {
+	var output bytes.Buffer
+	result := new(Result)
+	result.Status = a.Command.exec(ctx, arguments, &execResult{result: result, output: &output}, execScope...)
+	result.Output = output.String()
+	if !result.Status.OK() {
+		return result, result.Status.Cause()
+	}
+	return result, nil
+}
+
+// execResult collects the result of the execution of ExecResult
+type execResult struct {
+	result *Result
+	output *bytes.Buffer
+}
+
+// Output returns the writer of the output of the execution, which is the captured one of ExecResult,
+// the one set by SetOutput, or os.Stdout by default.
+func (c *Context) Output() io.Writer {
+	if c.snap != nil && c.snap.output != nil {
+		return c.snap.output
+	}
+	return os.Stdout
+}
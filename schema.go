@@ -0,0 +1,104 @@
+package flagx
+
+import "time"
+
+// JSONSchema returns the JSON Schema of the flags and non-flags, which can be marshaled by encoding/json,
+// so that the web frontends can render the forms of them.
+// NOTE:
+//  the non-flags are keyed by their names, such as "?0", and marked by "x-nonflag";
+//  the secret flags are marked by "writeOnly" and the "password" format;
+//  the durations are strings in the "duration" format, such as "1m30s".
+func (f *FlagSet) JSONSchema() map[string]interface{} {
+	properties := make(map[string]interface{}, 16)
+	visit := func(nonFlag bool) func(*Flag) {
+		return func(fl *Flag) {
+			properties[fl.Name] = flagSchema(f, fl, nonFlag)
+		}
+	}
+	f.VisitAll(visit(false))
+	f.NonVisitAll(visit(true))
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+}
+
+// JSONSchema returns the JSON Schema of the options of the command, which are the flags and non-flags
+// of its filters and action, see FlagSet.JSONSchema.
+// NOTE:
+//  the options of the parent commands are not included;
+//  the lazy command that is not loaded has no options.
+func (c *Command) JSONSchema() map[string]interface{} {
+	properties := make(map[string]interface{}, 16)
+	c.lock.RLock()
+	flagSets := make([]*FlagSet, 0, len(c.filters)+1)
+	for _, filter := range c.filters {
+		flagSets = append(flagSets, filter.flagSet)
+	}
+	if c.action != nil {
+		flagSets = append(flagSets, c.action.flagSet)
+	}
+	c.lock.RUnlock()
+	for _, flagSet := range flagSets {
+		for k, v := range flagSet.JSONSchema()["properties"].(map[string]interface{}) {
+			properties[k] = v
+		}
+	}
+	schema := map[string]interface{}{
+		"$schema":    "https://json-schema.org/draft/2020-12/schema",
+		"title":      c.PathString(),
+		"type":       "object",
+		"properties": properties,
+	}
+	if c.description != "" {
+		schema["description"] = c.description
+	}
+	return schema
+}
+
+// JSONSchemas returns the JSON Schemas of the options of all the commands keyed by the command path string,
+// see Command.JSONSchema.
+func (a *App) JSONSchemas() map[string]map[string]interface{} {
+	schemas := make(map[string]map[string]interface{}, 16)
+	a.Walk(func(c *Command) bool {
+		schemas[c.PathString()] = c.JSONSchema()
+		return true
+	})
+	return schemas
+}
+
+func flagSchema(f *FlagSet, fl *Flag, nonFlag bool) map[string]interface{} {
+	schema := make(map[string]interface{}, 4)
+	var typ string
+	if getter, ok := fl.Value.(Getter); ok {
+		switch getter.Get().(type) {
+		case bool:
+			typ = "boolean"
+		case int, int64, uint, uint64:
+			typ = "integer"
+		case float64:
+			typ = "number"
+		case time.Duration:
+			typ = "string"
+			schema["format"] = "duration"
+		}
+	}
+	if typ == "" {
+		typ = "string"
+	}
+	schema["type"] = typ
+	if _, usage := UnquoteUsage(fl); usage != "" {
+		schema["description"] = usage
+	}
+	if !isZeroValue(fl, fl.DefValue) {
+		schema["default"] = configValue(fl, fl.DefValue)
+	}
+	if f.IsSecret(fl.Name) {
+		schema["writeOnly"] = true
+		schema["format"] = "password"
+	}
+	if nonFlag {
+		schema["x-nonflag"] = true
+	}
+	return schema
+}
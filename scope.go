@@ -0,0 +1,55 @@
+package flagx
+
+import (
+	"fmt"
+)
+
+// ScopeAll a bitmask scope that contains all bits
+const ScopeAll Scope = ^Scope(0)
+
+// ScopeBit returns the scope with only the @n-th bit set.
+// NOTE:
+//  panic if @n is not in [0,31)
+func ScopeBit(n uint) Scope {
+	if n >= 31 {
+		panic(fmt.Errorf("scope bit out of range: %d", n))
+	}
+	return Scope(1) << n
+}
+
+// CombineScopes returns the union of the bitmask scopes.
+func CombineScopes(scopes ...Scope) Scope {
+	var s Scope
+	for _, scope := range scopes {
+		s |= scope
+	}
+	return s
+}
+
+// Union returns the union of the bitmask scopes.
+func (s Scope) Union(scopes ...Scope) Scope {
+	return s | CombineScopes(scopes...)
+}
+
+// Has reports whether the bitmask scope contains all bits of @other.
+func (s Scope) Has(other Scope) bool {
+	return s&other == other
+}
+
+// Overlaps reports whether the bitmask scopes have at least one common bit.
+func (s Scope) Overlaps(other Scope) bool {
+	return s&other != 0
+}
+
+// ScopeBitmaskMatcher is a scope matching function that treats Scope as a bitmask.
+// NOTE:
+//  InitialScope command matches any executor scope;
+//  otherwise the command scope and executor scope must have at least one common bit.
+// Example:
+//  app.SetScopeMatcher(flagx.ScopeBitmaskMatcher)
+func ScopeBitmaskMatcher(cmdScope, execScope Scope) error {
+	if cmdScope == InitialScope || cmdScope.Overlaps(execScope) {
+		return nil
+	}
+	return fmt.Errorf("scope mismatch: cmdScope=%#x, execScope=%#x", uint32(cmdScope), uint32(execScope))
+}
@@ -0,0 +1,91 @@
+package flagx
+
+import (
+	"fmt"
+)
+
+type (
+	// SecretResolver resolves the references in the values of the secret flags,
+	// such as "vault:kv/app#token", to the secret values
+	SecretResolver interface {
+		// Resolve returns the secret value of the reference.
+		// NOTE:
+		//  if the value is not a reference it knows, it should be returned unchanged.
+		Resolve(ref string) (string, error)
+	}
+	// SecretResolverFunc secret resolver function
+	SecretResolverFunc func(ref string) (string, error)
+)
+
+// Resolve implements SecretResolver interface.
+func (fn SecretResolverFunc) Resolve(ref string) (string, error) {
+	return fn(ref)
+}
+
+// MarkSecret marks the flags or non-flags as secret, whose values are resolved by the secret resolver.
+// NOTE:
+//  the struct field can be marked by the "secret" key in the tag, such as `flag:"token;secret"`.
+func (f *FlagSet) MarkSecret(names ...string) error {
+	for _, name := range names {
+		if f.Lookup(name) == nil {
+			return fmt.Errorf("no such flag -%s", name)
+		}
+		if f.secrets == nil {
+			f.secrets = make(map[string]bool, 4)
+		}
+		f.secrets[name] = true
+	}
+	return nil
+}
+
+// IsSecret reports whether the flag or non-flag is marked as secret.
+func (f *FlagSet) IsSecret(name string) bool {
+	return f.secrets[name]
+}
+
+// SetSecretResolver sets the resolver of the values of the secret flags,
+// which are resolved after parsing and expansion.
+// NOTE:
+//  set nil to disable it.
+func (f *FlagSet) SetSecretResolver(r SecretResolver) {
+	f.secretResolver = r
+}
+
+// SetSecretResolver sets the resolver of the values of the secret flags of all the commands,
+// which are resolved after parsing and expansion.
+// NOTE:
+//  if it fails, Exec returns a status with code StatusConfigFailed;
+//  set nil to disable it.
+func (a *App) SetSecretResolver(r SecretResolver) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.secretResolver = r
+}
+
+// resolveSecrets resolves the non-empty values of the secret flags and non-flags, including the defaults.
+func (f *FlagSet) resolveSecrets(r SecretResolver) error {
+	if r == nil || len(f.secrets) == 0 {
+		return nil
+	}
+	var err error
+	f.RangeAll(func(fl *Flag) {
+		if err != nil || !f.secrets[fl.Name] {
+			return
+		}
+		ref := fl.Value.String()
+		if ref == "" {
+			return
+		}
+		value, e := r.Resolve(ref)
+		if e != nil {
+			err = fmt.Errorf("resolve secret flag -%s: %v", fl.Name, e)
+			return
+		}
+		if value != ref {
+			if e = f.setValue(fl, value); e != nil {
+				err = fmt.Errorf("invalid secret value for flag -%s: %v", fl.Name, e)
+			}
+		}
+	})
+	return err
+}
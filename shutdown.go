@@ -0,0 +1,198 @@
+package flagx
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DefaultShutdownGrace the default grace period of Shutdown
+const DefaultShutdownGrace = 10 * time.Second
+
+type (
+	// shutdownState the running executions that can be shut down
+	shutdownState struct {
+		running  map[*execShutdown]struct{}
+		closing  bool
+		lock     sync.Mutex
+		shutting sync.Mutex
+	}
+	// execShutdown the shutdown hooks of an execution
+	execShutdown struct {
+		cancel    context.CancelFunc
+		funcs     []func()
+		triggered bool
+		done      chan struct{} // Closed when the execution returns
+		lock      sync.Mutex
+	}
+)
+
+// osExit is os.Exit, which is replaced in the tests.
+var osExit = os.Exit
+
+// EnableShutdown shuts down the running executions gracefully when the signals are received,
+// and returns the function to stop it.
+// NOTE:
+//  if @sig is empty, os.Interrupt and syscall.SIGTERM are used;
+//  the process exits with code 1 if the executions do not return within the grace period,
+//  or when the signal is received again;
+//  see Shutdown.
+func (a *App) EnableShutdown(sig ...os.Signal) (stop func()) {
+	if len(sig) == 0 {
+		sig = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	a.lock.Lock()
+	a.shutdownEnabled = true
+	a.lock.Unlock()
+	ch := make(chan os.Signal, 2)
+	done := make(chan struct{})
+	signal.Notify(ch, sig...)
+	go func() {
+		var received bool
+		for {
+			select {
+			case <-ch:
+				if received {
+					osExit(1)
+					return
+				}
+				received = true
+				go func() {
+					if !a.Shutdown() {
+						osExit(1)
+					}
+				}()
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+			a.lock.Lock()
+			a.shutdownEnabled = false
+			a.lock.Unlock()
+		})
+	}
+}
+
+// SetShutdownGrace sets the period waiting for the running executions to return after Shutdown,
+// such as the time for the servers to drain the connections.
+// NOTE:
+//  if @grace <= 0, DefaultShutdownGrace is used.
+func (a *App) SetShutdownGrace(grace time.Duration) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.shutdownGrace = grace
+}
+
+// Shutdown calls the functions added by Context.OnShutdown and cancels the contexts of the running executions,
+// then waits for them to return within the grace period, and reports whether they all returned.
+// NOTE:
+//  only the executions started after EnableShutdown are shut down;
+//  the executions started after Shutdown are cancelled at once.
+func (a *App) Shutdown() bool {
+	a.shutdown.shutting.Lock()
+	defer a.shutdown.shutting.Unlock()
+	a.lock.RLock()
+	grace := a.shutdownGrace
+	a.lock.RUnlock()
+	if grace <= 0 {
+		grace = DefaultShutdownGrace
+	}
+	a.shutdown.lock.Lock()
+	a.shutdown.closing = true
+	running := make([]*execShutdown, 0, len(a.shutdown.running))
+	for s := range a.shutdown.running {
+		running = append(running, s)
+	}
+	a.shutdown.lock.Unlock()
+	for _, s := range running {
+		go s.trigger()
+	}
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+	for _, s := range running {
+		select {
+		case <-s.done:
+		case <-timer.C:
+			return false
+		}
+	}
+	return true
+}
+
+// OnShutdown adds the function called when the app is shut down, such as draining the connections of a server.
+// NOTE:
+//  the functions are called in the order they were added, and then the context is cancelled;
+//  if the execution is already shut down, the function is called at once;
+//  the function is never called if the shutdown is not enabled, see App.EnableShutdown.
+func (c *Context) OnShutdown(fn func()) {
+	s := c.snap.shutdown
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	if s.triggered {
+		s.lock.Unlock()
+		fn()
+		return
+	}
+	s.funcs = append(s.funcs, fn)
+	s.lock.Unlock()
+}
+
+// trackShutdown returns the cancelable context of the execution tracked by Shutdown.
+func (a *App) trackShutdown(snap *execSnapshot, ctx context.Context) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	s := &execShutdown{done: make(chan struct{})}
+	ctx, s.cancel = context.WithCancel(ctx)
+	snap.shutdown = s
+	a.shutdown.lock.Lock()
+	closing := a.shutdown.closing
+	if !closing {
+		if a.shutdown.running == nil {
+			a.shutdown.running = make(map[*execShutdown]struct{}, 16)
+		}
+		a.shutdown.running[s] = struct{}{}
+	}
+	a.shutdown.lock.Unlock()
+	if closing {
+		s.trigger()
+	}
+	return ctx
+}
+
+func (a *App) untrackShutdown(snap *execSnapshot) {
+	s := snap.shutdown
+	a.shutdown.lock.Lock()
+	delete(a.shutdown.running, s)
+	a.shutdown.lock.Unlock()
+	s.cancel()
+	close(s.done)
+}
+
+// trigger calls the shutdown functions once and cancels the context.
+func (s *execShutdown) trigger() {
+	s.lock.Lock()
+	if s.triggered {
+		s.lock.Unlock()
+		return
+	}
+	s.triggered = true
+	funcs := s.funcs
+	s.funcs = nil
+	s.lock.Unlock()
+	for _, fn := range funcs {
+		fn()
+	}
+	s.cancel()
+}
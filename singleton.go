@@ -0,0 +1,75 @@
+package flagx
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// SetSingleton sets the lock file that the action of the command holds while running,
+// so that only one instance of the command runs at a time, such as the cron-invoked maintenance commands.
+// NOTE:
+//  the lock is acquired after the flags are parsed and the filters pass,
+//  and released after the action and the goroutines it started by Context.Go return;
+//  if another instance holds the lock, the execution returns a status with code StatusLocked;
+//  the process ID is written to the lock file, which is flocked on unix and exclusively created on the others;
+//  set empty string to disable it;
+//  panic when the command tree is frozen
+// Example:
+//  app.AddSubaction("gc", "collect the garbage", new(GCAction))
+//  app.LookupSubcommand("gc").SetSingleton("/var/run/app-gc.lock")
+func (c *Command) SetSingleton(lockPath string) {
+	c.checkMutable()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.singleton = lockPath
+}
+
+// Singleton returns the lock file set by SetSingleton.
+func (c *Command) Singleton() string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.singleton
+}
+
+// singletonAction wraps the action to run under the lock file.
+func singletonAction(lockPath string, action ActionFunc) ActionFunc {
+	return func(c *Context) {
+		release, holder, err := acquireLockFile(lockPath)
+		if err != nil {
+			c.ThrowStatus(StatusExecuteFailed, "", err)
+		}
+		if release == nil {
+			msg := c.snap.translate(MsgLocked, c.CmdPathString(), lockPath)
+			if holder != "" {
+				msg += " (pid " + holder + ")"
+			}
+			c.ThrowStatus(StatusLocked, msg)
+		}
+		defer release()
+		defer func() {
+			if r := recover(); r != nil {
+				c.stopGoroutines()
+				panic(r)
+			}
+		}()
+		action(c)
+		// the goroutines started by Context.Go run under the lock too
+		c.Wait()
+	}
+}
+
+// writePID writes the process ID to the lock file.
+func writePID(f *os.File) error {
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	_, err := f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	return err
+}
+
+// readPID returns the process ID written in the lock file.
+func readPID(lockPath string) string {
+	b, _ := os.ReadFile(lockPath)
+	return strings.TrimSpace(string(b))
+}
@@ -0,0 +1,31 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package flagx
+
+import (
+	"os"
+)
+
+// acquireLockFile creates the lock file exclusively, which is removed by the release.
+// It returns nil release and the process ID of the holder if another instance holds the lock.
+// NOTE:
+//  the lock file left by a crashed instance should be removed manually.
+func acquireLockFile(lockPath string) (release func(), holder string, err error) {
+	f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0644)
+	if err != nil {
+		if os.IsExist(err) {
+			return nil, readPID(lockPath), nil
+		}
+		return nil, "", err
+	}
+	if err = writePID(f); err != nil {
+		f.Close()
+		os.Remove(lockPath)
+		return nil, "", err
+	}
+	return func() {
+		f.Close()
+		os.Remove(lockPath)
+	}, "", nil
+}
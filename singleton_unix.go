@@ -0,0 +1,33 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package flagx
+
+import (
+	"os"
+	"syscall"
+)
+
+// acquireLockFile flocks the lock file exclusively without blocking.
+// It returns nil release and the process ID of the holder if another instance holds the lock.
+func acquireLockFile(lockPath string) (release func(), holder string, err error) {
+	f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, "", err
+	}
+	if err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if err == syscall.EWOULDBLOCK {
+			return nil, readPID(lockPath), nil
+		}
+		return nil, "", err
+	}
+	if err = writePID(f); err != nil {
+		f.Close()
+		return nil, "", err
+	}
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, "", nil
+}
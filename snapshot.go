@@ -0,0 +1,164 @@
+package flagx
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+)
+
+type (
+	// ValueSource the source of the flag value
+	ValueSource int8
+	// ResolvedValue the effective value of a flag after parsing
+	ResolvedValue struct {
+		Value  string      // The final value
+		Source ValueSource // The source of the value
+		Raw    string      // The raw input from the source, before expansion
+		From   string      // The locator in the source, such as the environment variable name or the config file name
+	}
+	// valueOrigin the source and raw input of a flag value
+	valueOrigin struct {
+		source ValueSource
+		raw    string
+		from   string
+	}
+)
+
+// Value sources
+const (
+	SourceDefault     ValueSource = iota // The default value
+	SourceCommandLine                    // The command line
+	SourceEnv                            // The environment variable
+	SourceRemote                         // The remote source
+	SourceConfig                         // The config file
+	SourceProvider                       // The defaults provider
+)
+
+// String returns the name of the source.
+func (s ValueSource) String() string {
+	switch s {
+	case SourceCommandLine:
+		return "cli"
+	case SourceEnv:
+		return "env"
+	case SourceRemote:
+		return "remote"
+	case SourceConfig:
+		return "config"
+	case SourceProvider:
+		return "provider"
+	default:
+		return "default"
+	}
+}
+
+// Describe returns where the value came from, such as "from env MYAPP_PORT" or "from config app.json".
+func (r ResolvedValue) Describe() string {
+	switch r.Source {
+	case SourceCommandLine:
+		return "from command line"
+	case SourceProvider:
+		return "from defaults provider"
+	case SourceDefault:
+		return "default"
+	}
+	if r.From == "" {
+		return "from " + r.Source.String()
+	}
+	return "from " + r.Source.String() + " " + r.From
+}
+
+// Snapshot returns the effective values of all the flags and non-flags keyed by name,
+// recording their sources and raw inputs.
+func (f *FlagSet) Snapshot() map[string]ResolvedValue {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	m := make(map[string]ResolvedValue, 16)
+	actual := make(map[string]bool, 16)
+	f.Range(func(fl *Flag) {
+		actual[fl.Name] = true
+	})
+	f.RangeAll(func(fl *Flag) {
+		r := ResolvedValue{Value: fl.Value.String(), Raw: fl.DefValue}
+		if o, ok := f.origins[fl.Name]; ok {
+			r.Source, r.Raw, r.From = o.source, o.raw, o.from
+		} else if actual[fl.Name] {
+			r.Source, r.Raw = SourceCommandLine, r.Value
+		}
+		m[fl.Name] = r
+	})
+	return m
+}
+
+// PrintEffective prints, to standard error unless configured otherwise, the effective values
+// of all the flags and non-flags with where they came from, which helps to debug the deployments.
+// NOTE:
+//  the values of the secret flags are masked.
+func (f *FlagSet) PrintEffective() {
+	f.printEffective(f.Output(), f.Snapshot())
+}
+
+func (f *FlagSet) printEffective(w io.Writer, snapshot map[string]ResolvedValue) {
+	print := func(prefix string) func(*Flag) {
+		return func(fl *Flag) {
+			r := snapshot[fl.Name]
+			value := r.Value
+			if f.IsSecret(fl.Name) && value != "" {
+				value = SecretMask
+			} else if isStringFlag(fl) {
+				value = strconv.Quote(value)
+			}
+			fmt.Fprintf(w, "  %s%s=%s\t(%s)\n", prefix, fl.Name, value, r.Describe())
+		}
+	}
+	f.VisitAll(print("-"))
+	f.NonVisitAll(print(""))
+}
+
+// setOrigin records the source, raw input and locator of the flag value.
+func (f *FlagSet) setOrigin(name string, source ValueSource, raw, from string) {
+	if f.origins == nil {
+		f.origins = make(map[string]valueOrigin, 8)
+	}
+	f.origins[name] = valueOrigin{source: source, raw: raw, from: from}
+	if source != SourceCommandLine {
+		f.diagnose(slog.LevelDebug, "flagx: flag value applied",
+			slog.String("flag", name), slog.String("source", source.String()), slog.String("from", from))
+	}
+}
+
+// recordCommandLine records the flags and non-flags set on the command line.
+func (f *FlagSet) recordCommandLine() {
+	f.Range(func(fl *Flag) {
+		f.setOrigin(fl.Name, SourceCommandLine, fl.Value.String(), "")
+	})
+}
+
+// Snapshot returns the effective values of the flags and non-flags of the filters and action
+// of the execution keyed by name, recording their sources and raw inputs.
+// NOTE:
+//  if the names are duplicated, the one of the action, or the latter filter, is used.
+func (c *Context) Snapshot() map[string]ResolvedValue {
+	m := make(map[string]ResolvedValue, 16)
+	if c.snap == nil {
+		return m
+	}
+	for _, b := range c.snap.bindings {
+		for k, v := range b.flagSet.Snapshot() {
+			m[k] = v
+		}
+	}
+	return m
+}
+
+// PrintEffective prints the effective values of the flags and non-flags of the filters and action
+// of the execution with where they came from, see FlagSet.PrintEffective.
+func (c *Context) PrintEffective(w io.Writer) {
+	if c.snap == nil {
+		return
+	}
+	for _, b := range c.snap.bindings {
+		b.flagSet.printEffective(w, b.flagSet.Snapshot())
+	}
+}
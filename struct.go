@@ -5,6 +5,7 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/henrylee2cn/ameda"
@@ -16,38 +17,67 @@ const (
 	tagKeyOmit        = "-"
 	tagKeyNameDefault = "def"
 	tagKeyNameUsage   = "usage"
+	tagKeySecret      = "secret"
 	// tag name of the non-flag command-line arguments.
 	tagKeyNonFlag = "?"
 )
 
-var timeDurationTypeID = ameda.ValueOf(time.Duration(0)).RuntimeTypeID()
+var (
+	timeDurationTypeID = ameda.ValueOf(time.Duration(0)).RuntimeTypeID()
+	stringSliceType    = reflect.TypeOf([]string(nil))
+	intSliceType       = reflect.TypeOf([]int(nil))
+	int64SliceType     = reflect.TypeOf([]int64(nil))
+)
 
-func (f *FlagSet) varFromStruct(v reflect.Value, structTypeIDs map[uintptr]struct{}) error {
-	v = ameda.DereferenceValue(v)
-	if v.Kind() != reflect.Struct {
-		return fmt.Errorf("flagx: want struct pointer field, but got %s", v.Type().String())
+type (
+	// structPlan the flag definitions compiled from a struct type
+	structPlan struct {
+		fields []*fieldPlan
+		err    error
+	}
+	// fieldPlan the flag definition of a struct field
+	fieldPlan struct {
+		index    []int // The field index path, including the anonymous structs
+		names    []string
+		nonFlags []int       // The non-flag index of each name, -1 for a flag
+		def      interface{} // The default value converted to the field type
+		usage    string
+		secret   bool
 	}
-	t := v.Type()
-	tid := ameda.RuntimeTypeID(t)
-	if _, ok := structTypeIDs[tid]; ok {
+)
+
+// structPlans caches the compiled *structPlan by the struct type,
+// so that binding a fresh struct does not parse the tags again.
+var structPlans sync.Map
+
+// structPlanOf returns the compiled flag definitions of the struct type.
+func structPlanOf(t reflect.Type) *structPlan {
+	if p, ok := structPlans.Load(t); ok {
+		return p.(*structPlan)
+	}
+	plan := new(structPlan)
+	plan.err = plan.compile(t, nil, make(map[reflect.Type]struct{}, 4))
+	p, _ := structPlans.LoadOrStore(t, plan)
+	return p.(*structPlan)
+}
+
+func (p *structPlan) compile(t reflect.Type, index []int, structTypes map[reflect.Type]struct{}) error {
+	if _, ok := structTypes[t]; ok {
 		return nil
 	}
-	structTypeIDs[tid] = struct{}{}
+	structTypes[t] = struct{}{}
 	for i := t.NumField() - 1; i >= 0; i-- {
-		fv := v.Field(i)
-		if !fv.CanSet() {
+		ft := t.Field(i)
+		if ft.PkgPath != "" {
 			continue
 		}
-		ft := t.Field(i)
 		tag, ok := ft.Tag.Lookup(tagNameFlag)
 		if tag == tagKeyOmit {
 			continue
 		}
-		if !ameda.InitPointer(fv) {
-			return fmt.Errorf("flagx: can not set field %s, type=%s", ft.Name, ft.Type.String())
-		}
-		fvElem := ameda.DereferenceValue(fv)
-		kind := fvElem.Kind()
+		fieldIndex := append(append(make([]int, 0, len(index)+1), index...), i)
+		elemType := ameda.DereferenceType(ft.Type)
+		kind := elemType.Kind()
 		switch kind {
 		case reflect.String,
 			reflect.Bool,
@@ -57,10 +87,17 @@ func (f *FlagSet) varFromStruct(v reflect.Value, structTypeIDs map[uintptr]struc
 			if !ok {
 				continue
 			}
+		case reflect.Slice:
+			if elemType != stringSliceType && elemType != intSliceType && elemType != int64SliceType {
+				return fmt.Errorf("flagx: not support field %s, type=%s, kind=%s", ft.Name, ft.Type.String(), kind)
+			}
+			if !ok {
+				continue
+			}
 
 		default:
 			if !ok && kind == reflect.Struct && ft.Anonymous {
-				err := f.varFromStruct(ameda.DereferenceValue(fv), structTypeIDs)
+				err := p.compile(elemType, fieldIndex, structTypes)
 				if err != nil {
 					return err
 				}
@@ -69,11 +106,15 @@ func (f *FlagSet) varFromStruct(v reflect.Value, structTypeIDs map[uintptr]struc
 				return fmt.Errorf("flagx: not support field %s, type=%s, kind=%s", ft.Name, ft.Type.String(), kind)
 			}
 		}
-		keys := strings.SplitN(tag, ";", 3)
-		var def, usage string
-		var names []string
-		for _, key := range keys {
-			key = strings.TrimSpace(key)
+		field := &fieldPlan{index: fieldIndex}
+		var def string
+		keys := strings.Split(tag, ";")
+		for i := 0; i < len(keys); i++ {
+			key := strings.TrimSpace(keys[i])
+			if key == tagKeySecret {
+				field.secret = true
+				continue
+			}
 			_def, ok := parseTagKey(key, tagKeyNameDefault)
 			if ok {
 				def = _def
@@ -81,39 +122,80 @@ func (f *FlagSet) varFromStruct(v reflect.Value, structTypeIDs map[uintptr]struc
 			}
 			_usage, ok := parseTagKey(key, tagKeyNameUsage)
 			if ok {
-				usage = _usage
+				// the usage may contain ';'
+				for i+1 < len(keys) && !isTagKey(keys[i+1]) {
+					i++
+					_usage += ";" + keys[i]
+				}
+				field.usage = strings.TrimSpace(_usage)
 				continue
 			}
-			names = parseTagNames(key)
+			field.names = parseTagNames(key)
 		}
-		if len(names) == 0 {
-			names = append(names, ft.Name)
+		if len(field.names) == 0 {
+			field.names = append(field.names, ft.Name)
 		}
-		err := f.varReflectValue(fvElem, names, def, usage)
-		if err != nil {
+		if err := field.compileDefault(elemType, def); err != nil {
 			return err
 		}
+		p.fields = append(p.fields, field)
 	}
 	return nil
 }
 
-func (f *FlagSet) varReflectValue(elem reflect.Value, names []string, def, usage string) error {
-	var err error
-	val := elem.Addr().Interface()
-	kind := elem.Kind()
-	switch kind {
-	case reflect.String:
-		for _, name := range names {
-			idx, isNon, err := getNonFlagIndex(name)
-			if err != nil {
+func (f *FlagSet) varFromStruct(v reflect.Value) error {
+	v = ameda.DereferenceValue(v)
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("flagx: want struct pointer field, but got %s", v.Type().String())
+	}
+	plan := structPlanOf(v.Type())
+	if plan.err != nil {
+		return plan.err
+	}
+	// the fields are compiled in reverse order, record them in the declaration order
+	for i := len(plan.fields) - 1; i >= 0; i-- {
+		for _, name := range plan.fields[i].names {
+			f.recordDefinition(name)
+		}
+	}
+	for _, field := range plan.fields {
+		fv, err := initFieldByIndex(v, field.index)
+		if err != nil {
+			return err
+		}
+		err = f.varReflectValue(fv, field)
+		if err != nil {
+			return err
+		}
+		if field.secret {
+			if err = f.MarkSecret(field.names...); err != nil {
 				return err
 			}
-			if isNon {
-				f.NonStringVar(val.(*string), idx, def, usage)
-			} else {
-				f.FlagSet.StringVar(val.(*string), name, def, usage)
-			}
 		}
+	}
+	return nil
+}
+
+// initFieldByIndex returns the dereferenced nested field by the index path,
+// and initializes the nil pointers along the way.
+func initFieldByIndex(v reflect.Value, index []int) (reflect.Value, error) {
+	for _, i := range index {
+		fv := v.Field(i)
+		if !ameda.InitPointer(fv) {
+			ft := v.Type().Field(i)
+			return fv, fmt.Errorf("flagx: can not set field %s, type=%s", ft.Name, ft.Type.String())
+		}
+		v = ameda.DereferenceValue(fv)
+	}
+	return v, nil
+}
+
+// compileDefault converts the default value to the field type and resolves the non-flag indexes,
+// so that binding a fresh struct only defines the flags.
+func (p *fieldPlan) compileDefault(t reflect.Type, def string) (err error) {
+	switch t.Kind() {
+	case reflect.String:
+		p.def = def
 	case reflect.Bool:
 		var b bool
 		if def != "" {
@@ -122,17 +204,7 @@ func (f *FlagSet) varReflectValue(elem reflect.Value, names []string, def, usage
 				return fmt.Errorf("flagx: %q cannot be converted to bool", def)
 			}
 		}
-		for _, name := range names {
-			idx, isNon, err := getNonFlagIndex(name)
-			if err != nil {
-				return err
-			}
-			if isNon {
-				f.NonBoolVar(val.(*bool), idx, b, usage)
-			} else {
-				f.FlagSet.BoolVar(val.(*bool), name, b, usage)
-			}
-		}
+		p.def = b
 	case reflect.Float64:
 		var b float64
 		if def != "" {
@@ -141,17 +213,7 @@ func (f *FlagSet) varReflectValue(elem reflect.Value, names []string, def, usage
 				return fmt.Errorf("flagx: %q cannot be converted to float64", def)
 			}
 		}
-		for _, name := range names {
-			idx, isNon, err := getNonFlagIndex(name)
-			if err != nil {
-				return err
-			}
-			if isNon {
-				f.NonFloat64Var(val.(*float64), idx, b, usage)
-			} else {
-				f.FlagSet.Float64Var(val.(*float64), name, b, usage)
-			}
-		}
+		p.def = b
 	case reflect.Int:
 		var b int
 		if def != "" {
@@ -160,19 +222,9 @@ func (f *FlagSet) varReflectValue(elem reflect.Value, names []string, def, usage
 				return fmt.Errorf("flagx: %q cannot be converted to int", def)
 			}
 		}
-		for _, name := range names {
-			idx, isNon, err := getNonFlagIndex(name)
-			if err != nil {
-				return err
-			}
-			if isNon {
-				f.NonIntVar(val.(*int), idx, b, usage)
-			} else {
-				f.FlagSet.IntVar(val.(*int), name, b, usage)
-			}
-		}
+		p.def = b
 	case reflect.Int64:
-		if ameda.RuntimeTypeID(elem.Type()) == timeDurationTypeID {
+		if ameda.RuntimeTypeID(t) == timeDurationTypeID {
 			var b time.Duration
 			if def != "" {
 				b, err = time.ParseDuration(def)
@@ -180,17 +232,7 @@ func (f *FlagSet) varReflectValue(elem reflect.Value, names []string, def, usage
 					return fmt.Errorf("flagx: %q cannot be converted to time.Duration", def)
 				}
 			}
-			for _, name := range names {
-				idx, isNon, err := getNonFlagIndex(name)
-				if err != nil {
-					return err
-				}
-				if isNon {
-					f.NonDurationVar(val.(*time.Duration), idx, b, usage)
-				} else {
-					f.FlagSet.DurationVar(val.(*time.Duration), name, b, usage)
-				}
-			}
+			p.def = b
 		} else {
 			var b int64
 			if def != "" {
@@ -199,17 +241,7 @@ func (f *FlagSet) varReflectValue(elem reflect.Value, names []string, def, usage
 					return fmt.Errorf("flagx: %q cannot be converted to int64", def)
 				}
 			}
-			for _, name := range names {
-				idx, isNon, err := getNonFlagIndex(name)
-				if err != nil {
-					return err
-				}
-				if isNon {
-					f.NonInt64Var(val.(*int64), idx, b, usage)
-				} else {
-					f.FlagSet.Int64Var(val.(*int64), name, b, usage)
-				}
-			}
+			p.def = b
 		}
 	case reflect.Uint:
 		var b uint
@@ -220,17 +252,7 @@ func (f *FlagSet) varReflectValue(elem reflect.Value, names []string, def, usage
 			}
 			b = uint(b2)
 		}
-		for _, name := range names {
-			idx, isNon, err := getNonFlagIndex(name)
-			if err != nil {
-				return err
-			}
-			if isNon {
-				f.NonUintVar(val.(*uint), idx, b, usage)
-			} else {
-				f.FlagSet.UintVar(val.(*uint), name, b, usage)
-			}
-		}
+		p.def = b
 	case reflect.Uint64:
 		var b uint64
 		if def != "" {
@@ -239,19 +261,124 @@ func (f *FlagSet) varReflectValue(elem reflect.Value, names []string, def, usage
 				return fmt.Errorf("flagx: %q cannot be converted to uint64", def)
 			}
 		}
-		for _, name := range names {
-			idx, isNon, err := getNonFlagIndex(name)
-			if err != nil {
-				return err
+		p.def = b
+	case reflect.Slice:
+		switch t {
+		case stringSliceType:
+			var b []string
+			if def != "" {
+				b = strings.Split(def, ",")
+			}
+			p.def = b
+		case intSliceType:
+			var b []int
+			if def != "" {
+				if err := newIntSliceValue(nil, &b).Set(def); err != nil {
+					return fmt.Errorf("flagx: %q cannot be converted to []int", def)
+				}
+			}
+			p.def = b
+		case int64SliceType:
+			var b []int64
+			if def != "" {
+				if err := newInt64SliceValue(nil, &b).Set(def); err != nil {
+					return fmt.Errorf("flagx: %q cannot be converted to []int64", def)
+				}
+			}
+			p.def = b
+		default:
+			return fmt.Errorf("flagx: not support field type %s", t.String())
+		}
+	default:
+		return fmt.Errorf("flagx: not support field type %s", t.String())
+	}
+	p.nonFlags = make([]int, len(p.names))
+	for i, name := range p.names {
+		idx, isNon, err := getNonFlagIndex(name)
+		if err != nil {
+			return err
+		}
+		if isNon && t.Kind() == reflect.Slice {
+			return fmt.Errorf("flagx: not support non-flag field type %s", t.String())
+		}
+		p.nonFlags[i] = idx
+	}
+	return nil
+}
+
+// varReflectValue defines the flags of the field by the compiled definition.
+func (f *FlagSet) varReflectValue(elem reflect.Value, field *fieldPlan) error {
+	val := elem.Addr().Interface()
+	usage := field.usage
+	// the aliases share the slice value, so that they append to the same slice
+	var value Value
+	switch def := field.def.(type) {
+	case []string:
+		value = newStringSliceValue(append([]string(nil), def...), val.(*[]string))
+	case []int:
+		value = newIntSliceValue(append([]int(nil), def...), val.(*[]int))
+	case []int64:
+		value = newInt64SliceValue(append([]int64(nil), def...), val.(*[]int64))
+	}
+	if value != nil {
+		for _, name := range field.names {
+			f.Var(value, name, usage)
+		}
+		return nil
+	}
+	for i, name := range field.names {
+		idx := field.nonFlags[i]
+		isNon := idx >= 0
+		switch def := field.def.(type) {
+		case string:
+			if isNon {
+				f.NonStringVar(val.(*string), idx, def, usage)
+			} else {
+				f.FlagSet.StringVar(val.(*string), name, def, usage)
 			}
+		case bool:
 			if isNon {
-				f.NonUint64Var(val.(*uint64), idx, b, usage)
+				f.NonBoolVar(val.(*bool), idx, def, usage)
 			} else {
-				f.FlagSet.Uint64Var(val.(*uint64), name, b, usage)
+				f.FlagSet.BoolVar(val.(*bool), name, def, usage)
+			}
+		case float64:
+			if isNon {
+				f.NonFloat64Var(val.(*float64), idx, def, usage)
+			} else {
+				f.FlagSet.Float64Var(val.(*float64), name, def, usage)
+			}
+		case int:
+			if isNon {
+				f.NonIntVar(val.(*int), idx, def, usage)
+			} else {
+				f.FlagSet.IntVar(val.(*int), name, def, usage)
+			}
+		case time.Duration:
+			if isNon {
+				f.NonDurationVar(val.(*time.Duration), idx, def, usage)
+			} else {
+				f.FlagSet.DurationVar(val.(*time.Duration), name, def, usage)
+			}
+		case int64:
+			if isNon {
+				f.NonInt64Var(val.(*int64), idx, def, usage)
+			} else {
+				f.FlagSet.Int64Var(val.(*int64), name, def, usage)
+			}
+		case uint:
+			if isNon {
+				f.NonUintVar(val.(*uint), idx, def, usage)
+			} else {
+				f.FlagSet.UintVar(val.(*uint), name, def, usage)
+			}
+		case uint64:
+			if isNon {
+				f.NonUint64Var(val.(*uint64), idx, def, usage)
+			} else {
+				f.FlagSet.Uint64Var(val.(*uint64), name, def, usage)
 			}
 		}
-	default:
-		return fmt.Errorf("flagx: not support field type %s", elem.Type().String())
 	}
 	return nil
 }
@@ -267,6 +394,19 @@ func parseTagKey(key, keyName string) (string, bool) {
 	return strings.TrimSpace(v), true
 }
 
+// isTagKey reports whether the part of the struct tag is a key, such as "def=".
+func isTagKey(key string) bool {
+	key = strings.TrimSpace(key)
+	if key == tagKeySecret {
+		return true
+	}
+	_, ok := parseTagKey(key, tagKeyNameDefault)
+	if !ok {
+		_, ok = parseTagKey(key, tagKeyNameUsage)
+	}
+	return ok
+}
+
 func parseTagNames(key string) []string {
 	a := strings.Split(key, ",")
 	names := make([]string, 0, len(a))
@@ -0,0 +1,75 @@
+package flagx
+
+import (
+	"sort"
+	"strings"
+)
+
+// maxFlagSuggestions the max number of the suggested flag names
+const maxFlagSuggestions = 3
+
+// SuggestFlags returns the defined flag names similar to the name, the nearest first,
+// which are suggested by *ErrUndefinedFlag, such as "did you mean -verbose?".
+// NOTE:
+//  the similar names are the ones having the name as the prefix or within a small edit distance;
+//  returns nil for the non-flag name, such as ?0.
+func (f *FlagSet) SuggestFlags(name string) []string {
+	if name == "" || strings.HasPrefix(name, tagKeyNonFlag) {
+		return nil
+	}
+	type candidate struct {
+		name     string
+		distance int
+	}
+	var candidates []candidate
+	// the short names are not similar to each other
+	maxDistance := len(name) / 3
+	f.FlagSet.VisitAll(func(fl *Flag) {
+		d := editDistance(strings.ToLower(name), strings.ToLower(fl.Name))
+		if d <= maxDistance || strings.HasPrefix(fl.Name, name) {
+			candidates = append(candidates, candidate{name: fl.Name, distance: d})
+		}
+	})
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].distance < candidates[j].distance
+	})
+	if len(candidates) > maxFlagSuggestions {
+		candidates = candidates[:maxFlagSuggestions]
+	}
+	names := make([]string, 0, len(candidates))
+	for _, c := range candidates {
+		names = append(names, c.name)
+	}
+	return names
+}
+
+// suggestFlags adds the similar flag names to the undefined flag error.
+func (f *FlagSet) suggestFlags(err error) error {
+	if e, ok := err.(*ErrUndefinedFlag); ok && e.Suggestions == nil {
+		e.Suggestions = f.SuggestFlags(e.Name)
+	}
+	return err
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	row := make([]int, len(t)+1)
+	for j := range row {
+		row[j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		prev := row[0]
+		row[0] = i
+		for j := 1; j <= len(t); j++ {
+			cur := row[j]
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			row[j] = min(row[j]+1, row[j-1]+1, prev+cost)
+			prev = cur
+		}
+	}
+	return row[len(t)]
+}
@@ -0,0 +1,250 @@
+package flagx
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TOMLDecoder decodes the TOML config data.
+// NOTE:
+//  only the subset used by the config files is supported: tables, dotted keys,
+//  strings, numbers, booleans, datetimes (as strings) and single-line arrays.
+func TOMLDecoder(data []byte) (map[string]interface{}, error) {
+	root := make(map[string]interface{}, 16)
+	table := root
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	var lineNo int
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(stripTOMLComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			if strings.HasPrefix(line, "[[") || !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: unsupported table: %s", lineNo, line)
+			}
+			keys, err := splitTOMLKey(line[1 : len(line)-1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNo, err)
+			}
+			table, err = tomlTable(root, keys)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNo, err)
+			}
+			continue
+		}
+		i := strings.IndexByte(line, '=')
+		if i < 0 {
+			return nil, fmt.Errorf("line %d: missing '=': %s", lineNo, line)
+		}
+		keys, err := splitTOMLKey(line[:i])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNo, err)
+		}
+		value, rest, err := parseTOMLValue(strings.TrimSpace(line[i+1:]))
+		if err == nil && strings.TrimSpace(rest) != "" {
+			err = fmt.Errorf("unexpected text after value: %s", rest)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNo, err)
+		}
+		t, err := tomlTable(table, keys[:len(keys)-1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNo, err)
+		}
+		t[keys[len(keys)-1]] = value
+	}
+	return root, scanner.Err()
+}
+
+// stripTOMLComment removes the comment that is not in a string.
+func stripTOMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func splitTOMLKey(s string) ([]string, error) {
+	var keys []string
+	s = strings.TrimSpace(s)
+	for {
+		var key string
+		if s != "" && (s[0] == '"' || s[0] == '\'') {
+			v, rest, err := parseTOMLString(s)
+			if err != nil {
+				return nil, err
+			}
+			key, s = v, strings.TrimSpace(rest)
+		} else {
+			i := strings.IndexByte(s, '.')
+			if i < 0 {
+				i = len(s)
+			}
+			key, s = strings.TrimSpace(s[:i]), s[i:]
+		}
+		if key == "" {
+			return nil, fmt.Errorf("empty key")
+		}
+		keys = append(keys, key)
+		if s == "" {
+			return keys, nil
+		}
+		if s[0] != '.' {
+			return nil, fmt.Errorf("invalid key: %s", s)
+		}
+		s = strings.TrimSpace(s[1:])
+	}
+}
+
+func tomlTable(root map[string]interface{}, keys []string) (map[string]interface{}, error) {
+	t := root
+	for _, key := range keys {
+		switch v := t[key].(type) {
+		case nil:
+			sub := make(map[string]interface{}, 8)
+			t[key] = sub
+			t = sub
+		case map[string]interface{}:
+			t = v
+		default:
+			return nil, fmt.Errorf("key %q is not a table", key)
+		}
+	}
+	return t, nil
+}
+
+// parseTOMLValue parses the value at the beginning of s, and returns the rest.
+func parseTOMLValue(s string) (interface{}, string, error) {
+	if s == "" {
+		return nil, "", fmt.Errorf("missing value")
+	}
+	switch s[0] {
+	case '"', '\'':
+		return parseTOMLString(s)
+	case '[':
+		return parseTOMLArray(s)
+	}
+	i := strings.IndexAny(s, ",]")
+	if i < 0 {
+		i = len(s)
+	}
+	raw, rest := strings.TrimSpace(s[:i]), s[i:]
+	switch raw {
+	case "true":
+		return true, rest, nil
+	case "false":
+		return false, rest, nil
+	}
+	num := strings.Replace(raw, "_", "", -1)
+	if n, ok, err := parseTOMLInteger(num); ok {
+		return n, rest, err
+	}
+	if f, err := strconv.ParseFloat(num, 64); err == nil && !strings.ContainsAny(num, "xX") {
+		if hasLeadingZero(strings.TrimLeft(num, "+-")) {
+			return nil, "", fmt.Errorf("invalid value: %s, leading zeros are not allowed", raw)
+		}
+		return f, rest, nil
+	}
+	if raw != "" && raw[0] >= '0' && raw[0] <= '9' { // datetime
+		return raw, rest, nil
+	}
+	return nil, "", fmt.Errorf("invalid value: %s", raw)
+}
+
+// parseTOMLInteger parses the integer, which is decimal unless it has the prefix 0x, 0o or 0b,
+// and reports whether it is an integer.
+func parseTOMLInteger(num string) (int64, bool, error) {
+	if len(num) > 2 && num[0] == '0' {
+		base := 0
+		switch num[1] {
+		case 'x':
+			base = 16
+		case 'o':
+			base = 8
+		case 'b':
+			base = 2
+		}
+		if base != 0 {
+			n, err := strconv.ParseInt(num[2:], base, 64)
+			if err != nil {
+				return 0, true, fmt.Errorf("invalid value: %s", num)
+			}
+			return n, true, nil
+		}
+	}
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil {
+		return 0, false, nil
+	}
+	if hasLeadingZero(strings.TrimLeft(num, "+-")) {
+		return 0, true, fmt.Errorf("invalid value: %s, leading zeros are not allowed", num)
+	}
+	return n, true, nil
+}
+
+// hasLeadingZero reports whether the unsigned decimal number has a leading zero, such as 012 or 01.5.
+func hasLeadingZero(num string) bool {
+	return len(num) > 1 && num[0] == '0' && num[1] >= '0' && num[1] <= '9'
+}
+
+func parseTOMLString(s string) (string, string, error) {
+	if strings.HasPrefix(s, `"""`) || strings.HasPrefix(s, "'''") {
+		return "", "", fmt.Errorf("multi-line string is not supported")
+	}
+	quote := s[0]
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if quote == '"' {
+				i++
+			}
+		case quote:
+			if quote == '\'' {
+				return s[1:i], s[i+1:], nil
+			}
+			v, err := strconv.Unquote(s[:i+1])
+			return v, s[i+1:], err
+		}
+	}
+	return "", "", fmt.Errorf("unterminated string: %s", s)
+}
+
+func parseTOMLArray(s string) ([]interface{}, string, error) {
+	a := make([]interface{}, 0, 4)
+	s = strings.TrimSpace(s[1:])
+	for {
+		if s == "" {
+			return nil, "", fmt.Errorf("unterminated array")
+		}
+		if s[0] == ']' {
+			return a, s[1:], nil
+		}
+		v, rest, err := parseTOMLValue(s)
+		if err != nil {
+			return nil, "", err
+		}
+		a = append(a, v)
+		s = strings.TrimSpace(rest)
+		if strings.HasPrefix(s, ",") {
+			s = strings.TrimSpace(s[1:])
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package flagx
+
+import (
+	"time"
+
+	"github.com/henrylee2cn/goutil/status"
+)
+
+// Tracer traces the execution of the commands, such as OpenTelemetry spans or timing logs.
+// NOTE:
+//  the methods are called synchronously in the executing goroutine.
+type Tracer interface {
+	// RouteResolved is called when the command of the arguments is resolved.
+	RouteResolved(c *Context)
+	// FilterEnter is called before the filter is executed.
+	FilterEnter(c *Context, filter Filter)
+	// FilterExit is called after the filter is executed.
+	FilterExit(c *Context, filter Filter, cost time.Duration, stat *Status)
+	// ActionStart is called before the action is executed.
+	ActionStart(c *Context, action Action)
+	// ActionEnd is called after the action is executed.
+	ActionEnd(c *Context, action Action, cost time.Duration, stat *Status)
+}
+
+// NopTracer a tracer that does nothing, it can be embedded to implement part of the Tracer.
+type NopTracer struct{}
+
+var _ Tracer = NopTracer{}
+
+// RouteResolved implements Tracer interface.
+func (NopTracer) RouteResolved(*Context) {}
+
+// FilterEnter implements Tracer interface.
+func (NopTracer) FilterEnter(*Context, Filter) {}
+
+// FilterExit implements Tracer interface.
+func (NopTracer) FilterExit(*Context, Filter, time.Duration, *Status) {}
+
+// ActionStart implements Tracer interface.
+func (NopTracer) ActionStart(*Context, Action) {}
+
+// ActionEnd implements Tracer interface.
+func (NopTracer) ActionEnd(*Context, Action, time.Duration, *Status) {}
+
+// SetTracer sets the tracer of the executions.
+func (a *App) SetTracer(tracer Tracer) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.tracer = tracer
+}
+
+func traceAction(tracer Tracer, action Action) ActionFunc {
+	return func(c *Context) {
+		tracer.ActionStart(c, action)
+		start := time.Now()
+		defer func() {
+			r := recover()
+			tracer.ActionEnd(c, action, time.Since(start), panicStatus(r))
+			if r != nil {
+				panic(r)
+			}
+		}()
+		action.Execute(c)
+	}
+}
+
+func traceFilter(tracer Tracer, filter Filter, action Action, next ActionFunc) ActionFunc {
+	return func(c *Context) {
+		tracer.FilterEnter(c, filter)
+		start := time.Now()
+		defer func() {
+			r := recover()
+			tracer.FilterExit(c, filter, time.Since(start), panicStatus(r))
+			if r != nil {
+				panic(r)
+			}
+		}()
+		callFilter(c, filter, action, next)
+	}
+}
+
+// panicStatus converts the recovered panic to a status.
+func panicStatus(r interface{}) *Status {
+	switch v := r.(type) {
+	case nil:
+		return new(Status)
+	case *Status:
+		if v == nil {
+			return new(Status)
+		}
+		return v
+	case Status:
+		return &v
+	default:
+		return NewStatus(status.UnknownError, "", v)
+	}
+}
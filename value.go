@@ -3,6 +3,7 @@ package flagx
 import (
 	"errors"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -200,3 +201,158 @@ func (d *durationValue) Set(s string) error {
 func (d *durationValue) Get() interface{} { return time.Duration(*d) }
 
 func (d *durationValue) String() string { return (*time.Duration)(d).String() }
+
+// -- []string Value
+type stringSliceValue struct {
+	p       *[]string
+	def     []string
+	changed bool
+}
+
+func newStringSliceValue(val []string, p *[]string) *stringSliceValue {
+	*p = val
+	return &stringSliceValue{p: p, def: val}
+}
+
+// Set appends the value, the first one replaces the default values.
+func (s *stringSliceValue) Set(val string) error {
+	if !s.changed {
+		*s.p = nil
+		s.changed = true
+	}
+	*s.p = append(*s.p, val)
+	return nil
+}
+
+func (s *stringSliceValue) Get() interface{} { return *s.p }
+
+func (s *stringSliceValue) String() string {
+	if s.p == nil {
+		return ""
+	}
+	return strings.Join(*s.p, ",")
+}
+
+func (s *stringSliceValue) IsSliceFlag() bool { return true }
+
+// reset restores the default values.
+func (s *stringSliceValue) reset() {
+	s.changed = false
+	*s.p = s.def
+}
+
+// -- []int Value
+type intSliceValue struct {
+	p       *[]int
+	def     []int
+	changed bool
+}
+
+func newIntSliceValue(val []int, p *[]int) *intSliceValue {
+	*p = val
+	return &intSliceValue{p: p, def: val}
+}
+
+// Set appends the comma-separated values, the first one replaces the default values.
+func (s *intSliceValue) Set(val string) error {
+	elems := strings.Split(val, ",")
+	values := make([]int, len(elems))
+	for i, elem := range elems {
+		v, err := strconv.ParseInt(strings.TrimSpace(elem), 0, strconv.IntSize)
+		if err != nil {
+			return numError(err)
+		}
+		values[i] = int(v)
+	}
+	if !s.changed {
+		*s.p = nil
+		s.changed = true
+	}
+	*s.p = append(*s.p, values...)
+	return nil
+}
+
+func (s *intSliceValue) Get() interface{} { return *s.p }
+
+func (s *intSliceValue) String() string {
+	if s.p == nil {
+		return ""
+	}
+	elems := make([]string, len(*s.p))
+	for i, v := range *s.p {
+		elems[i] = strconv.Itoa(v)
+	}
+	return strings.Join(elems, ",")
+}
+
+func (s *intSliceValue) IsSliceFlag() bool { return true }
+
+// reset restores the default values.
+func (s *intSliceValue) reset() {
+	s.changed = false
+	*s.p = s.def
+}
+
+// -- []int64 Value
+type int64SliceValue struct {
+	p       *[]int64
+	def     []int64
+	changed bool
+}
+
+func newInt64SliceValue(val []int64, p *[]int64) *int64SliceValue {
+	*p = val
+	return &int64SliceValue{p: p, def: val}
+}
+
+// Set appends the comma-separated values, the first one replaces the default values.
+func (s *int64SliceValue) Set(val string) error {
+	elems := strings.Split(val, ",")
+	values := make([]int64, len(elems))
+	for i, elem := range elems {
+		v, err := strconv.ParseInt(strings.TrimSpace(elem), 0, 64)
+		if err != nil {
+			return numError(err)
+		}
+		values[i] = v
+	}
+	if !s.changed {
+		*s.p = nil
+		s.changed = true
+	}
+	*s.p = append(*s.p, values...)
+	return nil
+}
+
+func (s *int64SliceValue) Get() interface{} { return *s.p }
+
+func (s *int64SliceValue) String() string {
+	if s.p == nil {
+		return ""
+	}
+	elems := make([]string, len(*s.p))
+	for i, v := range *s.p {
+		elems[i] = strconv.FormatInt(v, 10)
+	}
+	return strings.Join(elems, ",")
+}
+
+func (s *int64SliceValue) IsSliceFlag() bool { return true }
+
+// reset restores the default values.
+func (s *int64SliceValue) reset() {
+	s.changed = false
+	*s.p = s.def
+}
+
+// isSliceFlag reports whether the flag accepts multiple values, see SliceValue.
+func isSliceFlag(f *Flag) bool {
+	v, ok := f.Value.(SliceValue)
+	return ok && v.IsSliceFlag()
+}
+
+// isBoolFlag reports whether the flag does not need an argument.
+func isBoolFlag(f *Flag) bool {
+	v, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && v.IsBoolFlag()
+}
@@ -0,0 +1,173 @@
+package flagx
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// VersionInfo the version information of the app, including the build information of the binary
+type VersionInfo struct {
+	Name          string    `json:"name"`
+	Version       string    `json:"version"`
+	Module        string    `json:"module,omitempty"`        // The main module path
+	ModuleVersion string    `json:"moduleVersion,omitempty"` // The main module version, such as v1.2.3 or (devel)
+	Revision      string    `json:"revision,omitempty"`      // The VCS revision
+	RevisionTime  string    `json:"revisionTime,omitempty"`  // The VCS commit time
+	Dirty         bool      `json:"dirty,omitempty"`         // The working tree has local modifications
+	GoVersion     string    `json:"goVersion"`
+	Platform      string    `json:"platform"` // The GOOS/GOARCH
+	Compiled      time.Time `json:"compiled"`
+}
+
+// Version output formats
+const (
+	VersionText = "text"
+	VersionJSON = "json"
+)
+
+// defaultVersionTemplate is the text template of the version information.
+var defaultVersionTemplate = template.Must(template.New("version").Parse(`{{.Name}} v{{.Version}}{{if .Revision}}
+revision: {{.Revision}}{{if .Dirty}} (dirty){{end}}{{if .RevisionTime}} {{.RevisionTime}}{{end}}{{end}}{{if .Module}}
+module: {{.Module}}{{if .ModuleVersion}} {{.ModuleVersion}}{{end}}{{end}}
+go: {{.GoVersion}} {{.Platform}}
+`))
+
+// readBuildInfo is debug.ReadBuildInfo, which is replaced in the tests.
+var readBuildInfo = debug.ReadBuildInfo
+
+// VersionInfo returns the version information of the app, which reads the build information
+// embedded in the binary, such as the module version and the VCS revision.
+func (a *App) VersionInfo() *VersionInfo {
+	a.lock.RLock()
+	info := &VersionInfo{
+		Name:      a.appName,
+		Version:   a.version,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+		Compiled:  a.compiled,
+	}
+	if info.Name == "" {
+		info.Name = a.cmdName
+	}
+	a.lock.RUnlock()
+	bi, ok := readBuildInfo()
+	if !ok {
+		return info
+	}
+	info.Module = bi.Main.Path
+	info.ModuleVersion = bi.Main.Version
+	if bi.GoVersion != "" {
+		info.GoVersion = bi.GoVersion
+	}
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			info.Revision = s.Value
+		case "vcs.time":
+			info.RevisionTime = s.Value
+		case "vcs.modified":
+			info.Dirty = s.Value == "true"
+		}
+	}
+	return info
+}
+
+// SetVersionTemplate sets the text template of the version information, whose data is *VersionInfo.
+func (a *App) SetVersionTemplate(tmpl *template.Template) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.versionTemplate = tmpl
+}
+
+// SetVersionFlag sets the name of the global flag printing the version information, such as "version",
+// which is combined with the -output flag to select the format, such as `--version --output json`.
+// NOTE:
+//  the flag is only recognized among the leading flags before any non-flag argument,
+//  and the execution stops after printing;
+//  the output formats are VersionText and VersionJSON;
+//  set empty string to disable it.
+func (a *App) SetVersionFlag(name string) {
+	name = strings.TrimLeft(name, "-")
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.versionFlag = name
+	a.resetUsageLocked()
+}
+
+// PrintVersion prints the version information in the format, see VersionText and VersionJSON.
+func (a *App) PrintVersion(w io.Writer, format string) error {
+	info := a.VersionInfo()
+	switch format {
+	case VersionJSON:
+		b, err := json.MarshalIndent(info, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append(b, '\n'))
+		return err
+	case VersionText, "":
+		a.lock.RLock()
+		tmpl := a.versionTemplate
+		a.lock.RUnlock()
+		if tmpl == nil {
+			tmpl = defaultVersionTemplate
+		}
+		return tmpl.Execute(w, info)
+	default:
+		return fmt.Errorf("unknown version output format: %s", format)
+	}
+}
+
+// versionFlagObject returns the flag object of the version flag for the usage.
+func (a *App) versionFlagObject() *Flag {
+	if a.versionFlag == "" {
+		return nil
+	}
+	return &Flag{Name: a.versionFlag, Usage: "print the version information, add -output json for the JSON format", Value: new(boolValue), DefValue: "false"}
+}
+
+// extractVersionFlag reports whether the leading flags contain the version flag,
+// and returns the value of the -o/--output flag.
+func (snap *execSnapshot) extractVersionFlag(arguments []string) (format string, found bool) {
+	if snap.versionFlag == "" {
+		return "", false
+	}
+	for i := 0; i < len(arguments); i++ {
+		arg := arguments[i]
+		name := strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+		if arg == "--" || name == arg {
+			break
+		}
+		name, value, hasValue := strings.Cut(name, "=")
+		switch {
+		case name == snap.versionFlag:
+			found = !hasValue || value == "true"
+		case isOutputFlag(name):
+			if !hasValue && i+1 < len(arguments) {
+				i++
+				value = arguments[i]
+			}
+			format = value
+		}
+	}
+	return format, found
+}
+
+// printVersion prints the version information to the output of the execution.
+func (c *Command) printVersion(snap *execSnapshot, format string) *Status {
+	output := snap.output
+	if output == nil {
+		output = os.Stdout
+	}
+	if err := c.app.PrintVersion(output, format); err != nil {
+		return snap.newStatus(StatusBadArgs, "", err)
+	}
+	return new(Status)
+}
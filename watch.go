@@ -0,0 +1,215 @@
+package flagx
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+type (
+	// ConfigChangeFunc is notified with the old and new values of the flag changed by the config file,
+	// the values are got by flag.Getter if implemented, otherwise they are the strings
+	ConfigChangeFunc func(name string, oldValue, newValue interface{})
+	// configFile a config file bound to the flag set
+	configFile struct {
+		filename string
+		decoder  ConfigDecoder
+	}
+	// fileStamp the modification stamp of a file
+	fileStamp struct {
+		modTime time.Time
+		size    int64
+	}
+)
+
+// WatchConfig watches the config files bound by BindConfigFile, and applies the changed values
+// of the flags that are not set on the command line when the files change.
+// NOTE:
+//  the directories of the files are watched by fsnotify, so that the files replaced by the editors are followed;
+//  if the file system is set by SetFS, the files are polled every @interval instead;
+//  the changes within @interval are coalesced into one reload, which limits the rate of reloading, defaults to 1s;
+//  it is stopped when @ctx is done;
+//  the values are applied under the lock of the flag set, so Snapshot and PrintEffective can be called concurrently;
+//  @fn is called in the watching goroutine after the values are applied,
+//  the access to the variables of the flags should be synchronized by it;
+//  if a file fails to load, the change is ignored until the next change.
+func (f *FlagSet) WatchConfig(ctx context.Context, interval time.Duration, fn ConfigChangeFunc) error {
+	if len(f.configFiles) == 0 {
+		return errors.New("flagx: no config file is bound")
+	}
+	if interval <= 0 {
+		interval = time.Second
+	}
+	files := append([]configFile(nil), f.configFiles...)
+	if f.fsys != nil {
+		f.pollConfigFiles(ctx, interval, files, fn)
+		return nil
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	names := make(map[string]bool, len(files))
+	for _, file := range files {
+		name, err := filepath.Abs(file.filename)
+		if err == nil {
+			err = watcher.Add(filepath.Dir(name))
+		}
+		if err != nil {
+			watcher.Close()
+			return err
+		}
+		names[name] = true
+	}
+	go func() {
+		defer watcher.Close()
+		var timer *time.Timer
+		var fire <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				if timer != nil {
+					timer.Stop()
+				}
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if fire != nil || event.Op == fsnotify.Chmod || !names[filepath.Clean(event.Name)] {
+					continue
+				}
+				timer = time.NewTimer(interval)
+				fire = timer.C
+			case _, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+			case <-fire:
+				fire = nil
+				f.reloadConfigFiles(nil, files, fn)
+			}
+		}
+	}()
+	return nil
+}
+
+// pollConfigFiles polls the config files of the file system set by SetFS every interval.
+func (f *FlagSet) pollConfigFiles(ctx context.Context, interval time.Duration, files []configFile, fn ConfigChangeFunc) {
+	fsys := f.fsys
+	stamps := statConfigFiles(fsys, files)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			s := statConfigFiles(fsys, files)
+			if equalStamps(s, stamps) {
+				continue
+			}
+			stamps = s
+			f.reloadConfigFiles(fsys, files, fn)
+		}
+	}()
+}
+
+func statConfigFiles(fsys fs.FS, files []configFile) []fileStamp {
+	stamps := make([]fileStamp, len(files))
+	for i, file := range files {
+		if info, err := statFile(fsys, file.filename); err == nil {
+			stamps[i] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+		}
+	}
+	return stamps
+}
+
+func equalStamps(a, b []fileStamp) bool {
+	for i := range a {
+		if !a[i].modTime.Equal(b[i].modTime) || a[i].size != b[i].size {
+			return false
+		}
+	}
+	return true
+}
+
+// reloadConfigFiles reloads the config files, and applies the changed values to the flags that are not
+// set on the command line by the precedence config file > defaults provider > default.
+func (f *FlagSet) reloadConfigFiles(fsys fs.FS, files []configFile, fn ConfigChangeFunc) {
+	values := make(map[string]string, 16)
+	from := make(map[string]string, 16)
+	for _, file := range files {
+		m, err := loadConfigFile(fsys, file.filename, file.decoder)
+		if err != nil {
+			return
+		}
+		for k, v := range m {
+			values[k] = v
+			from[k] = file.filename
+		}
+	}
+	type change struct {
+		name               string
+		oldValue, newValue interface{}
+	}
+	var changes []change
+	f.lock.Lock()
+	f.config = values
+	f.configFrom = from
+	actual := make(map[string]bool, 8)
+	f.FlagSet.Visit(func(fl *Flag) {
+		actual[fl.Name] = true
+	})
+	f.FlagSet.VisitAll(func(fl *Flag) {
+		if o, ok := f.origins[fl.Name]; (ok && o.source == SourceCommandLine) || (!ok && actual[fl.Name]) {
+			return
+		}
+		raw, source, file := fl.DefValue, SourceDefault, ""
+		if v, ok := values[fl.Name]; ok {
+			raw, source, file = v, SourceConfig, from[fl.Name]
+		} else if v, ok := lookupDefaults(f.defaultsProviders, fl.Name, nil); ok {
+			raw, source = v, SourceProvider
+		}
+		value := raw
+		if f.expander != nil && isStringFlag(fl) {
+			value = os.Expand(value, f.expander)
+		}
+		if f.secretResolver != nil && value != "" && f.IsSecret(fl.Name) {
+			var err error
+			if value, err = f.secretResolver.Resolve(value); err != nil {
+				return
+			}
+		}
+		if value == fl.Value.String() {
+			return
+		}
+		oldValue := flagValue(fl)
+		if err := f.setReloadedValue(fl, value); err != nil {
+			return
+		}
+		f.setOrigin(fl.Name, source, raw, file)
+		changes = append(changes, change{name: fl.Name, oldValue: oldValue, newValue: flagValue(fl)})
+	})
+	f.lock.Unlock()
+	if fn != nil {
+		for _, c := range changes {
+			fn(c.name, c.oldValue, c.newValue)
+		}
+	}
+}
+
+// flagValue returns the value got by flag.Getter if implemented, otherwise the string.
+func flagValue(f *Flag) interface{} {
+	if getter, ok := f.Value.(Getter); ok {
+		return getter.Get()
+	}
+	return f.Value.String()
+}